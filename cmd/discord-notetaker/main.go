@@ -14,6 +14,14 @@ import (
 )
 
 func main() {
+	// Offline replay of recorded audio
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Replay failed")
+		}
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
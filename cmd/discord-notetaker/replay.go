@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/bot"
+	"github.com/user/discord-notetaker/internal/config"
+)
+
+const replayUsage = `Usage: discord-notetaker replay [flags] [track.wav|track.ogg[=user] ...]
+
+Runs recorded audio through the transcription and summarisation pipeline
+without connecting to Discord. Give one file per speaker, optionally naming
+the speaker after '=', or a manifest listing the tracks. A session's
+metadata.json can be used as a manifest to replay its audio archive.
+
+With -capture, a raw voice capture recorded with VOICE_CAPTURE=true is fed
+through a voice session packet by packet, reproducing its speaker mapping
+and chunking.
+
+Flags:
+`
+
+// runReplay implements the replay subcommand.
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "JSON manifest listing the tracks to replay")
+	sessionID := flags.String("session-id", "", "Session ID for the results (default: generated)")
+	guildID := flags.String("guild", "", "Guild whose chunk settings to use")
+	capturePath := flags.String("capture", "", "Raw voice capture to replay")
+	speed := flags.Float64("speed", 0, "Capture replay speed: 1 is real time, 0 as fast as possible")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), replayUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *capturePath != "" {
+		if *manifestPath != "" || flags.NArg() > 0 {
+			return fmt.Errorf("-capture can't be combined with -manifest or track files")
+		}
+		return runCaptureReplay(*capturePath, *sessionID, *speed)
+	}
+
+	var manifest *bot.ReplayManifest
+	switch {
+	case *manifestPath != "" && flags.NArg() > 0:
+		return fmt.Errorf("give either -manifest or track files, not both")
+	case *manifestPath != "":
+		var err error
+		if manifest, err = bot.LoadReplayManifest(*manifestPath); err != nil {
+			return err
+		}
+	case flags.NArg() > 0:
+		manifest = &bot.ReplayManifest{}
+		for _, arg := range flags.Args() {
+			path, name, _ := strings.Cut(arg, "=")
+			manifest.Tracks = append(manifest.Tracks, bot.ReplayTrack{
+				Path:    path,
+				UserID:  name,
+				UserTag: name,
+			})
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *sessionID != "" {
+		manifest.SessionID = *sessionID
+	}
+	if *guildID != "" {
+		manifest.GuildID = *guildID
+	}
+
+	cfg, err := config.LoadOffline()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	setupLogging(cfg.LogLevel)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	metadata, err := bot.Replay(ctx, cfg, manifest)
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("session_id", metadata.SessionID).
+		Str("transcript_path", metadata.TranscriptPath).
+		Str("notes_path", metadata.NotesPath).
+		Msg("Replay complete")
+
+	return nil
+}
+
+// runCaptureReplay replays a raw voice capture through a voice session.
+func runCaptureReplay(path, sessionID string, speed float64) error {
+	if speed < 0 {
+		return fmt.Errorf("-speed must not be negative")
+	}
+
+	cfg, err := config.LoadOffline()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	setupLogging(cfg.LogLevel)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	metadata, err := bot.ReplayCaptureFile(ctx, cfg, path, sessionID, speed)
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("session_id", metadata.SessionID).
+		Str("transcript_path", metadata.TranscriptPath).
+		Str("notes_path", metadata.NotesPath).
+		Msg("Capture replay complete")
+
+	return nil
+}
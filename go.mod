@@ -3,12 +3,15 @@ module github.com/user/discord-notetaker
 go 1.21
 
 require (
+	github.com/alphacep/vosk-api/go v0.3.50
 	github.com/bwmarrin/discordgo v0.27.1
 	github.com/google/generative-ai-go v0.5.0
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/joho/godotenv v1.5.1
 	github.com/maxhawkins/go-webrtcvad v0.0.0-20210121163624-be60036f3083
 	github.com/rs/zerolog v1.32.0
+	golang.org/x/sync v0.6.0
 	google.golang.org/api v0.149.0
 	layeh.com/gopus v0.0.0-20210501142526-1ee02d434e32
 )
@@ -23,14 +26,12 @@ require (
 	github.com/google/s2a-go v0.1.7 // indirect
 	github.com/googleapis/enterprise-certificate-proxy v0.3.2 // indirect
 	github.com/googleapis/gax-go/v2 v2.12.0 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
 	go.opencensus.io v0.24.0 // indirect
 	golang.org/x/crypto v0.14.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/oauth2 v0.13.0 // indirect
-	golang.org/x/sync v0.6.0 // indirect
 	golang.org/x/sys v0.13.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
@@ -0,0 +1,205 @@
+// Package assembler turns the utterances transcribed from individual audio
+// chunks into a clean transcript.
+package assembler
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+const (
+	// overlapTolerance allows for the small timing differences between two
+	// chunks' transcriptions of the same audio
+	overlapTolerance = 150 * time.Millisecond
+
+	// maxWordsPerSecond bounds how many words text alignment may match in
+	// an overlap; even fast speech stays under this
+	maxWordsPerSecond = 4
+
+	// minFragment is the shortest partial word matched against a whole one,
+	// where a chunk boundary cut through a word
+	minFragment = 3
+)
+
+// DedupeOverlaps removes the words transcribed twice where consecutive
+// chunks of a speaker overlap. The later chunk's copy is dropped: by word
+// timings when both utterances have them, otherwise by aligning the end of
+// the earlier text with the start of the later one. utterances must be in
+// start time order, and so is the result; utterances left empty are dropped.
+func DedupeOverlaps(utterances []audio.Utterance) []audio.Utterance {
+	result := make([]audio.Utterance, 0, len(utterances))
+	recent := make(map[string][]int) // Speaker -> indexes in result that may overlap what follows
+
+	for _, utt := range utterances {
+		var overlapping []int
+		for _, i := range recent[utt.UserID] {
+			if result[i].TSEnd.Add(overlapTolerance).After(utt.TSStart) {
+				overlapping = append(overlapping, i)
+			}
+		}
+
+		for _, i := range overlapping {
+			utt = reconcile(result[i], utt)
+		}
+		if strings.TrimSpace(utt.Text) == "" {
+			continue
+		}
+
+		result = append(result, utt)
+		recent[utt.UserID] = append(overlapping, len(result)-1)
+	}
+
+	// Trimmed utterances start later than they did
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TSStart.Before(result[j].TSStart)
+	})
+	return result
+}
+
+// reconcile drops the start of next where it repeats the end of prev.
+func reconcile(prev, next audio.Utterance) audio.Utterance {
+	if len(prev.Words) > 0 && len(next.Words) > 0 {
+		return dropTimedDuplicates(prev, next)
+	}
+	return dropAlignedText(prev, next)
+}
+
+// dropTimedDuplicates removes the words of next that cover the same audio
+// as a word of prev.
+func dropTimedDuplicates(prev, next audio.Utterance) audio.Utterance {
+	horizon := prev.TSEnd.Add(overlapTolerance)
+
+	kept := make([]audio.Word, 0, len(next.Words))
+	for _, word := range next.Words {
+		if word.Start.Before(horizon) && duplicatesAny(word, prev.Words) {
+			continue
+		}
+		kept = append(kept, word)
+	}
+
+	if len(kept) == len(next.Words) {
+		return next
+	}
+
+	next.Words = kept
+	if len(kept) == 0 {
+		next.Text = ""
+		return next
+	}
+
+	texts := make([]string, len(kept))
+	for i, word := range kept {
+		texts[i] = word.Text
+	}
+	next.Text = strings.Join(texts, " ")
+	next.TSStart = kept[0].Start
+	return next
+}
+
+// duplicatesAny reports whether word is another transcription of one of
+// words: most of the shorter of the two overlaps the other, or the same word
+// starts at about the same time.
+func duplicatesAny(word audio.Word, words []audio.Word) bool {
+	for _, other := range words {
+		if similarTokens(normalize(word.Text), normalize(other.Text)) && absDuration(word.Start.Sub(other.Start)) <= overlapTolerance {
+			return true
+		}
+
+		shorter := word.End.Sub(word.Start)
+		if d := other.End.Sub(other.Start); d < shorter {
+			shorter = d
+		}
+		overlap := earlier(word.End, other.End).Sub(later(word.Start, other.Start))
+		if shorter > 0 && overlap >= shorter/2 {
+			return true
+		}
+	}
+	return false
+}
+
+// dropAlignedText removes the longest run of words at the start of next
+// that repeats the end of prev, for backends without word timings. The run
+// is bounded by how many words fit in the time the two overlap.
+func dropAlignedText(prev, next audio.Utterance) audio.Utterance {
+	overlap := prev.TSEnd.Sub(next.TSStart) + overlapTolerance
+	limit := int(overlap.Seconds()*maxWordsPerSecond) + 1
+
+	prevTokens := strings.Fields(prev.Text)
+	nextTokens := strings.Fields(next.Text)
+	limit = min(limit, len(prevTokens), len(nextTokens))
+
+	for n := limit; n > 0; n-- {
+		if !alignedRun(prevTokens[len(prevTokens)-n:], nextTokens[:n]) {
+			continue
+		}
+
+		// Without timings, assume words are evenly spread
+		duration := next.TSEnd.Sub(next.TSStart)
+		next.TSStart = next.TSStart.Add(duration * time.Duration(n) / time.Duration(len(nextTokens)))
+		next.Text = strings.Join(nextTokens[n:], " ")
+		return next
+	}
+	return next
+}
+
+// alignedRun reports whether the end of one chunk's text and the start of
+// the next say the same words. The next chunk may start partway through the
+// first word and the earlier one may end partway through the last.
+func alignedRun(tail, head []string) bool {
+	for i := range tail {
+		a, b := normalize(tail[i]), normalize(head[i])
+		switch {
+		case a == b:
+		case i == 0 && len(b) >= minFragment && strings.HasSuffix(a, b):
+		case i == len(tail)-1 && len(a) >= minFragment && strings.HasPrefix(b, a):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// similarTokens reports whether two normalized words are the same, or one
+// is a fragment of the other cut off at a chunk boundary.
+func similarTokens(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len(a) < minFragment || len(b) < minFragment {
+		return false
+	}
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a) ||
+		strings.HasSuffix(a, b) || strings.HasSuffix(b, a)
+}
+
+// normalize lowercases a word and strips surrounding punctuation.
+func normalize(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}))
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,131 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+var base = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func at(seconds float64) time.Time {
+	return base.Add(time.Duration(seconds * float64(time.Second)))
+}
+
+// untimed is an utterance from a backend without word timings.
+func untimed(user, text string, start, end float64) audio.Utterance {
+	return audio.Utterance{UserID: user, Text: text, TSStart: at(start), TSEnd: at(end), Source: "test"}
+}
+
+// timed is an utterance whose words are given as "text@start-end".
+func timed(user string, words ...string) audio.Utterance {
+	utt := audio.Utterance{UserID: user, Source: "test"}
+	var texts []string
+	for _, spec := range words {
+		var text string
+		var start, end float64
+		if _, err := fmt.Sscanf(strings.Replace(spec, "@", " ", 1), "%s %f-%f", &text, &start, &end); err != nil {
+			panic(fmt.Sprintf("bad word %q: %v", spec, err))
+		}
+		utt.Words = append(utt.Words, audio.Word{Text: text, Start: at(start), End: at(end)})
+		texts = append(texts, text)
+	}
+	utt.Text = strings.Join(texts, " ")
+	utt.TSStart = utt.Words[0].Start
+	utt.TSEnd = utt.Words[len(utt.Words)-1].End
+	return utt
+}
+
+// describe renders utterances as "user@start: text", one per line.
+func describe(utterances []audio.Utterance) string {
+	lines := make([]string, len(utterances))
+	for i, utt := range utterances {
+		lines[i] = fmt.Sprintf("%s@%.2f: %s", utt.UserID, utt.TSStart.Sub(base).Seconds(), utt.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestDedupeOverlaps(t *testing.T) {
+	tests := []struct {
+		name       string
+		utterances []audio.Utterance
+		want       string
+	}{
+		{
+			name: "chunks that don't overlap are kept",
+			utterances: []audio.Utterance{
+				untimed("alice", "good morning", 0, 1),
+				untimed("alice", "shall we start", 2, 3),
+			},
+			want: "alice@0.00: good morning\nalice@2.00: shall we start",
+		},
+		{
+			name: "timed words repeated in the overlap are dropped",
+			utterances: []audio.Utterance{
+				timed("alice", "hello@0-0.4", "there@0.5-0.9", "general@1.0-1.4"),
+				timed("alice", "general@1.02-1.42", "kenobi@1.5-1.9"),
+			},
+			want: "alice@0.00: hello there general\nalice@1.50: kenobi",
+		},
+		{
+			name: "a word cut by the chunk boundary is dropped",
+			utterances: []audio.Utterance{
+				timed("alice", "the@0-0.2", "deployment@0.3-1.0"),
+				timed("alice", "ment@0.7-1.0", "failed@1.1-1.5"),
+			},
+			want: "alice@0.00: the deployment\nalice@1.10: failed",
+		},
+		{
+			name: "untimed text is aligned and its start moved",
+			utterances: []audio.Utterance{
+				untimed("alice", "we should ship it", 0, 2),
+				untimed("alice", "ship it on friday", 1.5, 3.5),
+			},
+			want: "alice@0.00: we should ship it\nalice@2.50: on friday",
+		},
+		{
+			name: "untimed text ending part way through a word",
+			utterances: []audio.Utterance{
+				untimed("alice", "see you tomorr", 0, 2),
+				untimed("alice", "tomorrow morning", 1.8, 3),
+			},
+			want: "alice@0.00: see you tomorr\nalice@2.40: morning",
+		},
+		{
+			name: "other speakers' overlapping speech is kept",
+			utterances: []audio.Utterance{
+				untimed("alice", "ship it", 0, 2),
+				untimed("bob", "ship it", 1.5, 2.5),
+			},
+			want: "alice@0.00: ship it\nbob@1.50: ship it",
+		},
+		{
+			name: "an utterance repeated entirely is dropped",
+			utterances: []audio.Utterance{
+				timed("alice", "yes@0-0.3", "please@0.4-0.8"),
+				timed("alice", "please@0.41-0.8"),
+				untimed("bob", "ok", 1, 1.5),
+			},
+			want: "alice@0.00: yes please\nbob@1.00: ok",
+		},
+		{
+			name: "trimmed utterances are put back in order",
+			utterances: []audio.Utterance{
+				untimed("alice", "one two three four", 0, 2),
+				untimed("alice", "three four five six", 1, 3),
+				untimed("bob", "hmm", 1.2, 1.4),
+			},
+			want: "alice@0.00: one two three four\nbob@1.20: hmm\nalice@2.00: five six",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describe(DedupeOverlaps(tt.utterances)); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
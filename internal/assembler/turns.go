@@ -0,0 +1,119 @@
+package assembler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// Options controls how utterances are merged into speaker turns.
+type Options struct {
+	MaxGap      time.Duration // A pause this long always ends a turn
+	SentenceGap time.Duration // A pause this long ends a turn after a finished sentence
+	MaxTurn     time.Duration // Longer turns are split at the next sentence end
+}
+
+// DefaultOptions keeps a speaker's sentences together through ordinary
+// pauses for breath, and starts a new turn when they stop to think.
+func DefaultOptions() Options {
+	return Options{
+		MaxGap:      2 * time.Second,
+		SentenceGap: 700 * time.Millisecond,
+		MaxTurn:     60 * time.Second,
+	}
+}
+
+// Assemble merges consecutive utterances from the same speaker into turns,
+// so sentences cut up by chunk boundaries read as one. A turn ends when
+// someone else speaks, at a long pause, or at a shorter pause after a
+// sentence ends. utterances must be in start time order.
+//
+// Turns are utterances too: the first fragment's ID and start, the last
+// one's end, the joined text and words, and the duration-weighted mean
+// confidence. Fragments from different backends are never merged, so each
+// turn's source stays accurate.
+func Assemble(utterances []audio.Utterance, opts Options) []audio.Utterance {
+	defaults := DefaultOptions()
+	if opts.MaxGap <= 0 {
+		opts.MaxGap = defaults.MaxGap
+	}
+	if opts.SentenceGap <= 0 || opts.SentenceGap > opts.MaxGap {
+		opts.SentenceGap = min(defaults.SentenceGap, opts.MaxGap)
+	}
+	if opts.MaxTurn <= 0 {
+		opts.MaxTurn = defaults.MaxTurn
+	}
+
+	turns := make([]audio.Utterance, 0, len(utterances))
+	var weights []time.Duration // Duration behind each turn's confidence
+
+	for _, utt := range utterances {
+		if strings.TrimSpace(utt.Text) == "" {
+			continue
+		}
+
+		n := len(turns)
+		if n == 0 || !continues(turns[n-1], utt, opts) {
+			utt.Words = append([]audio.Word(nil), utt.Words...)
+			turns = append(turns, utt)
+			weights = append(weights, confidenceWeight(utt))
+			continue
+		}
+
+		turn := &turns[n-1]
+		turn.Text = strings.TrimSpace(turn.Text) + " " + strings.TrimSpace(utt.Text)
+		turn.Words = append(turn.Words, utt.Words...)
+		if utt.TSEnd.After(turn.TSEnd) {
+			turn.TSEnd = utt.TSEnd
+		}
+
+		if weight := confidenceWeight(utt); utt.Confidence > 0 && weight > 0 {
+			total := weights[n-1] + weight
+			turn.Confidence = (turn.Confidence*float64(weights[n-1]) + utt.Confidence*float64(weight)) / float64(total)
+			weights[n-1] = total
+		}
+	}
+
+	return turns
+}
+
+// continues reports whether utt belongs to the same turn as turn.
+func continues(turn, utt audio.Utterance, opts Options) bool {
+	if utt.UserID != turn.UserID || utt.Source != turn.Source {
+		return false
+	}
+
+	gap := utt.TSStart.Sub(turn.TSEnd)
+	if gap >= opts.MaxGap {
+		return false
+	}
+
+	if endsSentence(turn.Text) {
+		if gap >= opts.SentenceGap || utt.TSEnd.Sub(turn.TSStart) > opts.MaxTurn {
+			return false
+		}
+	}
+
+	return true
+}
+
+// endsSentence reports whether text ends with sentence punctuation. Backends
+// without punctuation never do, so only pauses split their turns.
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), `"')]`)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "!") ||
+		strings.HasSuffix(text, "?") || strings.HasSuffix(text, "…")
+}
+
+// confidenceWeight is how much an utterance counts towards its turn's
+// confidence, zero when the backend gave none.
+func confidenceWeight(utt audio.Utterance) time.Duration {
+	if utt.Confidence <= 0 {
+		return 0
+	}
+	if d := utt.TSEnd.Sub(utt.TSStart); d > 0 {
+		return d
+	}
+	return time.Millisecond
+}
@@ -0,0 +1,125 @@
+package assembler
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+func TestAssemble(t *testing.T) {
+	fromBackend := func(utt audio.Utterance, source string) audio.Utterance {
+		utt.Source = source
+		return utt
+	}
+
+	tests := []struct {
+		name       string
+		utterances []audio.Utterance
+		opts       Options
+		want       string
+	}{
+		{
+			name: "fragments of a sentence are joined",
+			utterances: []audio.Utterance{
+				untimed("alice", "so what I think", 0, 1),
+				untimed("alice", "we should do is", 1.3, 2),
+				untimed("alice", "ship on friday.", 2.2, 3),
+			},
+			want: "alice@0.00: so what I think we should do is ship on friday.",
+		},
+		{
+			name: "another speaker ends the turn",
+			utterances: []audio.Utterance{
+				untimed("alice", "so what I think", 0, 1),
+				untimed("bob", "yes", 1.1, 1.3),
+				untimed("alice", "is that we ship", 1.4, 2),
+			},
+			want: "alice@0.00: so what I think\nbob@1.10: yes\nalice@1.40: is that we ship",
+		},
+		{
+			name: "a long pause ends the turn",
+			utterances: []audio.Utterance{
+				untimed("alice", "let me check", 0, 1),
+				untimed("alice", "right it's green", 3, 4),
+			},
+			want: "alice@0.00: let me check\nalice@3.00: right it's green",
+		},
+		{
+			name: "a shorter pause ends the turn after a sentence",
+			utterances: []audio.Utterance{
+				untimed("alice", "That's done.", 0, 1),
+				untimed("alice", "Next item.", 1.8, 2.5),
+				untimed("alice", "Any questions?", 2.7, 3.5),
+			},
+			want: "alice@0.00: That's done.\nalice@1.80: Next item. Any questions?",
+		},
+		{
+			name: "long turns are split at a sentence end",
+			opts: Options{MaxTurn: 5 * time.Second},
+			utterances: []audio.Utterance{
+				untimed("alice", "First point.", 0, 3),
+				untimed("alice", "Second point.", 3.2, 6),
+				untimed("alice", "and more", 6.2, 7),
+			},
+			want: "alice@0.00: First point.\nalice@3.20: Second point. and more",
+		},
+		{
+			name: "different backends are never merged",
+			utterances: []audio.Utterance{
+				untimed("alice", "from the stream", 0, 1),
+				fromBackend(untimed("alice", "from a retry", 1.1, 2), "vosk"),
+			},
+			want: "alice@0.00: from the stream\nalice@1.10: from a retry",
+		},
+		{
+			name: "empty utterances are skipped",
+			utterances: []audio.Utterance{
+				untimed("alice", "hello", 0, 1),
+				untimed("alice", "  ", 1.1, 1.5),
+				untimed("alice", "again", 1.6, 2),
+			},
+			want: "alice@0.00: hello again",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describe(Assemble(tt.utterances, tt.opts)); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembleTurnDetails(t *testing.T) {
+	first := timed("alice", "hello@0-0.5", "there@0.5-1")
+	first.ID = uuid.New()
+	first.Confidence = 0.9
+	second := timed("alice", "general@1.2-2", "kenobi@2-4")
+	second.ID = uuid.New()
+	second.Confidence = 0.5
+
+	turns := Assemble([]audio.Utterance{first, second}, DefaultOptions())
+	if len(turns) != 1 {
+		t.Fatalf("got %d turns, want 1", len(turns))
+	}
+	turn := turns[0]
+
+	if turn.ID != first.ID || !turn.TSStart.Equal(first.TSStart) || !turn.TSEnd.Equal(second.TSEnd) {
+		t.Errorf("turn spans %s-%s, want the first fragment's start to the last one's end", turn.TSStart, turn.TSEnd)
+	}
+	if len(turn.Words) != 4 {
+		t.Errorf("got %d words, want 4", len(turn.Words))
+	}
+	// Weighted by duration: 1s at 0.9 and 2.8s at 0.5
+	if want := (0.9*1 + 0.5*2.8) / 3.8; math.Abs(turn.Confidence-want) > 1e-9 {
+		t.Errorf("confidence = %v, want %v", turn.Confidence, want)
+	}
+
+	// The input's word slices aren't shared with the turn
+	if len(first.Words) != 2 {
+		t.Errorf("Assemble changed its input's words")
+	}
+}
@@ -0,0 +1,39 @@
+package audio
+
+import "time"
+
+// anchorTolerance is how far a block's timestamp may stray from where the
+// previous block ended before it counts as a new run. It absorbs packet
+// timing jitter, which is well under half a frame.
+const anchorTolerance = 10 * time.Millisecond
+
+// anchorBuilder collects a chunk's anchors as its audio is appended.
+type anchorBuilder struct {
+	sampleRate int
+	samples    int       // Samples appended so far
+	next       time.Time // Expected timestamp of the next contiguous block
+	anchors    []TimeAnchor
+}
+
+func newAnchorBuilder(sampleRate int) *anchorBuilder {
+	return &anchorBuilder{sampleRate: sampleRate}
+}
+
+// add appends a block of samples captured at timestamp, starting a new
+// anchor when it doesn't follow on from the previous block.
+func (b *anchorBuilder) add(timestamp time.Time, samples int) {
+	drift := timestamp.Sub(b.next)
+	if drift < 0 {
+		drift = -drift
+	}
+	if len(b.anchors) == 0 || drift > anchorTolerance {
+		b.anchors = append(b.anchors, TimeAnchor{Offset: b.duration(b.samples), At: timestamp})
+	}
+
+	b.samples += samples
+	b.next = timestamp.Add(b.duration(samples))
+}
+
+func (b *anchorBuilder) duration(samples int) time.Duration {
+	return time.Duration(samples) * time.Second / time.Duration(b.sampleRate)
+}
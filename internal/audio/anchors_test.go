@@ -0,0 +1,84 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+// addFrames adds frames of 20ms starting at at.
+func addFrames(c Chunker, at time.Time, frames int) {
+	pcm := make([]int16, FrameSize)
+	for i := 0; i < frames; i++ {
+		c.AddSamples(pcm, at.Add(time.Duration(i)*20*time.Millisecond), []string{"alice"})
+	}
+}
+
+func TestChunkersAnchorRunsAcrossPauses(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		chunker Chunker
+	}{
+		// 200ms is shorter than MinChunk, so it's merged into the next utterance
+		{"endpoint", NewEndpointChunker(EndpointOptions{MinChunk: 500 * time.Millisecond})},
+		{"ring", NewRingChunker(1, 0, SampleRate)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addFrames(tt.chunker, start, 10)
+			addFrames(tt.chunker, start.Add(5*time.Second), 40)
+			tt.chunker.Stop()
+
+			chunk, ok := <-tt.chunker.GetChunk()
+			if !ok {
+				t.Fatal("no chunk emitted")
+			}
+
+			want := []TimeAnchor{
+				{Offset: 0, At: start},
+				{Offset: 200 * time.Millisecond, At: start.Add(5 * time.Second)},
+			}
+			if len(chunk.Anchors) != len(want) {
+				t.Fatalf("got anchors %+v, want %+v", chunk.Anchors, want)
+			}
+			for i := range want {
+				if chunk.Anchors[i].Offset != want[i].Offset || !chunk.Anchors[i].At.Equal(want[i].At) {
+					t.Errorf("anchor %d = %+v, want %+v", i, chunk.Anchors[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChunkTimeAt(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	chunk := &Chunk{
+		Start: start,
+		Anchors: []TimeAnchor{
+			{Offset: 0, At: start},
+			{Offset: time.Second, At: start.Add(10 * time.Second)},
+		},
+	}
+
+	tests := []struct {
+		offset time.Duration
+		want   time.Time
+	}{
+		{0, start},
+		{500 * time.Millisecond, start.Add(500 * time.Millisecond)},
+		{time.Second, start.Add(10 * time.Second)},
+		{1500 * time.Millisecond, start.Add(10*time.Second + 500*time.Millisecond)},
+	}
+	for _, tt := range tests {
+		if got := chunk.TimeAt(tt.offset); !got.Equal(tt.want) {
+			t.Errorf("TimeAt(%s) = %s, want %s", tt.offset, got, tt.want)
+		}
+	}
+
+	// Without anchors the audio is contiguous from Start
+	plain := &Chunk{Start: start}
+	if got := plain.TimeAt(time.Second); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("TimeAt without anchors = %s, want %s", got, start.Add(time.Second))
+	}
+}
@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Archive formats
+const (
+	ArchiveNone = "none"
+	ArchiveWAV  = "wav" // Decoded PCM
+	ArchiveOgg  = "ogg" // Original Opus packets, not re-encoded
+)
+
+// silentFrame pads WAV archives across gaps one frame at a time, so a long
+// mute never allocates the whole gap at once.
+var silentFrame = make([]int16, FrameSize)
+
+// SpeakerArchive records one speaker's audio to disk, aligned to a common
+// session start so tracks from different speakers line up. Gaps between
+// frames are filled with silence.
+type SpeakerArchive struct {
+	path   string
+	format string
+	start  time.Time
+	file   *os.File
+	wav    *WAVWriter
+	ogg    *OggOpusWriter
+}
+
+// NewSpeakerArchive creates an archive file in the given format whose
+// timeline begins at start.
+func NewSpeakerArchive(path, format string, start time.Time) (*SpeakerArchive, error) {
+	if format != ArchiveWAV && format != ArchiveOgg {
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive file: %w", err)
+	}
+
+	archive := &SpeakerArchive{
+		path:   path,
+		format: format,
+		start:  start,
+		file:   file,
+	}
+
+	if format == ArchiveWAV {
+		archive.wav, err = NewWAVWriter(file, SampleRate)
+	} else {
+		archive.ogg, err = NewOggOpusWriter(file, SampleRate)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// Path returns the archive file path.
+func (a *SpeakerArchive) Path() string {
+	return a.path
+}
+
+// Format returns the archive format.
+func (a *SpeakerArchive) Format() string {
+	return a.format
+}
+
+// WriteFrame records a 20ms frame starting at timestamp. WAV archives store
+// the decoded pcm; Ogg archives store the original opus packet, or silence
+// when it is nil (e.g. a concealed lost frame).
+func (a *SpeakerArchive) WriteFrame(opus []byte, pcm []int16, timestamp time.Time) error {
+	offset := int(timestamp.Sub(a.start) * SampleRate / time.Second)
+
+	if a.wav != nil {
+		if gap := offset - a.wav.Samples(); gap > FrameSize/2 {
+			for ; gap > 0; gap -= FrameSize {
+				if err := a.wav.Write(silentFrame[:min(gap, FrameSize)]); err != nil {
+					return err
+				}
+			}
+		}
+		return a.wav.Write(pcm)
+	}
+
+	// Ogg Opus has no way to express gaps, so pad with silence packets
+	for offset-int(a.ogg.Granule()) > FrameSize/2 {
+		if err := a.ogg.WritePacket(OpusSilenceFrame, FrameSize); err != nil {
+			return err
+		}
+	}
+
+	if opus == nil {
+		opus = OpusSilenceFrame
+	}
+	return a.ogg.WritePacket(opus, FrameSize)
+}
+
+// Close finalises the container and closes the file.
+func (a *SpeakerArchive) Close() error {
+	var err error
+	if a.wav != nil {
+		err = a.wav.Close()
+	} else {
+		err = a.ogg.Close()
+	}
+
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
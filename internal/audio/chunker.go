@@ -22,8 +22,8 @@ type RingChunker struct {
 	lastChunkTime time.Time
 
 	chunkChan chan *Chunk
-	stopChan  chan struct{}
 	stopped   bool
+	lost      []LostChunk // Chunks that found the channel full
 	mutex     sync.RWMutex
 }
 
@@ -44,7 +44,6 @@ func NewRingChunker(chunkSeconds, overlapMS, sampleRate int) *RingChunker {
 		timestamps:     make([]time.Time, bufferSize),
 		speakers:       make([][]string, bufferSize),
 		chunkChan:      make(chan *Chunk, 10),
-		stopChan:       make(chan struct{}),
 	}
 }
 
@@ -113,11 +112,13 @@ func (c *RingChunker) createChunk() {
 	}
 
 	chunk := &Chunk{
-		ID:       uuid.New(),
-		PCM:      chunkPCM,
-		Start:    startTime,
-		End:      endTime,
-		Speakers: speakerList,
+		ID:         uuid.New(),
+		PCM:        chunkPCM,
+		SampleRate: c.sampleRate,
+		Start:      startTime,
+		End:        endTime,
+		Speakers:   speakerList,
+		Anchors:    c.anchors(c.chunkSamples),
 	}
 
 	select {
@@ -129,10 +130,13 @@ func (c *RingChunker) createChunk() {
 			Strs("speakers", chunk.Speakers).
 			Int("samples", len(chunk.PCM)).
 			Msg("Created audio chunk")
-	case <-c.stopChan:
-		return
 	default:
-		log.Warn().Msg("Chunk channel full, dropping chunk")
+		// Chunks are added on the packet loop, so never wait for room
+		log.Warn().
+			Str("chunk_id", chunk.ID.String()).
+			Time("start", chunk.Start).
+			Msg("Chunk channel full, giving up on chunk")
+		c.lose(chunk)
 	}
 
 	c.lastChunkTime = endTime
@@ -163,6 +167,35 @@ func (c *RingChunker) slideBuffer() {
 	}
 }
 
+// anchors finds where each contiguous run of audio starts in the first n
+// buffered samples.
+func (c *RingChunker) anchors(n int) []TimeAnchor {
+	builder := newAnchorBuilder(c.sampleRate)
+	for _, timestamp := range c.timestamps[:n] {
+		builder.add(timestamp, 1)
+	}
+	return builder.anchors
+}
+
+// lose records a chunk that never made it into the channel.
+func (c *RingChunker) lose(chunk *Chunk) {
+	c.lost = append(c.lost, LostChunk{
+		Start:    chunk.Start,
+		End:      chunk.End,
+		Speakers: chunk.Speakers,
+		Reason:   "chunk queue full",
+	})
+}
+
+func (c *RingChunker) Lost() []LostChunk {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	lost := make([]LostChunk, len(c.lost))
+	copy(lost, c.lost)
+	return lost
+}
+
 func (c *RingChunker) GetChunk() <-chan *Chunk {
 	return c.chunkChan
 }
@@ -176,7 +209,6 @@ func (c *RingChunker) Stop() {
 	}
 
 	c.stopped = true
-	close(c.stopChan)
 
 	// Create final chunk if there's remaining data
 	if c.bufferPos > c.overlapSamples {
@@ -201,11 +233,13 @@ func (c *RingChunker) Stop() {
 		}
 
 		finalChunk := &Chunk{
-			ID:       uuid.New(),
-			PCM:      finalPCM,
-			Start:    startTime,
-			End:      endTime,
-			Speakers: speakerList,
+			ID:         uuid.New(),
+			PCM:        finalPCM,
+			SampleRate: c.sampleRate,
+			Start:      startTime,
+			End:        endTime,
+			Speakers:   speakerList,
+			Anchors:    c.anchors(c.bufferPos),
 		}
 
 		select {
@@ -215,6 +249,7 @@ func (c *RingChunker) Stop() {
 				Msg("Created final audio chunk")
 		default:
 			log.Warn().Msg("Could not send final chunk")
+			c.lose(finalChunk)
 		}
 	}
 
@@ -0,0 +1,113 @@
+package audio
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxClockLag is how far behind the arrival time an RTP-derived
+	// timestamp may fall before the clock is re-anchored.
+	DefaultMaxClockLag = time.Second
+
+	// maxSequenceJump is the largest forward sequence number jump still
+	// treated as packet loss rather than a stream reset.
+	maxSequenceJump = 3000
+)
+
+// RTPClock maps the RTP timestamps of a single SSRC onto wall-clock time.
+//
+// The clock is anchored on the arrival time of the first packet. Since
+// network delay can only make packets late, never early, the anchor is moved
+// back whenever a packet arrives earlier than its RTP timestamp predicts, so
+// it converges on the least-delayed packet. This keeps speakers aligned on a
+// common session timeline regardless of jitter and bursts.
+type RTPClock struct {
+	sampleRate int
+	maxLag     time.Duration
+
+	anchorTime time.Time
+	anchorTS   int64 // Extended RTP timestamp at anchorTime
+	extTS      int64 // Extended (unwrapped) timestamp of the last packet
+	lastTS     uint32
+	lastSeq    uint16
+	started    bool
+
+	mutex sync.Mutex
+}
+
+// NewRTPClock creates a clock for an RTP stream with the given sample rate.
+func NewRTPClock(sampleRate int, maxLag time.Duration) *RTPClock {
+	if maxLag <= 0 {
+		maxLag = DefaultMaxClockLag
+	}
+
+	return &RTPClock{
+		sampleRate: sampleRate,
+		maxLag:     maxLag,
+	}
+}
+
+// Timestamp returns the wall-clock time of the first sample of a packet.
+func (c *RTPClock) Timestamp(rtpTS uint32, seq uint16, arrival time.Time) time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.started {
+		c.reset(rtpTS, seq, arrival)
+		return arrival
+	}
+
+	// A large jump in sequence numbers means the sender restarted the stream
+	seqDelta := int16(seq - c.lastSeq)
+	if seqDelta > maxSequenceJump || seqDelta < -maxSequenceJump {
+		c.reset(rtpTS, seq, arrival)
+		return arrival
+	}
+
+	// Unwrap the 32-bit timestamp so wraparound doesn't break the timeline
+	ts := c.extTS + int64(int32(rtpTS-c.lastTS))
+	if seqDelta > 0 {
+		c.extTS = ts
+		c.lastTS = rtpTS
+		c.lastSeq = seq
+	}
+
+	at := c.timeAt(ts)
+
+	switch {
+	case at.After(arrival):
+		// Packet arrived earlier than predicted, so the anchor was too late
+		c.anchorTime = c.anchorTime.Add(arrival.Sub(at))
+		at = arrival
+	case arrival.Sub(at) > c.maxLag:
+		// The sender's clock stalled (e.g. paused during silence)
+		c.anchorTime = arrival
+		c.anchorTS = ts
+		at = arrival
+	}
+
+	return at
+}
+
+// Reset forgets the current anchor, e.g. when the SSRC is reassigned.
+func (c *RTPClock) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.started = false
+}
+
+func (c *RTPClock) reset(rtpTS uint32, seq uint16, arrival time.Time) {
+	c.started = true
+	c.anchorTime = arrival
+	c.anchorTS = 0
+	c.extTS = 0
+	c.lastTS = rtpTS
+	c.lastSeq = seq
+}
+
+func (c *RTPClock) timeAt(ts int64) time.Time {
+	offset := time.Duration(ts-c.anchorTS) * time.Second / time.Duration(c.sampleRate)
+	return c.anchorTime.Add(offset)
+}
@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTPClock(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	ms := time.Millisecond
+
+	type packet struct {
+		ts      uint32
+		seq     uint16
+		arrival time.Duration // After start
+		want    time.Duration // After start
+	}
+	tests := []struct {
+		name    string
+		packets []packet
+	}{
+		{
+			name: "steady stream follows RTP time",
+			packets: []packet{
+				{0, 1, 0, 0},
+				{960, 2, 20 * ms, 20 * ms},
+				{1920, 3, 40 * ms, 40 * ms},
+			},
+		},
+		{
+			name: "jitter doesn't move late packets",
+			packets: []packet{
+				{0, 1, 0, 0},
+				{960, 2, 35 * ms, 20 * ms},
+				{1920, 3, 41 * ms, 40 * ms},
+			},
+		},
+		{
+			name: "early packet moves the anchor back",
+			packets: []packet{
+				{0, 1, 30 * ms, 30 * ms},
+				{960, 2, 40 * ms, 40 * ms},
+				{1920, 3, 60 * ms, 60 * ms},
+			},
+		},
+		{
+			name: "stalled sender clock re-anchors on arrival",
+			packets: []packet{
+				{0, 1, 0, 0},
+				{960, 2, 5 * time.Second, 5 * time.Second},
+				{1920, 3, 5*time.Second + 20*ms, 5*time.Second + 20*ms},
+			},
+		},
+		{
+			name: "timestamp wraparound",
+			packets: []packet{
+				{4294967296 - 960, 1, 0, 0},
+				{0, 2, 20 * ms, 20 * ms},
+				{960, 3, 40 * ms, 40 * ms},
+			},
+		},
+		{
+			name: "sequence jump restarts the stream",
+			packets: []packet{
+				{0, 1, 0, 0},
+				{123456, 20000, 100 * ms, 100 * ms},
+				{123456 + 960, 20001, 120 * ms, 120 * ms},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewRTPClock(SampleRate, DefaultMaxClockLag)
+			for i, p := range tt.packets {
+				got := clock.Timestamp(p.ts, p.seq, start.Add(p.arrival))
+				if want := start.Add(p.want); !got.Equal(want) {
+					t.Errorf("packet %d at %s, want %s", i, got.Sub(start), p.want)
+				}
+			}
+		})
+	}
+}
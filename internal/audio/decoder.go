@@ -10,6 +10,9 @@ const (
 	SampleRate = 48000
 	Channels   = 1   // Mono
 	FrameSize  = 960 // 20ms at 48kHz
+
+	// maxOpusPacket bounds the size of a single encoded Opus packet
+	maxOpusPacket = 4000
 )
 
 type OpusDecoder struct {
@@ -29,7 +32,7 @@ func NewOpusDecoder() (*OpusDecoder, error) {
 
 func (d *OpusDecoder) Decode(opus []byte) ([]int16, error) {
 	// Handle silence frames
-	if len(opus) == 3 && opus[0] == 0xF8 && opus[1] == 0xFF && opus[2] == 0xFE {
+	if isSilenceFrame(opus) {
 		// Return silence for comfort noise frames
 		return make([]int16, FrameSize), nil
 	}
@@ -42,6 +45,36 @@ func (d *OpusDecoder) Decode(opus []byte) ([]int16, error) {
 	return pcm, nil
 }
 
+// DecodeFEC recovers a lost frame from the forward error correction data
+// carried in the packet that followed it.
+func (d *OpusDecoder) DecodeFEC(next []byte) ([]int16, error) {
+	if len(next) == 0 || isSilenceFrame(next) {
+		return nil, fmt.Errorf("no FEC data in packet")
+	}
+
+	pcm, err := d.decoder.Decode(next, FrameSize, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode FEC data: %w", err)
+	}
+
+	return pcm, nil
+}
+
+// DecodePLC synthesises a replacement for a lost frame using Opus packet
+// loss concealment.
+func (d *OpusDecoder) DecodePLC() ([]int16, error) {
+	pcm, err := d.decoder.Decode(nil, FrameSize, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to conceal lost frame: %w", err)
+	}
+
+	return pcm, nil
+}
+
+func isSilenceFrame(opus []byte) bool {
+	return len(opus) == 3 && opus[0] == 0xF8 && opus[1] == 0xFF && opus[2] == 0xFE
+}
+
 func (d *OpusDecoder) Close() {
 	// gopus decoder doesn't require explicit cleanup
 }
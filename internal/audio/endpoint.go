@@ -0,0 +1,279 @@
+package audio
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// EndpointOptions configures an EndpointChunker.
+type EndpointOptions struct {
+	SampleRate int
+	MinChunk   time.Duration // Shorter utterances are merged into the next one
+	MaxChunk   time.Duration // Longer utterances are split at the quietest frame
+	Hangover   time.Duration // Pause length that ends an utterance
+}
+
+// DefaultEndpointOptions returns the default endpointing configuration.
+func DefaultEndpointOptions() EndpointOptions {
+	return EndpointOptions{
+		SampleRate: SampleRate,
+		MinChunk:   500 * time.Millisecond,
+		MaxChunk:   15 * time.Second,
+		Hangover:   600 * time.Millisecond,
+	}
+}
+
+// splitSearchWindow is how far back from MaxChunk the chunker looks for a
+// quiet frame to split an over-long utterance at.
+const splitSearchWindow = time.Second
+
+// endpointFrame is a block of speech samples added in one AddSamples call.
+type endpointFrame struct {
+	pcm       []int16
+	timestamp time.Time
+	speakers  []string
+}
+
+// EndpointChunker cuts audio into natural utterances at pauses in speech.
+//
+// It expects to receive only the frames the VAD classified as speech, so a
+// pause shows up either as a jump in timestamps between consecutive frames
+// or as no frames arriving at all for the hangover period.
+type EndpointChunker struct {
+	opts EndpointOptions
+
+	frames  []endpointFrame
+	samples int
+	lastEnd time.Time
+	idle    *time.Timer
+
+	chunkChan chan *Chunk
+	stopped   bool
+	lost      []LostChunk // Chunks that found the channel full
+	mutex     sync.Mutex
+}
+
+// NewEndpointChunker creates a chunker that ends chunks on pauses.
+func NewEndpointChunker(opts EndpointOptions) *EndpointChunker {
+	defaults := DefaultEndpointOptions()
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = defaults.SampleRate
+	}
+	if opts.MaxChunk <= 0 {
+		opts.MaxChunk = defaults.MaxChunk
+	}
+	if opts.Hangover <= 0 {
+		opts.Hangover = defaults.Hangover
+	}
+	if opts.MinChunk < 0 || opts.MinChunk > opts.MaxChunk {
+		opts.MinChunk = defaults.MinChunk
+	}
+
+	return &EndpointChunker{
+		opts:      opts,
+		chunkChan: make(chan *Chunk, 10),
+	}
+}
+
+func (c *EndpointChunker) AddSamples(pcm []int16, timestamp time.Time, speakers []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stopped || len(pcm) == 0 {
+		return
+	}
+
+	// A gap in the timeline means the speaker paused
+	if c.samples > 0 && timestamp.Sub(c.lastEnd) >= c.opts.Hangover {
+		c.endUtterance(false)
+	}
+
+	frame := endpointFrame{
+		pcm:       make([]int16, len(pcm)),
+		timestamp: timestamp,
+		speakers:  speakers,
+	}
+	copy(frame.pcm, pcm)
+
+	c.frames = append(c.frames, frame)
+	c.samples += len(pcm)
+	c.lastEnd = timestamp.Add(c.duration(len(pcm)))
+
+	if c.duration(c.samples) >= c.opts.MaxChunk {
+		c.splitAtQuietestFrame()
+	}
+
+	// No audio for the hangover period also ends the utterance
+	if c.idle == nil {
+		c.idle = time.AfterFunc(c.opts.Hangover, c.onIdle)
+	} else {
+		c.idle.Reset(c.opts.Hangover)
+	}
+}
+
+func (c *EndpointChunker) onIdle() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stopped || c.samples == 0 {
+		return
+	}
+
+	c.endUtterance(false)
+}
+
+// endUtterance emits the buffered frames as a chunk. Utterances shorter than
+// MinChunk are kept and merged into the next one unless force is set.
+func (c *EndpointChunker) endUtterance(force bool) {
+	if c.samples == 0 {
+		return
+	}
+
+	if !force && c.duration(c.samples) < c.opts.MinChunk {
+		return
+	}
+
+	c.emit(c.frames)
+	c.frames = nil
+	c.samples = 0
+}
+
+// splitAtQuietestFrame emits everything up to the lowest-energy frame in the
+// final second of the buffer, keeping the rest for the next chunk.
+func (c *EndpointChunker) splitAtQuietestFrame() {
+	windowSamples := int(splitSearchWindow.Seconds() * float64(c.opts.SampleRate))
+
+	split := len(c.frames)
+	quietest := math.MaxFloat64
+	remaining := 0
+	for i := len(c.frames) - 1; i > 0 && remaining < windowSamples; i-- {
+		remaining += len(c.frames[i].pcm)
+		if energy := frameRMS(c.frames[i].pcm); energy < quietest {
+			quietest = energy
+			split = i
+		}
+	}
+
+	c.emit(c.frames[:split])
+
+	rest := make([]endpointFrame, len(c.frames)-split)
+	copy(rest, c.frames[split:])
+	c.frames = rest
+	c.samples = 0
+	for _, frame := range c.frames {
+		c.samples += len(frame.pcm)
+	}
+}
+
+func (c *EndpointChunker) emit(frames []endpointFrame) {
+	if len(frames) == 0 {
+		return
+	}
+
+	total := 0
+	for _, frame := range frames {
+		total += len(frame.pcm)
+	}
+
+	pcm := make([]int16, 0, total)
+	anchors := newAnchorBuilder(c.opts.SampleRate)
+	speakerMap := make(map[string]struct{})
+	speakerList := make([]string, 0)
+	for _, frame := range frames {
+		pcm = append(pcm, frame.pcm...)
+		anchors.add(frame.timestamp, len(frame.pcm))
+		for _, speaker := range frame.speakers {
+			if _, seen := speakerMap[speaker]; !seen && speaker != "" {
+				speakerMap[speaker] = struct{}{}
+				speakerList = append(speakerList, speaker)
+			}
+		}
+	}
+
+	last := frames[len(frames)-1]
+	chunk := &Chunk{
+		ID:         uuid.New(),
+		PCM:        pcm,
+		SampleRate: c.opts.SampleRate,
+		Start:      frames[0].timestamp,
+		End:        last.timestamp.Add(c.duration(len(last.pcm))),
+		Speakers:   speakerList,
+		Anchors:    anchors.anchors,
+	}
+
+	select {
+	case c.chunkChan <- chunk:
+		log.Debug().
+			Str("chunk_id", chunk.ID.String()).
+			Time("start", chunk.Start).
+			Time("end", chunk.End).
+			Strs("speakers", chunk.Speakers).
+			Int("samples", len(chunk.PCM)).
+			Msg("Created endpointed audio chunk")
+	default:
+		// Chunks are added on the packet loop, so never wait for room
+		log.Warn().
+			Str("chunk_id", chunk.ID.String()).
+			Time("start", chunk.Start).
+			Msg("Chunk channel full, giving up on chunk")
+		c.lost = append(c.lost, LostChunk{
+			Start:    chunk.Start,
+			End:      chunk.End,
+			Speakers: chunk.Speakers,
+			Reason:   "chunk queue full",
+		})
+	}
+}
+
+func (c *EndpointChunker) duration(samples int) time.Duration {
+	return time.Duration(samples) * time.Second / time.Duration(c.opts.SampleRate)
+}
+
+func (c *EndpointChunker) Lost() []LostChunk {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	lost := make([]LostChunk, len(c.lost))
+	copy(lost, c.lost)
+	return lost
+}
+
+func (c *EndpointChunker) GetChunk() <-chan *Chunk {
+	return c.chunkChan
+}
+
+func (c *EndpointChunker) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stopped {
+		return
+	}
+
+	c.stopped = true
+	if c.idle != nil {
+		c.idle.Stop()
+	}
+
+	// Flush whatever is left, however short
+	c.endUtterance(true)
+
+	close(c.chunkChan)
+}
+
+func frameRMS(pcm []int16) float64 {
+	if len(pcm) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, sample := range pcm {
+		sum += float64(sample) * float64(sample)
+	}
+
+	return math.Sqrt(sum / float64(len(pcm)))
+}
@@ -0,0 +1,165 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// speechRun is a run of consecutive 20ms speech frames.
+type speechRun struct {
+	at     time.Duration // From the start of the test
+	frames int
+	quiet  int // Index of a quieter frame within the run, or -1
+}
+
+// describeChunks renders chunks as "start-end" in milliseconds from base.
+func describeChunks(chunks []*Chunk, base time.Time) string {
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = fmt.Sprintf("%d-%d", chunk.Start.Sub(base).Milliseconds(), chunk.End.Sub(base).Milliseconds())
+	}
+	return strings.Join(parts, " | ")
+}
+
+func TestEndpointChunker(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	loud := sine(200, SampleRate, FrameSize, 8000)
+	quiet := sine(200, SampleRate, FrameSize, 100)
+
+	tests := []struct {
+		name     string
+		maxChunk time.Duration
+		runs     []speechRun
+		want     string
+	}{
+		{
+			name: "a pause ends the utterance",
+			runs: []speechRun{{0, 50, -1}, {2 * time.Second, 50, -1}},
+			want: "0-1000 | 2000-3000",
+		},
+		{
+			name: "pauses shorter than the hangover don't",
+			runs: []speechRun{{0, 50, -1}, {1500 * time.Millisecond, 50, -1}},
+			want: "0-2500",
+		},
+		{
+			name: "an utterance shorter than MinChunk joins the next",
+			runs: []speechRun{{0, 10, -1}, {2 * time.Second, 50, -1}},
+			want: "0-3000",
+		},
+		{
+			name: "a short last utterance is flushed on stop",
+			runs: []speechRun{{0, 10, -1}},
+			want: "0-200",
+		},
+		{
+			name:     "MaxChunk splits at the quietest frame of the last second",
+			maxChunk: 2 * time.Second,
+			runs:     []speechRun{{0, 120, 80}},
+			want:     "0-1600 | 1600-2400",
+		},
+		{
+			name:     "MaxChunk splits late when the last second is evenly loud",
+			maxChunk: 2 * time.Second,
+			runs:     []speechRun{{0, 100, -1}},
+			want:     "0-1980 | 1980-2000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunker := NewEndpointChunker(EndpointOptions{
+				MinChunk: 500 * time.Millisecond,
+				MaxChunk: tt.maxChunk,
+				Hangover: 600 * time.Millisecond,
+			})
+
+			for _, run := range tt.runs {
+				for i := 0; i < run.frames; i++ {
+					pcm := loud
+					if i == run.quiet {
+						pcm = quiet
+					}
+					at := start.Add(run.at + time.Duration(i)*20*time.Millisecond)
+					chunker.AddSamples(pcm, at, []string{"alice"})
+				}
+			}
+			chunker.Stop()
+
+			var chunks []*Chunk
+			for chunk := range chunker.GetChunk() {
+				chunks = append(chunks, chunk)
+			}
+			if got := describeChunks(chunks, start); got != tt.want {
+				t.Errorf("chunks %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointChunkerIdleTimer(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	chunker := NewEndpointChunker(EndpointOptions{
+		MinChunk: 500 * time.Millisecond,
+		Hangover: 50 * time.Millisecond,
+	})
+	defer chunker.Stop()
+
+	// Too short to send on its own, so the idle timer keeps it
+	addFrames(chunker, start, 10)
+	select {
+	case chunk := <-chunker.GetChunk():
+		t.Fatalf("got chunk %s-%s before reaching MinChunk", chunk.Start, chunk.End)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// No frames arriving for the hangover ends the utterance, without
+	// waiting for a later frame to show the gap
+	addFrames(chunker, start.Add(200*time.Millisecond), 30)
+	select {
+	case chunk := <-chunker.GetChunk():
+		if got := describeChunks([]*Chunk{chunk}, start); got != "0-800" {
+			t.Errorf("chunk %q, want 0-800", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle timer never ended the utterance")
+	}
+}
+
+func TestNewChunkerFactory(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      ChunkerConfig
+		wantErr  bool
+		wantType string
+	}{
+		{name: "ring", cfg: ChunkerConfig{Strategy: ChunkStrategyRing, ChunkSeconds: 5, OverlapMS: 300}, wantType: "*audio.RingChunker"},
+		{name: "endpoint", cfg: ChunkerConfig{Strategy: ChunkStrategyEndpoint, MinChunk: time.Second, MaxChunk: 15 * time.Second, Hangover: time.Second}, wantType: "*audio.EndpointChunker"},
+		{name: "unknown strategy", cfg: ChunkerConfig{Strategy: "vibes"}, wantErr: true},
+		{name: "ring without a length", cfg: ChunkerConfig{Strategy: ChunkStrategyRing}, wantErr: true},
+		{name: "ring overlap as long as the chunk", cfg: ChunkerConfig{Strategy: ChunkStrategyRing, ChunkSeconds: 1, OverlapMS: 1000}, wantErr: true},
+		{name: "ring negative overlap", cfg: ChunkerConfig{Strategy: ChunkStrategyRing, ChunkSeconds: 1, OverlapMS: -1}, wantErr: true},
+		{name: "endpoint without a hangover", cfg: ChunkerConfig{Strategy: ChunkStrategyEndpoint, MaxChunk: time.Second}, wantErr: true},
+		{name: "endpoint min above max", cfg: ChunkerConfig{Strategy: ChunkStrategyEndpoint, MinChunk: 2 * time.Second, MaxChunk: time.Second, Hangover: time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory, err := NewChunkerFactory(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewChunkerFactory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			chunker := factory.NewChunker()
+			defer chunker.Stop()
+			if got := fmt.Sprintf("%T", chunker); got != tt.wantType {
+				t.Errorf("NewChunker() = %s, want %s", got, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"fmt"
+	"time"
+)
+
+// Chunking strategies
+const (
+	ChunkStrategyRing     = "ring"     // Fixed windows with overlap
+	ChunkStrategyEndpoint = "endpoint" // Cut on pauses in speech
+)
+
+// ChunkerConfig describes how per-speaker chunkers are built.
+type ChunkerConfig struct {
+	Strategy   string
+	SampleRate int
+
+	// Ring strategy
+	ChunkSeconds int
+	OverlapMS    int
+
+	// Endpoint strategy
+	MinChunk time.Duration
+	MaxChunk time.Duration
+	Hangover time.Duration
+}
+
+// ChunkerFactory creates a fresh Chunker for every speaker in a session.
+type ChunkerFactory interface {
+	NewChunker() Chunker
+}
+
+type configChunkerFactory struct {
+	cfg ChunkerConfig
+}
+
+// NewChunkerFactory validates cfg and returns a factory building chunkers
+// of the configured strategy.
+func NewChunkerFactory(cfg ChunkerConfig) (ChunkerFactory, error) {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = SampleRate
+	}
+
+	switch cfg.Strategy {
+	case ChunkStrategyRing:
+		if cfg.ChunkSeconds <= 0 {
+			return nil, fmt.Errorf("chunk seconds must be positive, got %d", cfg.ChunkSeconds)
+		}
+		if cfg.OverlapMS < 0 || cfg.OverlapMS >= cfg.ChunkSeconds*1000 {
+			return nil, fmt.Errorf("chunk overlap must be between 0 and the chunk length, got %dms", cfg.OverlapMS)
+		}
+	case ChunkStrategyEndpoint:
+		if cfg.MaxChunk <= 0 || cfg.Hangover <= 0 {
+			return nil, fmt.Errorf("endpoint max chunk and hangover must be positive")
+		}
+		if cfg.MinChunk > cfg.MaxChunk {
+			return nil, fmt.Errorf("endpoint min chunk %s exceeds max chunk %s", cfg.MinChunk, cfg.MaxChunk)
+		}
+	default:
+		return nil, fmt.Errorf("unknown chunk strategy %q", cfg.Strategy)
+	}
+
+	return &configChunkerFactory{cfg: cfg}, nil
+}
+
+func (f *configChunkerFactory) NewChunker() Chunker {
+	if f.cfg.Strategy == ChunkStrategyEndpoint {
+		return NewEndpointChunker(EndpointOptions{
+			SampleRate: f.cfg.SampleRate,
+			MinChunk:   f.cfg.MinChunk,
+			MaxChunk:   f.cfg.MaxChunk,
+			Hangover:   f.cfg.Hangover,
+		})
+	}
+
+	return NewRingChunker(f.cfg.ChunkSeconds, f.cfg.OverlapMS, f.cfg.SampleRate)
+}
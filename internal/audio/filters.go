@@ -0,0 +1,163 @@
+package audio
+
+import "math"
+
+// butterworthQ gives a maximally flat passband.
+const butterworthQ = 1 / math.Sqrt2
+
+// HighPassFilter removes rumble, handling noise and DC offset below the
+// cutoff with a second-order Butterworth biquad.
+type HighPassFilter struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	x1, x2     float64
+	y1, y2     float64
+}
+
+// NewHighPassFilter creates a high-pass filter at cutoff Hz.
+func NewHighPassFilter(cutoff float64, sampleRate int) *HighPassFilter {
+	w0 := 2 * math.Pi * cutoff / float64(sampleRate)
+	alpha := math.Sin(w0) / (2 * butterworthQ)
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+
+	return &HighPassFilter{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *HighPassFilter) Process(pcm []int16) {
+	for i, sample := range pcm {
+		x := float64(sample)
+		y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+
+		f.x2, f.x1 = f.x1, x
+		f.y2, f.y1 = f.y1, y
+
+		pcm[i] = clampInt16(y)
+	}
+}
+
+// NoiseGate mutes frames whose level is below a threshold. The gate opens
+// immediately and closes after a short hold so word endings aren't clipped,
+// with the gain ramped to avoid clicks.
+type NoiseGate struct {
+	threshold   float64 // Linear RMS
+	holdSamples int
+	held        int
+	gain        float64
+	rampStep    float64
+}
+
+const (
+	gateHold = 0.2  // Seconds the gate stays open after the level drops
+	gateRamp = 0.01 // Seconds to fade fully in or out
+)
+
+// NewNoiseGate creates a gate muting audio below thresholdDB dBFS.
+func NewNoiseGate(thresholdDB float64, sampleRate int) *NoiseGate {
+	return &NoiseGate{
+		threshold:   dbfsToRMS(thresholdDB),
+		holdSamples: int(gateHold * float64(sampleRate)),
+		rampStep:    1 / (gateRamp * float64(sampleRate)),
+	}
+}
+
+func (g *NoiseGate) Process(pcm []int16) {
+	if len(pcm) == 0 {
+		return
+	}
+
+	target := 0.0
+	if frameRMS(pcm) >= g.threshold {
+		g.held = 0
+		target = 1
+	} else if g.held < g.holdSamples {
+		g.held += len(pcm)
+		target = 1
+	}
+
+	for i, sample := range pcm {
+		switch {
+		case g.gain < target:
+			g.gain = math.Min(target, g.gain+g.rampStep)
+		case g.gain > target:
+			g.gain = math.Max(target, g.gain-g.rampStep)
+		}
+		pcm[i] = clampInt16(float64(sample) * g.gain)
+	}
+}
+
+// AGC normalises speech towards a target level. The gain only adapts on
+// frames loud enough to be speech, so pauses don't pump up background noise.
+type AGC struct {
+	target  float64 // Linear RMS
+	maxGain float64
+	floor   float64 // Linear RMS below which the gain is frozen
+	gain    float64
+	attack  float64 // Per-frame smoothing when reducing gain
+	release float64 // Per-frame smoothing when increasing gain
+}
+
+const (
+	agcFloorDB = -55.0
+	agcPeakDB  = -1.0 // Ceiling for peaks, leaving headroom below full scale
+	agcAttack  = 0.5  // Fast: loud bursts are tamed quickly
+	agcRelease = 0.05 // Slow: quiet passages are boosted gradually
+)
+
+// NewAGC creates an automatic gain control aiming at targetDB dBFS with at
+// most maxGainDB of boost.
+func NewAGC(targetDB, maxGainDB float64) *AGC {
+	return &AGC{
+		target:  dbfsToRMS(targetDB),
+		maxGain: math.Pow(10, maxGainDB/20),
+		floor:   dbfsToRMS(agcFloorDB),
+		gain:    1,
+		attack:  agcAttack,
+		release: agcRelease,
+	}
+}
+
+func (a *AGC) Process(pcm []int16) {
+	if len(pcm) == 0 {
+		return
+	}
+
+	start := a.gain
+	if rms := frameRMS(pcm); rms > a.floor {
+		desired := math.Min(a.target/rms, a.maxGain)
+		rate := a.release
+		if desired < a.gain {
+			rate = a.attack
+		}
+		a.gain += (desired - a.gain) * rate
+	}
+
+	// Never push a frame's peaks to full scale, e.g. a shout after a long
+	// quiet passage has raised the gain
+	var peak float64
+	for _, sample := range pcm {
+		peak = math.Max(peak, math.Abs(float64(sample)))
+	}
+	if peak > 0 {
+		limit := dbfsToRMS(agcPeakDB) / peak
+		start = math.Min(start, limit)
+		a.gain = math.Min(a.gain, limit)
+	}
+
+	// Interpolate across the frame so gain changes don't click
+	step := (a.gain - start) / float64(len(pcm))
+	for i, sample := range pcm {
+		pcm[i] = clampInt16(float64(sample) * (start + step*float64(i+1)))
+	}
+}
+
+// dbfsToRMS converts a level in dBFS to a linear 16-bit RMS value.
+func dbfsToRMS(db float64) float64 {
+	return math.MaxInt16 * math.Pow(10, db/20)
+}
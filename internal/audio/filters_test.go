@@ -0,0 +1,186 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// processFrames runs pcm through p one 20ms frame at a time, as the
+// pipeline does, and returns the processed copy.
+func processFrames(p Processor, pcm []int16) []int16 {
+	out := append([]int16(nil), pcm...)
+	for start := 0; start < len(out); start += FrameSize {
+		p.Process(out[start:min(start+FrameSize, len(out))])
+	}
+	return out
+}
+
+func TestHighPassFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []int16
+		minRatio float64 // Output RMS over input RMS once settled
+		maxRatio float64
+	}{
+		{"DC offset", constant(5000, SampleRate), 0, 0.01},
+		{"20Hz rumble", sine(20, SampleRate, SampleRate, 8000), 0, 0.1},
+		{"1kHz speech band", sine(1000, SampleRate, SampleRate, 8000), 0.95, 1.05},
+		{"300Hz voice fundamental", sine(300, SampleRate, SampleRate, 8000), 0.9, 1.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := processFrames(NewHighPassFilter(80, SampleRate), tt.input)
+
+			// Skip the first half second while the filter settles
+			settled := SampleRate / 2
+			ratio := frameRMS(out[settled:]) / frameRMS(tt.input[settled:])
+			if ratio < tt.minRatio || ratio > tt.maxRatio {
+				t.Errorf("output/input RMS = %.3f, want between %.2f and %.2f", ratio, tt.minRatio, tt.maxRatio)
+			}
+		})
+	}
+}
+
+func TestNoiseGate(t *testing.T) {
+	gate := NewNoiseGate(-50, SampleRate)
+
+	// -50 dBFS is an RMS of about 104
+	quiet := sine(440, SampleRate, FrameSize, 50)
+	loud := sine(440, SampleRate, FrameSize, 8000)
+
+	tests := []struct {
+		name     string
+		frame    []int16
+		frames   int
+		wantOpen bool // Checked on the last frame
+	}{
+		{"quiet start closes after the hold", quiet, 15, false},
+		{"speech opens the gate", loud, 2, true},
+		{"quiet within the hold passes", quiet, 5, true},
+		{"quiet past the hold is muted", quiet, 10, false},
+		{"speech reopens the gate", loud, 2, true},
+	}
+
+	for _, tt := range tests {
+		var out []int16
+		for i := 0; i < tt.frames; i++ {
+			out = append([]int16(nil), tt.frame...)
+			gate.Process(out)
+		}
+
+		got, in := frameRMS(out), frameRMS(tt.frame)
+		if tt.wantOpen && math.Abs(got-in) > in*0.01 {
+			t.Errorf("%s: output RMS %.1f, want the input's %.1f", tt.name, got, in)
+		}
+		if !tt.wantOpen && got != 0 {
+			t.Errorf("%s: output RMS %.1f, want silence", tt.name, got)
+		}
+	}
+}
+
+func TestAGC(t *testing.T) {
+	target := dbfsToRMS(-20)
+
+	tests := []struct {
+		name      string
+		amplitude float64
+		want      float64 // Settled output RMS
+	}{
+		{"quiet speech is raised", 500, target},
+		{"loud speech is lowered", 25000, target},
+		// -50 dBFS needs more than the 20 dB maximum boost
+		{"very quiet speech stops at the maximum gain", dbfsToRMS(-50) * math.Sqrt2, dbfsToRMS(-50) * 10},
+		// Below the floor the gain doesn't adapt at all
+		{"background noise is left alone", dbfsToRMS(-60) * math.Sqrt2, dbfsToRMS(-60)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := processFrames(NewAGC(-20, 20), sine(440, SampleRate, 10*SampleRate, tt.amplitude))
+
+			got := frameRMS(out[len(out)-FrameSize:])
+			if math.Abs(got-tt.want) > tt.want*0.05 {
+				t.Errorf("settled RMS = %.1f, want %.1f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAGCNeverClips(t *testing.T) {
+	agc := NewAGC(-20, 20)
+
+	// Seconds of quiet speech raise the gain to its maximum, then a shout
+	// arrives at nearly full scale
+	pcm := append(sine(440, SampleRate, 5*SampleRate, 300), sine(440, SampleRate, SampleRate, 30000)...)
+	out := processFrames(agc, pcm)
+
+	for i, sample := range out {
+		if sample == math.MaxInt16 || sample == math.MinInt16 {
+			t.Fatalf("sample %d clipped at %d", i, sample)
+		}
+	}
+
+	// The shout still ends up near the target
+	if got, want := frameRMS(out[len(out)-FrameSize:]), dbfsToRMS(-20); math.Abs(got-want) > want*0.05 {
+		t.Errorf("settled RMS after the shout = %.1f, want %.1f", got, want)
+	}
+}
+
+func TestNewProcessorFactory(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*ProcessorConfig)
+		wantErr bool
+	}{
+		{name: "no stages", modify: func(*ProcessorConfig) {}},
+		{name: "all stages", modify: func(c *ProcessorConfig) { c.Stages = ParseStages(" HighPass, gate ,agc,") }},
+		{name: "unknown stage", modify: func(c *ProcessorConfig) { c.Stages = []string{"reverb"} }, wantErr: true},
+		{name: "cutoff above Nyquist", modify: func(c *ProcessorConfig) {
+			c.Stages = []string{ProcessorHighPass}
+			c.HighPassCutoff = 30000
+		}, wantErr: true},
+		{name: "gate above full scale", modify: func(c *ProcessorConfig) {
+			c.Stages = []string{ProcessorGate}
+			c.GateThresholdDB = 3
+		}, wantErr: true},
+		{name: "negative AGC gain", modify: func(c *ProcessorConfig) {
+			c.Stages = []string{ProcessorAGC}
+			c.AGCMaxGainDB = -1
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultProcessorConfig()
+			tt.modify(&cfg)
+
+			factory, err := NewProcessorFactory(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewProcessorFactory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if chain := factory.NewProcessor().(Chain); len(chain) != len(cfg.Stages) {
+				t.Errorf("chain has %d processors, want %d", len(chain), len(cfg.Stages))
+			}
+		})
+	}
+}
+
+func TestEmptyChainLeavesAudioUntouched(t *testing.T) {
+	factory, err := NewProcessorFactory(DefaultProcessorConfig())
+	if err != nil {
+		t.Fatalf("NewProcessorFactory failed: %v", err)
+	}
+
+	pcm := sine(440, SampleRate, FrameSize, 8000)
+	out := append([]int16(nil), pcm...)
+	factory.NewProcessor().Process(out)
+	for i := range pcm {
+		if out[i] != pcm[i] {
+			t.Fatalf("sample %d changed from %d to %d", i, pcm[i], out[i])
+		}
+	}
+}
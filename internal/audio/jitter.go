@@ -0,0 +1,232 @@
+package audio
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJitterDepth is the number of packets held back to absorb
+	// reordering before a missing packet is declared lost (60ms).
+	DefaultJitterDepth = 3
+
+	// DefaultMaxConcealFrames caps how many consecutive lost frames are
+	// synthesised. Longer gaps are skipped rather than filled with PLC noise.
+	DefaultMaxConcealFrames = 5
+)
+
+// RTPPacket is a single received Opus packet with its RTP header fields.
+type RTPPacket struct {
+	Sequence  uint16
+	Timestamp uint32
+	Opus      []byte
+	Arrival   time.Time
+}
+
+// JitterFrame is a frame released by the JitterBuffer in sequence order.
+// Lost frames have no payload; FEC holds the next packet's payload when it
+// was already buffered, so the frame can be recovered with in-band FEC.
+type JitterFrame struct {
+	RTPPacket
+	Lost bool
+	FEC  []byte
+}
+
+// JitterStats reports per-stream packet statistics.
+type JitterStats struct {
+	Received   int `json:"received"`
+	Lost       int `json:"lost"`
+	Recovered  int `json:"recovered"` // Lost frames rebuilt from FEC
+	Concealed  int `json:"concealed"` // Lost frames synthesised with PLC
+	Skipped    int `json:"skipped"`   // Lost frames beyond the concealment cap
+	Late       int `json:"late"`      // Arrived after their slot was released
+	Duplicates int `json:"duplicates"`
+	Reordered  int `json:"reordered"`
+}
+
+// LossRate returns the fraction of expected packets that never arrived.
+func (s JitterStats) LossRate() float64 {
+	expected := s.Received + s.Lost
+	if expected == 0 {
+		return 0
+	}
+	return float64(s.Lost) / float64(expected)
+}
+
+// JitterBuffer reorders the packets of a single SSRC by RTP sequence number
+// and detects gaps.
+type JitterBuffer struct {
+	depth      int
+	maxConceal int
+	packets    map[uint16]RTPPacket
+	nextSeq    uint16
+	highestSeq uint16
+	lastTS     uint32
+	started    bool
+	stats      JitterStats
+	mutex      sync.Mutex
+}
+
+// NewJitterBuffer creates a buffer that holds back up to depth packets.
+func NewJitterBuffer(depth, maxConceal int) *JitterBuffer {
+	if depth < 1 {
+		depth = DefaultJitterDepth
+	}
+	if maxConceal < 0 {
+		maxConceal = DefaultMaxConcealFrames
+	}
+
+	return &JitterBuffer{
+		depth:      depth,
+		maxConceal: maxConceal,
+		packets:    make(map[uint16]RTPPacket),
+	}
+}
+
+// Push adds a packet and returns the frames that are ready for decoding,
+// in sequence order.
+func (j *JitterBuffer) Push(packet RTPPacket) []JitterFrame {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	var frames []JitterFrame
+
+	// A large jump in sequence numbers means the sender restarted the stream
+	if j.started {
+		delta := int16(packet.Sequence - j.highestSeq)
+		if delta > maxSequenceJump || delta < -maxSequenceJump {
+			frames = j.release(true)
+			j.started = false
+		}
+	}
+
+	if !j.started {
+		j.started = true
+		j.nextSeq = packet.Sequence
+		j.highestSeq = packet.Sequence
+		j.lastTS = packet.Timestamp - FrameSize
+	}
+
+	if seqBefore(packet.Sequence, j.nextSeq) {
+		j.stats.Late++
+		return frames
+	}
+
+	if _, exists := j.packets[packet.Sequence]; exists {
+		j.stats.Duplicates++
+		return frames
+	}
+
+	if seqBefore(packet.Sequence, j.highestSeq) {
+		j.stats.Reordered++
+	} else {
+		j.highestSeq = packet.Sequence
+	}
+
+	j.stats.Received++
+	j.packets[packet.Sequence] = packet
+
+	return append(frames, j.release(false)...)
+}
+
+// Flush releases every buffered packet, concealing any remaining gaps.
+func (j *JitterBuffer) Flush() []JitterFrame {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.release(true)
+}
+
+// Stats returns a snapshot of the buffer's packet statistics.
+func (j *JitterBuffer) Stats() JitterStats {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.stats
+}
+
+// RecordRecovery tallies how a lost frame was eventually decoded.
+func (j *JitterBuffer) RecordRecovery(fec bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if fec {
+		j.stats.Recovered++
+	} else {
+		j.stats.Concealed++
+	}
+}
+
+func (j *JitterBuffer) release(flush bool) []JitterFrame {
+	var frames []JitterFrame
+
+	for len(j.packets) > 0 {
+		if packet, ok := j.packets[j.nextSeq]; ok {
+			delete(j.packets, j.nextSeq)
+			frames = append(frames, JitterFrame{RTPPacket: packet})
+			j.lastTS = packet.Timestamp
+			j.nextSeq++
+			continue
+		}
+
+		// Wait for the missing packet until enough later ones are queued
+		if !flush && len(j.packets) < j.depth {
+			break
+		}
+
+		frames = append(frames, j.skipGap()...)
+	}
+
+	return frames
+}
+
+// skipGap declares the packets between nextSeq and the earliest buffered
+// packet lost, emitting at most maxConceal placeholder frames.
+func (j *JitterBuffer) skipGap() []JitterFrame {
+	earliest := j.nextSeq
+	first := true
+	for seq := range j.packets {
+		if first || seqBefore(seq, earliest) {
+			earliest = seq
+			first = false
+		}
+	}
+
+	gap := int(earliest - j.nextSeq)
+	j.stats.Lost += gap
+
+	next := j.packets[earliest]
+	var frames []JitterFrame
+	for i := 0; i < gap; i++ {
+		if i >= j.maxConceal {
+			j.stats.Skipped += gap - i
+			break
+		}
+
+		j.lastTS += FrameSize
+		frame := JitterFrame{
+			RTPPacket: RTPPacket{
+				Sequence:  j.nextSeq + uint16(i),
+				Timestamp: j.lastTS,
+				Arrival:   next.Arrival,
+			},
+			Lost: true,
+		}
+
+		// Only the frame directly before a received packet has FEC data
+		if i == gap-1 {
+			frame.FEC = next.Opus
+		}
+
+		frames = append(frames, frame)
+	}
+
+	j.nextSeq = earliest
+	return frames
+}
+
+// seqBefore reports whether sequence number a precedes b, allowing for
+// 16-bit wraparound.
+func seqBefore(a, b uint16) bool {
+	return int16(a-b) < 0
+}
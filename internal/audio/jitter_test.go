@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// describeFrames renders released frames as their sequence numbers, with
+// lost frames marked "?" and those recoverable with FEC marked "?fec".
+func describeFrames(frames []JitterFrame) string {
+	parts := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		part := fmt.Sprint(frame.Sequence)
+		if frame.Lost {
+			part += "?"
+			if frame.FEC != nil {
+				part += "fec"
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestJitterBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		sequences []uint16
+		want      string // Frames released by the pushes, then the flush
+		stats     JitterStats
+	}{
+		{
+			name:      "in order",
+			sequences: []uint16{1, 2, 3},
+			want:      "1 2 3",
+			stats:     JitterStats{Received: 3},
+		},
+		{
+			name:      "reordered within the depth",
+			sequences: []uint16{1, 3, 2, 4},
+			want:      "1 2 3 4",
+			stats:     JitterStats{Received: 4, Reordered: 1},
+		},
+		{
+			name:      "lost packet recovered with the next packet's FEC",
+			sequences: []uint16{1, 3, 4, 5},
+			want:      "1 2?fec 3 4 5",
+			stats:     JitterStats{Received: 4, Lost: 1},
+		},
+		{
+			name:      "duplicate of a held packet",
+			sequences: []uint16{1, 3, 3, 2},
+			want:      "1 2 3",
+			stats:     JitterStats{Received: 3, Duplicates: 1, Reordered: 1},
+		},
+		{
+			name:      "duplicate of a released packet is late",
+			sequences: []uint16{1, 1, 2},
+			want:      "1 2",
+			stats:     JitterStats{Received: 2, Late: 1},
+		},
+		{
+			name:      "late after its slot was given up",
+			sequences: []uint16{1, 3, 4, 5, 2},
+			want:      "1 2?fec 3 4 5",
+			stats:     JitterStats{Received: 4, Lost: 1, Late: 1},
+		},
+		{
+			name:      "long gap only partly concealed",
+			sequences: []uint16{1, 10, 11, 12},
+			want:      "1 2? 3? 4? 5? 6? 10 11 12",
+			stats:     JitterStats{Received: 4, Lost: 8, Skipped: 3},
+		},
+		{
+			name:      "sequence wraparound",
+			sequences: []uint16{65534, 65535, 0, 1},
+			want:      "65534 65535 0 1",
+			stats:     JitterStats{Received: 4},
+		},
+		{
+			name:      "gap left at the end is concealed by the flush",
+			sequences: []uint16{1, 3},
+			want:      "1 2?fec 3",
+			stats:     JitterStats{Received: 2, Lost: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := NewJitterBuffer(DefaultJitterDepth, DefaultMaxConcealFrames)
+			arrival := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+			var frames []JitterFrame
+			for _, seq := range tt.sequences {
+				frames = append(frames, j.Push(RTPPacket{
+					Sequence:  seq,
+					Timestamp: uint32(seq) * FrameSize,
+					Opus:      []byte{byte(seq)},
+					Arrival:   arrival,
+				})...)
+			}
+			frames = append(frames, j.Flush()...)
+
+			if got := describeFrames(frames); got != tt.want {
+				t.Errorf("released %q, want %q", got, tt.want)
+			}
+			if got := j.Stats(); got != tt.stats {
+				t.Errorf("stats = %+v, want %+v", got, tt.stats)
+			}
+		})
+	}
+}
+
+func TestJitterStatsLossRate(t *testing.T) {
+	tests := []struct {
+		stats JitterStats
+		want  float64
+	}{
+		{JitterStats{}, 0},
+		{JitterStats{Received: 9, Lost: 1}, 0.1},
+		{JitterStats{Received: 0, Lost: 4}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.LossRate(); got != tt.want {
+			t.Errorf("LossRate(%+v) = %v, want %v", tt.stats, got, tt.want)
+		}
+	}
+}
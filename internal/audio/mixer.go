@@ -0,0 +1,286 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"layeh.com/gopus"
+)
+
+const (
+	// mixHeadroom is the peak level the limiter allows, just under full scale
+	mixHeadroom = 0.95 * math.MaxInt16
+	// mixRelease is the per-frame recovery of the limiter gain towards unity
+	mixRelease = 0.02
+	// mixOpusBitrate is the Opus bitrate for Ogg mixdowns; plenty for speech
+	mixOpusBitrate = 32000
+	// mixBufferSamples is how much of the timeline is mixed in memory before
+	// the oldest part is written to the scratch file
+	mixBufferSamples = 10 * SampleRate
+	// mixKeepSamples stays in memory after a flush, for speakers whose audio
+	// arrives a little behind the others
+	mixKeepSamples = 2 * SampleRate
+)
+
+// Mixer sums every speaker's audio onto a single session timeline. Samples
+// are accumulated as int32, so overlapping speech can't wrap around before
+// the final render applies clipping protection. The recent part of the
+// timeline is mixed in memory and written to a scratch file in blocks, so
+// long meetings don't have to fit in memory.
+type Mixer struct {
+	file    *os.File
+	start   time.Time
+	samples int     // Length of the timeline so far
+	base    int     // Timeline offset of buffer[0]; earlier samples are in the file
+	buffer  []int32 // Recent samples not yet written to the file
+	mutex   sync.Mutex
+}
+
+// NewMixer creates a mixer whose timeline begins at start, using
+// scratchPath for the accumulated samples.
+func NewMixer(scratchPath string, start time.Time) (*Mixer, error) {
+	file, err := os.Create(scratchPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mix scratch file: %w", err)
+	}
+
+	return &Mixer{
+		file:  file,
+		start: start,
+	}, nil
+}
+
+// Add mixes 48kHz PCM into the timeline at timestamp. Safe for concurrent
+// use by several speakers.
+func (m *Mixer) Add(pcm []int16, timestamp time.Time) error {
+	offset := int(timestamp.Sub(m.start) * SampleRate / time.Second)
+	if offset < 0 {
+		// Audio from before the session started
+		if -offset >= len(pcm) {
+			return nil
+		}
+		pcm = pcm[-offset:]
+		offset = 0
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	end := offset + len(pcm)
+	if end > m.samples {
+		m.samples = end
+	}
+
+	// Audio for a part of the timeline already flushed is mixed into the
+	// file directly
+	if offset < m.base {
+		n := min(len(pcm), m.base-offset)
+		if err := m.addToFile(pcm[:n], offset); err != nil {
+			return err
+		}
+		pcm = pcm[n:]
+		offset += n
+	}
+	if len(pcm) == 0 {
+		return nil
+	}
+
+	if end-m.base > mixBufferSamples {
+		if err := m.flush(min(offset, end-mixKeepSamples)); err != nil {
+			return err
+		}
+	}
+
+	if need := end - m.base; need > len(m.buffer) {
+		m.buffer = append(m.buffer, make([]int32, need-len(m.buffer))...)
+	}
+	for i, sample := range pcm {
+		m.buffer[offset-m.base+i] += int32(sample)
+	}
+	return nil
+}
+
+// flush writes the buffered timeline before offset to the scratch file.
+func (m *Mixer) flush(offset int) error {
+	n := min(max(offset-m.base, 0), len(m.buffer))
+	if n > 0 {
+		buf := make([]byte, n*4)
+		for i, sample := range m.buffer[:n] {
+			binary.LittleEndian.PutUint32(buf[i*4:], uint32(sample))
+		}
+		if _, err := m.file.WriteAt(buf, int64(m.base)*4); err != nil {
+			return fmt.Errorf("failed to write mix: %w", err)
+		}
+
+		m.buffer = m.buffer[:copy(m.buffer, m.buffer[n:])]
+	}
+
+	// Silence past the buffer was never stored; the file reads it as zeros
+	m.base = max(m.base+n, offset)
+	return nil
+}
+
+// addToFile mixes pcm into samples already written to the scratch file.
+func (m *Mixer) addToFile(pcm []int16, offset int) error {
+	buf := make([]byte, len(pcm)*4)
+	if _, err := m.file.ReadAt(buf, int64(offset)*4); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read mix: %w", err)
+	}
+
+	for i, sample := range pcm {
+		sum := int32(binary.LittleEndian.Uint32(buf[i*4:])) + int32(sample)
+		binary.LittleEndian.PutUint32(buf[i*4:], uint32(sum))
+	}
+
+	if _, err := m.file.WriteAt(buf, int64(offset)*4); err != nil {
+		return fmt.Errorf("failed to write mix: %w", err)
+	}
+	return nil
+}
+
+// Duration returns the length of the mixed timeline.
+func (m *Mixer) Duration() time.Duration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return time.Duration(m.samples) * time.Second / SampleRate
+}
+
+// Render writes the mixed timeline to path as a WAV or Ogg Opus file,
+// limiting peaks so overlapping speakers don't clip.
+func (m *Mixer) Render(path, format string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.flush(m.samples); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create recording file: %w", err)
+	}
+	defer file.Close()
+
+	var write func(pcm []int16) error
+	var finish func() error
+
+	switch format {
+	case ArchiveWAV:
+		wav, err := NewWAVWriter(file, SampleRate)
+		if err != nil {
+			return err
+		}
+		write, finish = wav.Write, wav.Close
+	case ArchiveOgg:
+		encoder, err := gopus.NewEncoder(SampleRate, Channels, gopus.Voip)
+		if err != nil {
+			return fmt.Errorf("failed to create opus encoder: %w", err)
+		}
+		encoder.SetBitrate(mixOpusBitrate)
+
+		ogg, err := NewOggOpusWriter(file, SampleRate)
+		if err != nil {
+			return err
+		}
+		write = func(pcm []int16) error {
+			packet, err := encoder.Encode(pcm, FrameSize, maxOpusPacket)
+			if err != nil {
+				return fmt.Errorf("failed to encode opus: %w", err)
+			}
+			return ogg.WritePacket(packet, FrameSize)
+		}
+		finish = ogg.Close
+	default:
+		return fmt.Errorf("unsupported recording format %q", format)
+	}
+
+	limiter := &mixLimiter{gain: 1}
+	buf := make([]byte, FrameSize*4)
+	mix := make([]int32, FrameSize)
+	out := make([]int16, FrameSize)
+
+	for offset := 0; offset < m.samples; offset += FrameSize {
+		n := FrameSize
+		if remaining := m.samples - offset; remaining < n {
+			// Pad the final frame with silence; Opus needs whole frames
+			n = remaining
+			clear(buf)
+		}
+
+		if _, err := m.file.ReadAt(buf[:n*4], int64(offset)*4); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read mix: %w", err)
+		}
+		for i := range mix {
+			mix[i] = int32(binary.LittleEndian.Uint32(buf[i*4:]))
+		}
+
+		limiter.process(mix, out)
+
+		frame := out
+		if format == ArchiveWAV {
+			frame = out[:n]
+		}
+		if err := write(frame); err != nil {
+			return err
+		}
+	}
+
+	if err := finish(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Close removes the scratch file.
+func (m *Mixer) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.file.Close()
+	if err := os.Remove(m.file.Name()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove mix scratch file: %w", err)
+	}
+	return nil
+}
+
+// mixLimiter scales summed audio back into 16-bit range. The gain drops
+// immediately when a frame would clip and recovers slowly afterwards, so
+// crosstalk is tamed without pumping.
+type mixLimiter struct {
+	gain float64
+}
+
+func (l *mixLimiter) process(mix []int32, out []int16) {
+	var peak int32
+	for _, sample := range mix {
+		if sample < 0 {
+			sample = -sample
+		}
+		if sample > peak {
+			peak = sample
+		}
+	}
+
+	start := l.gain
+	if limit := mixHeadroom / float64(peak); float64(peak)*l.gain > mixHeadroom {
+		// Attack: apply the reduced gain to the whole frame
+		l.gain = limit
+		start = limit
+	} else {
+		l.gain = math.Min(1, l.gain+(1-l.gain)*mixRelease)
+		if float64(peak)*l.gain > mixHeadroom {
+			l.gain = limit
+		}
+	}
+
+	step := (l.gain - start) / float64(len(mix))
+	for i, sample := range mix {
+		out[i] = clampInt16(float64(sample) * (start + step*float64(i+1)))
+	}
+}
@@ -0,0 +1,202 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// renderWAV renders a mixer's timeline and reads it back.
+func renderWAV(t *testing.T, m *Mixer) []int16 {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mix.wav")
+	if err := m.Render(path, ArchiveWAV); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	pcm, _, err := ReadWAV(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pcm
+}
+
+func constant(value int16, samples int) []int16 {
+	pcm := make([]int16, samples)
+	for i := range pcm {
+		pcm[i] = value
+	}
+	return pcm
+}
+
+func TestMixer(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	// Offsets are whole milliseconds, so they map to whole samples
+	at := func(samples int) time.Time {
+		return start.Add(time.Duration(samples/48) * time.Millisecond)
+	}
+
+	type add struct {
+		offset int // Samples from the start; a multiple of 48
+		value  int16
+		length int
+	}
+	tests := []struct {
+		name  string
+		adds  []add
+		check map[int]int16 // Sample offset -> expected value
+		total int
+	}{
+		{
+			name:  "speakers overlapping are summed",
+			adds:  []add{{0, 100, 960}, {480, 200, 960}},
+			check: map[int]int16{0: 100, 479: 100, 480: 300, 959: 300, 960: 200, 1439: 200},
+			total: 1440,
+		},
+		{
+			name:  "gaps are silent",
+			adds:  []add{{0, 100, 960}, {48000, 100, 960}},
+			check: map[int]int16{959: 100, 960: 0, 47999: 0, 48000: 100},
+			total: 48960,
+		},
+		{
+			name: "late audio for a flushed part of the timeline",
+			adds: []add{
+				{0, 100, 960},
+				// Far enough ahead to flush the start to the scratch file
+				{20 * SampleRate, 100, 960},
+				{480, 50, 960},
+			},
+			check: map[int]int16{0: 100, 480: 150, 1000: 50, 20 * SampleRate: 100},
+			total: 20*SampleRate + 960,
+		},
+		{
+			name:  "audio from before the start is cut",
+			adds:  []add{{-480, 100, 960}},
+			check: map[int]int16{0: 100, 479: 100},
+			total: 480,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMixer(filepath.Join(t.TempDir(), "mix.pcm"), start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			for _, a := range tt.adds {
+				timestamp := at(a.offset)
+				if a.offset < 0 {
+					timestamp = start.Add(-time.Duration(-a.offset/48) * time.Millisecond)
+				}
+				if err := m.Add(constant(a.value, a.length), timestamp); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if want := time.Duration(tt.total) * time.Second / SampleRate; m.Duration() != want {
+				t.Errorf("Duration() = %s, want %s", m.Duration(), want)
+			}
+
+			pcm := renderWAV(t, m)
+			if len(pcm) != tt.total {
+				t.Fatalf("rendered %d samples, want %d", len(pcm), tt.total)
+			}
+			for offset, want := range tt.check {
+				if pcm[offset] != want {
+					t.Errorf("sample %d = %d, want %d", offset, pcm[offset], want)
+				}
+			}
+		})
+	}
+}
+
+func TestMixerLimitsClipping(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	m, err := NewMixer(filepath.Join(t.TempDir(), "mix.pcm"), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// Three loud speakers at once would overflow 16 bits
+	for i := 0; i < 3; i++ {
+		if err := m.Add(constant(30000, FrameSize), start); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, sample := range renderWAV(t, m) {
+		if sample <= 0 {
+			t.Fatalf("sample %d = %d; the sum wrapped around instead of being limited", i, sample)
+		}
+	}
+}
+
+// TestMixerRenderFormats renders the same timeline as WAV and Ogg Opus and
+// replays both as tracks, as the offline replay does.
+func TestMixerRenderFormats(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	m, err := NewMixer(filepath.Join(t.TempDir(), "mix.pcm"), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// Half a second of tone, ending part way through a frame
+	tone := sine(440, SampleRate, SampleRate/2+100, 8000)
+	if err := m.Add(tone, start); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, format := range []string{ArchiveWAV, ArchiveOgg} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(dir, "mix."+format)
+			if err := m.Render(path, format); err != nil {
+				t.Fatal(err)
+			}
+
+			track, err := OpenTrack(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer track.Close()
+
+			decoder, err := NewOpusDecoder()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer decoder.Close()
+
+			var decoded []int16
+			for {
+				packet, err := track.ReadPacket()
+				if err != nil {
+					break
+				}
+				pcm, err := decoder.Decode(packet)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded = append(decoded, pcm...)
+			}
+
+			// Both round up to whole 20ms frames
+			if want := (len(tone)/FrameSize + 1) * FrameSize; len(decoded) != want {
+				t.Errorf("decoded %d samples, want %d", len(decoded), want)
+			}
+			// Opus is lossy, but the tone should come back at about its level
+			if got, want := rms(decoded[:len(tone)], FrameSize*2), rms(tone, FrameSize*2); got < want*0.8 || got > want*1.2 {
+				t.Errorf("decoded level %.0f, want about %.0f", got, want)
+			}
+		})
+	}
+}
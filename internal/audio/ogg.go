@@ -0,0 +1,277 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// OpusSilenceFrame is a 20ms Opus packet that decodes to silence.
+var OpusSilenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+const (
+	oggHeaderContinued = 0x01
+	oggHeaderBOS       = 0x02
+	oggHeaderEOS       = 0x04
+
+	// oggPagePackets is how many 20ms packets are grouped per page (1s)
+	oggPagePackets = 50
+	oggMaxSegments = 255
+)
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func oggCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// OggOpusWriter muxes Opus packets into an Ogg Opus stream without
+// re-encoding them.
+type OggOpusWriter struct {
+	w        io.Writer
+	serial   uint32
+	pageSeq  uint32
+	granule  int64
+	segments []byte
+	data     []byte
+	packets  int
+}
+
+// NewOggOpusWriter writes the Opus identification and comment headers for a
+// mono stream at sampleRate and returns a writer.
+func NewOggOpusWriter(w io.Writer, sampleRate int) (*OggOpusWriter, error) {
+	o := &OggOpusWriter{
+		w:      w,
+		serial: rand.Uint32(),
+	}
+
+	head := make([]byte, 19)
+	copy(head[0:8], "OpusHead")
+	head[8] = 1                                                    // Version
+	head[9] = 1                                                    // Channels
+	binary.LittleEndian.PutUint16(head[10:12], 0)                  // Pre-skip
+	binary.LittleEndian.PutUint32(head[12:16], uint32(sampleRate)) // Input sample rate
+	binary.LittleEndian.PutUint16(head[16:18], 0)                  // Output gain
+	head[18] = 0                                                   // Channel mapping family
+
+	if err := o.writePage(oggHeaderBOS, 0, lacing(len(head)), head); err != nil {
+		return nil, fmt.Errorf("failed to write OpusHead: %w", err)
+	}
+
+	vendor := "discord-notetaker"
+	tags := make([]byte, 8+4+len(vendor)+4)
+	copy(tags[0:8], "OpusTags")
+	binary.LittleEndian.PutUint32(tags[8:12], uint32(len(vendor)))
+	copy(tags[12:], vendor)
+	binary.LittleEndian.PutUint32(tags[12+len(vendor):], 0) // No user comments
+
+	if err := o.writePage(0, 0, lacing(len(tags)), tags); err != nil {
+		return nil, fmt.Errorf("failed to write OpusTags: %w", err)
+	}
+
+	return o, nil
+}
+
+// WritePacket appends an Opus packet decoding to the given number of
+// samples at 48kHz.
+func (o *OggOpusWriter) WritePacket(packet []byte, samples int) error {
+	segments := lacing(len(packet))
+	if len(o.segments)+len(segments) > oggMaxSegments {
+		if err := o.flush(0); err != nil {
+			return err
+		}
+	}
+
+	o.segments = append(o.segments, segments...)
+	o.data = append(o.data, packet...)
+	o.granule += int64(samples)
+	o.packets++
+
+	if o.packets >= oggPagePackets {
+		return o.flush(0)
+	}
+	return nil
+}
+
+// Granule returns the number of samples written so far.
+func (o *OggOpusWriter) Granule() int64 {
+	return o.granule
+}
+
+// Close writes the final page with the end-of-stream flag. It does not close
+// the underlying writer.
+func (o *OggOpusWriter) Close() error {
+	return o.flush(oggHeaderEOS)
+}
+
+func (o *OggOpusWriter) flush(headerType byte) error {
+	if len(o.segments) == 0 && headerType&oggHeaderEOS == 0 {
+		return nil
+	}
+
+	err := o.writePage(headerType, o.granule, o.segments, o.data)
+	o.segments = o.segments[:0]
+	o.data = o.data[:0]
+	o.packets = 0
+	return err
+}
+
+func (o *OggOpusWriter) writePage(headerType byte, granule int64, segments, data []byte) error {
+	page := make([]byte, 27+len(segments)+len(data))
+	copy(page[0:4], "OggS")
+	page[4] = 0 // Version
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:14], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:18], o.serial)
+	binary.LittleEndian.PutUint32(page[18:22], o.pageSeq)
+	page[26] = byte(len(segments))
+	copy(page[27:], segments)
+	copy(page[27+len(segments):], data)
+
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+
+	o.pageSeq++
+	_, err := o.w.Write(page)
+	return err
+}
+
+// lacing returns the Ogg segment table entries for a packet of size n.
+func lacing(n int) []byte {
+	segments := make([]byte, 0, n/255+1)
+	for n >= 255 {
+		segments = append(segments, 255)
+		n -= 255
+	}
+	return append(segments, byte(n))
+}
+
+// OggOpusReader demuxes Opus packets from an Ogg Opus stream. Only the
+// first logical stream is read; the OpusHead and OpusTags headers are
+// skipped.
+type OggOpusReader struct {
+	r       io.Reader
+	serial  uint32
+	packets [][]byte
+	partial []byte // Packet continued on the next page
+	headers int    // Header packets still to skip
+	eos     bool
+	started bool
+}
+
+// NewOggOpusReader returns a reader over an Ogg Opus stream.
+func NewOggOpusReader(r io.Reader) *OggOpusReader {
+	return &OggOpusReader{
+		r:       r,
+		headers: 2,
+	}
+}
+
+// ReadPacket returns the next Opus packet, or io.EOF at the end of the
+// stream.
+func (o *OggOpusReader) ReadPacket() ([]byte, error) {
+	for len(o.packets) == 0 {
+		if o.eos {
+			return nil, io.EOF
+		}
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+
+	packet := o.packets[0]
+	o.packets = o.packets[1:]
+	return packet, nil
+}
+
+func (o *OggOpusReader) readPage() error {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(o.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF || (err == io.EOF && o.started) {
+			// Truncated recording, e.g. the bot was killed mid-session
+			o.eos = true
+			return nil
+		}
+		return err
+	}
+	if string(header[0:4]) != "OggS" {
+		return fmt.Errorf("invalid Ogg page signature")
+	}
+
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(o.r, segments); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			o.eos = true
+			return nil
+		}
+		return fmt.Errorf("failed to read Ogg segment table: %w", err)
+	}
+
+	size := 0
+	for _, segment := range segments {
+		size += int(segment)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(o.r, data); err != nil {
+		// A page cut short is dropped along with the rest of the stream
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			o.eos = true
+			return nil
+		}
+		return fmt.Errorf("failed to read Ogg page: %w", err)
+	}
+
+	serial := binary.LittleEndian.Uint32(header[14:18])
+	if !o.started {
+		o.serial = serial
+		o.started = true
+	} else if serial != o.serial {
+		// Ignore other multiplexed streams
+		return nil
+	}
+
+	if header[5]&oggHeaderContinued == 0 {
+		o.partial = nil
+	}
+
+	offset := 0
+	packet := o.partial
+	for _, segment := range segments {
+		packet = append(packet, data[offset:offset+int(segment)]...)
+		offset += int(segment)
+
+		if segment < 255 {
+			if o.headers > 0 {
+				o.headers--
+			} else {
+				o.packets = append(o.packets, packet)
+			}
+			packet = nil
+		}
+	}
+	o.partial = packet
+
+	if header[5]&oggHeaderEOS != 0 {
+		o.eos = true
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestOggOpusRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []int // Packet sizes in bytes
+	}{
+		{"single silence frame", []int{len(OpusSilenceFrame)}},
+		{"packets needing several lacing segments", []int{254, 255, 256, 700}},
+		{"several pages", repeat(80, 120)},
+		{"pages limited by segment count", repeat(200, 600)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var packets [][]byte
+			for i, size := range tt.sizes {
+				packet := make([]byte, size)
+				for j := range packet {
+					packet[j] = byte(i + j)
+				}
+				packets = append(packets, packet)
+			}
+
+			var buf bytes.Buffer
+			w, err := NewOggOpusWriter(&buf, SampleRate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, packet := range packets {
+				if err := w.WritePacket(packet, FrameSize); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := w.Granule(), int64(len(packets)*FrameSize); got != want {
+				t.Errorf("Granule() = %d, want %d", got, want)
+			}
+
+			r := NewOggOpusReader(&buf)
+			for i, want := range packets {
+				got, err := r.ReadPacket()
+				if err != nil {
+					t.Fatalf("packet %d: %v", i, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("packet %d differs: got %d bytes, want %d", i, len(got), len(want))
+				}
+			}
+			if _, err := r.ReadPacket(); !errors.Is(err, io.EOF) {
+				t.Errorf("after the last packet got %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestOggOpusReaderTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewOggOpusWriter(&buf, SampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*oggPagePackets; i++ {
+		if err := w.WritePacket(OpusSilenceFrame, FrameSize); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// No Close, and the last page cut short, as when the bot is killed
+	data := buf.Bytes()[:buf.Len()-10]
+
+	r := NewOggOpusReader(bytes.NewReader(data))
+	read := 0
+	for {
+		_, err := r.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadPacket failed after %d packets: %v", read, err)
+		}
+		read++
+	}
+	if read != oggPagePackets {
+		t.Errorf("read %d packets, want the %d on complete pages", read, oggPagePackets)
+	}
+}
+
+func TestOggOpusReaderRejectsOtherFormats(t *testing.T) {
+	r := NewOggOpusReader(bytes.NewReader(EncodeWAV(make([]int16, FrameSize), SampleRate)))
+	if _, err := r.ReadPacket(); err == nil {
+		t.Error("reading a WAV file as Ogg succeeded")
+	}
+}
+
+func repeat(n, size int) []int {
+	sizes := make([]int, n)
+	for i := range sizes {
+		sizes[i] = size
+	}
+	return sizes
+}
@@ -0,0 +1,115 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Processing stages
+const (
+	ProcessorHighPass = "highpass"
+	ProcessorGate     = "gate"
+	ProcessorAGC      = "agc"
+)
+
+// Processor transforms a frame of PCM in place. Processors keep state
+// between frames, so each speaker needs their own instances.
+type Processor interface {
+	Process(pcm []int16)
+}
+
+// Chain runs processors in order.
+type Chain []Processor
+
+func (c Chain) Process(pcm []int16) {
+	for _, processor := range c {
+		processor.Process(pcm)
+	}
+}
+
+// ProcessorConfig describes the per-speaker processing chain.
+type ProcessorConfig struct {
+	Stages     []string // Applied in order, e.g. highpass, gate, agc
+	SampleRate int
+
+	HighPassCutoff  float64 // Hz
+	GateThresholdDB float64 // dBFS below which audio is muted
+	AGCTargetDB     float64 // dBFS the AGC aims for
+	AGCMaxGainDB    float64 // Upper bound on AGC boost
+}
+
+// DefaultProcessorConfig returns the default processing parameters with no
+// stages enabled.
+func DefaultProcessorConfig() ProcessorConfig {
+	return ProcessorConfig{
+		SampleRate:      SampleRate,
+		HighPassCutoff:  80,
+		GateThresholdDB: -50,
+		AGCTargetDB:     -20,
+		AGCMaxGainDB:    20,
+	}
+}
+
+// ProcessorFactory creates a fresh processing chain for every speaker.
+type ProcessorFactory interface {
+	NewProcessor() Processor
+}
+
+type configProcessorFactory struct {
+	cfg ProcessorConfig
+}
+
+// NewProcessorFactory validates cfg and returns a factory building chains
+// of the configured stages.
+func NewProcessorFactory(cfg ProcessorConfig) (ProcessorFactory, error) {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = SampleRate
+	}
+
+	for _, stage := range cfg.Stages {
+		switch stage {
+		case ProcessorHighPass:
+			if cfg.HighPassCutoff <= 0 || cfg.HighPassCutoff >= float64(cfg.SampleRate)/2 {
+				return nil, fmt.Errorf("high-pass cutoff must be between 0 and %dHz, got %.0f", cfg.SampleRate/2, cfg.HighPassCutoff)
+			}
+		case ProcessorGate:
+			if cfg.GateThresholdDB >= 0 {
+				return nil, fmt.Errorf("noise gate threshold must be below 0 dBFS, got %.1f", cfg.GateThresholdDB)
+			}
+		case ProcessorAGC:
+			if cfg.AGCTargetDB >= 0 || cfg.AGCMaxGainDB < 0 {
+				return nil, fmt.Errorf("AGC target must be below 0 dBFS and max gain non-negative")
+			}
+		default:
+			return nil, fmt.Errorf("unknown audio processor %q", stage)
+		}
+	}
+
+	return &configProcessorFactory{cfg: cfg}, nil
+}
+
+func (f *configProcessorFactory) NewProcessor() Processor {
+	chain := make(Chain, 0, len(f.cfg.Stages))
+	for _, stage := range f.cfg.Stages {
+		switch stage {
+		case ProcessorHighPass:
+			chain = append(chain, NewHighPassFilter(f.cfg.HighPassCutoff, f.cfg.SampleRate))
+		case ProcessorGate:
+			chain = append(chain, NewNoiseGate(f.cfg.GateThresholdDB, f.cfg.SampleRate))
+		case ProcessorAGC:
+			chain = append(chain, NewAGC(f.cfg.AGCTargetDB, f.cfg.AGCMaxGainDB))
+		}
+	}
+	return chain
+}
+
+// ParseStages splits a comma-separated list of processing stages.
+func ParseStages(value string) []string {
+	var stages []string
+	for _, stage := range strings.Split(value, ",") {
+		if stage = strings.ToLower(strings.TrimSpace(stage)); stage != "" {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
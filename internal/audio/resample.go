@@ -0,0 +1,181 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// resampleHalfTaps is the number of filter taps per polyphase branch on
+	// each side of the centre. 16 gives a ~32 tap branch, plenty for speech.
+	resampleHalfTaps = 16
+
+	// resampleKaiserBeta trades transition width for stopband attenuation
+	// (~85 dB at 8.6).
+	resampleKaiserBeta = 8.6
+
+	// resampleRolloff places the cutoff slightly below Nyquist so the
+	// transition band doesn't alias.
+	resampleRolloff = 0.95
+)
+
+// Resampler converts 16-bit mono PCM between sample rates with a polyphase
+// windowed-sinc (Kaiser) filter. It is stateless between calls, so a single
+// instance can be shared between goroutines as long as each call is given a
+// complete, independent buffer such as a chunk.
+type Resampler struct {
+	from int
+	to   int
+	up   int // Interpolation factor
+	down int // Decimation factor
+
+	filter []float64 // Prototype low-pass filter at from*up Hz
+	delay  int       // Filter group delay in upsampled samples
+}
+
+// NewResampler creates a resampler from one sample rate to another.
+func NewResampler(from, to int) (*Resampler, error) {
+	if from <= 0 || to <= 0 {
+		return nil, fmt.Errorf("invalid resample rates %d -> %d", from, to)
+	}
+
+	g := gcd(from, to)
+	up := to / g
+	down := from / g
+
+	// Cutoff relative to the upsampled rate, below both Nyquist limits
+	cutoff := resampleRolloff * 0.5 / float64(max(up, down))
+
+	delay := resampleHalfTaps * max(up, down)
+	length := 2*delay + 1
+	filter := make([]float64, length)
+	for i := range filter {
+		x := float64(i - delay)
+		filter[i] = float64(up) * 2 * cutoff * sinc(2*cutoff*x) * kaiser(x, float64(delay), resampleKaiserBeta)
+	}
+
+	return &Resampler{
+		from:   from,
+		to:     to,
+		up:     up,
+		down:   down,
+		filter: filter,
+		delay:  delay,
+	}, nil
+}
+
+// ResampleChunk returns a copy of chunk converted to the target rate, or the
+// chunk itself if it already has that rate. Chunks without a sample rate are
+// assumed to be at the canonical 48kHz.
+func ResampleChunk(chunk *Chunk, r *Resampler) *Chunk {
+	from := chunk.SampleRate
+	if from == 0 {
+		from = SampleRate
+	}
+	if from == r.to {
+		return chunk
+	}
+
+	resampled := *chunk
+	resampled.PCM = r.Resample(chunk.PCM)
+	resampled.SampleRate = r.to
+	return &resampled
+}
+
+// From returns the input sample rate.
+func (r *Resampler) From() int {
+	return r.from
+}
+
+// To returns the output sample rate.
+func (r *Resampler) To() int {
+	return r.to
+}
+
+// Resample converts a complete buffer. Samples outside the buffer are
+// treated as silence.
+func (r *Resampler) Resample(pcm []int16) []int16 {
+	if r.up == r.down {
+		out := make([]int16, len(pcm))
+		copy(out, pcm)
+		return out
+	}
+
+	outLen := len(pcm) * r.up / r.down
+	out := make([]int16, outLen)
+
+	for k := range out {
+		// Position of this output sample on the upsampled timeline,
+		// shifted by the filter delay so input and output stay aligned
+		t := k*r.down + r.delay
+
+		// Only every up-th upsampled sample is non-zero, so visit the input
+		// samples under the filter directly (the polyphase branch for t)
+		first := (t - len(r.filter) + r.up) / r.up
+		if first < 0 {
+			first = 0
+		}
+		last := t / r.up
+		if last >= len(pcm) {
+			last = len(pcm) - 1
+		}
+
+		var acc float64
+		for n := first; n <= last; n++ {
+			acc += r.filter[t-n*r.up] * float64(pcm[n])
+		}
+
+		out[k] = clampInt16(acc)
+	}
+
+	return out
+}
+
+func clampInt16(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser evaluates a Kaiser window of half-width half at offset x from the
+// centre.
+func kaiser(x, half, beta float64) float64 {
+	ratio := x / half
+	if ratio < -1 || ratio > 1 {
+		return 0
+	}
+	return besselI0(beta*math.Sqrt(1-ratio*ratio)) / besselI0(beta)
+}
+
+// besselI0 is the zeroth-order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum := 1.0
+	term := 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
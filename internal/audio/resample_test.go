@@ -0,0 +1,120 @@
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func sine(freq float64, rate, samples int, amplitude float64) []int16 {
+	pcm := make([]int16, samples)
+	for i := range pcm {
+		pcm[i] = int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return pcm
+}
+
+// rms returns the RMS level of pcm, ignoring edge samples where the filter
+// runs off the end of the buffer.
+func rms(pcm []int16, edge int) float64 {
+	var sum float64
+	n := 0
+	for _, sample := range pcm[edge : len(pcm)-edge] {
+		sum += float64(sample) * float64(sample)
+		n++
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+func TestResampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		freq     float64
+		wantGain float64 // Output RMS relative to input RMS
+	}{
+		{"down to 16kHz keeps speech", 48000, 16000, 440, 1},
+		{"down to 16kHz removes tones above Nyquist", 48000, 16000, 12000, 0},
+		{"up from 16kHz keeps speech", 16000, 48000, 440, 1},
+		{"uneven ratio keeps speech", 48000, 44100, 1000, 1},
+		{"same rate copies", 16000, 16000, 440, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewResampler(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in := sine(tt.freq, tt.from, tt.from/2, 10000)
+			out := r.Resample(in)
+			if want := len(in) * tt.to / tt.from; len(out) != want {
+				t.Fatalf("got %d samples, want %d", len(out), want)
+			}
+
+			gain := rms(out, tt.to/100) / rms(in, tt.from/100)
+			if math.Abs(gain-tt.wantGain) > 0.02 {
+				t.Errorf("gain = %.3f, want %.3f", gain, tt.wantGain)
+			}
+		})
+	}
+}
+
+func TestResamplerKeepsTiming(t *testing.T) {
+	// A click should come out at the same point in time
+	in := make([]int16, SampleRate/10)
+	in[2400] = 30000 // 50ms
+
+	r, err := NewResampler(SampleRate, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := r.Resample(in)
+
+	peak := 0
+	for i, sample := range out {
+		if sample > out[peak] {
+			peak = i
+		}
+	}
+	if peak != 800 {
+		t.Errorf("click at sample %d, want 800", peak)
+	}
+}
+
+func TestNewResamplerRejectsInvalidRates(t *testing.T) {
+	for _, rates := range [][2]int{{0, 16000}, {48000, 0}, {-1, 16000}} {
+		if _, err := NewResampler(rates[0], rates[1]); err == nil {
+			t.Errorf("NewResampler(%d, %d) succeeded", rates[0], rates[1])
+		}
+	}
+}
+
+func TestResampleChunk(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	chunk := &Chunk{
+		PCM:     make([]int16, SampleRate),
+		Start:   start,
+		Anchors: []TimeAnchor{{0, start}, {500 * time.Millisecond, start.Add(5 * time.Second)}},
+	}
+
+	r, err := NewResampler(SampleRate, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resampled := ResampleChunk(chunk, r)
+	if resampled.SampleRate != 16000 || len(resampled.PCM) != 16000 {
+		t.Errorf("got %d samples at %dHz, want 16000 at 16000Hz", len(resampled.PCM), resampled.SampleRate)
+	}
+	if len(chunk.PCM) != SampleRate {
+		t.Error("ResampleChunk modified the original chunk")
+	}
+	if got, want := resampled.TimeAt(750*time.Millisecond), start.Add(5250*time.Millisecond); !got.Equal(want) {
+		t.Errorf("TimeAt after resampling = %s, want %s", got, want)
+	}
+
+	if again := ResampleChunk(resampled, r); again != resampled {
+		t.Error("a chunk already at the target rate was copied")
+	}
+}
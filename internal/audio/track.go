@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"layeh.com/gopus"
+)
+
+// PacketReader yields a speaker's audio as consecutive 20ms Opus packets,
+// the same form it arrives in from Discord.
+type PacketReader interface {
+	// ReadPacket returns the next packet, or io.EOF after the last one.
+	ReadPacket() ([]byte, error)
+	Close() error
+}
+
+// OpenTrack opens a recorded speaker track for replay. Ogg Opus files are
+// demuxed as-is; WAV files are resampled to 48kHz mono and encoded to Opus
+// so they go through the same decoder as live audio.
+func OpenTrack(path string) (PacketReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open track: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ogg", ".opus":
+		return &oggTrack{reader: NewOggOpusReader(file), file: file}, nil
+
+	case ".wav":
+		defer file.Close()
+
+		pcm, sampleRate, err := ReadWAV(file)
+		if err != nil {
+			return nil, err
+		}
+		if sampleRate != SampleRate {
+			resampler, err := NewResampler(sampleRate, SampleRate)
+			if err != nil {
+				return nil, err
+			}
+			pcm = resampler.Resample(pcm)
+		}
+
+		encoder, err := gopus.NewEncoder(SampleRate, Channels, gopus.Voip)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create opus encoder: %w", err)
+		}
+		return &pcmTrack{pcm: pcm, encoder: encoder}, nil
+
+	default:
+		file.Close()
+		return nil, fmt.Errorf("unsupported track format %q, expected .wav or .ogg", filepath.Ext(path))
+	}
+}
+
+type oggTrack struct {
+	reader *OggOpusReader
+	file   *os.File
+}
+
+func (t *oggTrack) ReadPacket() ([]byte, error) {
+	return t.reader.ReadPacket()
+}
+
+func (t *oggTrack) Close() error {
+	return t.file.Close()
+}
+
+// pcmTrack encodes decoded audio back into Opus one frame at a time.
+type pcmTrack struct {
+	pcm     []int16
+	pos     int
+	encoder *gopus.Encoder
+}
+
+func (t *pcmTrack) ReadPacket() ([]byte, error) {
+	if t.pos >= len(t.pcm) {
+		return nil, io.EOF
+	}
+
+	frame := make([]int16, FrameSize)
+	t.pos += copy(frame, t.pcm[t.pos:])
+
+	packet, err := t.encoder.Encode(frame, FrameSize, maxOpusPacket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode opus: %w", err)
+	}
+	return packet, nil
+}
+
+func (t *pcmTrack) Close() error {
+	return nil
+}
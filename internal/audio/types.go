@@ -1,6 +1,7 @@
 package audio
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,23 +9,69 @@ import (
 
 // Chunk represents a segment of audio data for processing
 type Chunk struct {
-	ID       uuid.UUID
-	PCM      []int16
-	Start    time.Time
-	End      time.Time
-	Speakers []string // Discord user IDs of speakers in this chunk
+	ID         uuid.UUID
+	PCM        []int16
+	SampleRate int // Sample rate of PCM in Hz
+	Start      time.Time
+	End        time.Time
+	Speakers   []string // Discord user IDs of speakers in this chunk
+	// Anchors mark where each contiguous run of captured audio starts in
+	// PCM. Only speech is chunked, so pauses are cut out and a chunk's
+	// audio is shorter than End-Start. Empty means PCM is contiguous.
+	Anchors []TimeAnchor
+}
+
+// TimeAnchor maps a position in a chunk's audio back to capture time.
+// Offset is a duration rather than a sample index so it survives
+// resampling.
+type TimeAnchor struct {
+	Offset time.Duration // Position in the chunk's audio
+	At     time.Time     // When the audio at Offset was captured
+}
+
+// TimeAt returns when the audio at offset into the chunk was captured.
+// Backends report times relative to the audio they were sent, which skips
+// the pauses, so their offsets must be mapped through this rather than
+// added to Start.
+func (c *Chunk) TimeAt(offset time.Duration) time.Time {
+	if len(c.Anchors) == 0 {
+		return c.Start.Add(offset)
+	}
+
+	anchor := c.Anchors[0]
+	for _, a := range c.Anchors[1:] {
+		if a.Offset > offset {
+			break
+		}
+		anchor = a
+	}
+	return anchor.At.Add(offset - anchor.Offset)
 }
 
 // Utterance represents a transcribed piece of speech
 type Utterance struct {
-	ID       uuid.UUID     `json:"id"`
-	TSStart  time.Time     `json:"ts_start"`
-	TSEnd    time.Time     `json:"ts_end"`
-	UserID   string        `json:"user_id"`
-	UserTag  string        `json:"user_tag"`
-	Text     string        `json:"text"`
-	Source   string        `json:"source"` // "vosk" or "deepgram"
-	Confidence float64     `json:"confidence,omitempty"`
+	ID         uuid.UUID `json:"id"`
+	TSStart    time.Time `json:"ts_start"`
+	TSEnd      time.Time `json:"ts_end"`
+	UserID     string    `json:"user_id"`
+	UserTag    string    `json:"user_tag"`
+	Text       string    `json:"text"`
+	Source     string    `json:"source"` // "vosk", "deepgram" or "whisper"
+	Confidence float64   `json:"confidence,omitempty"`
+	Interim    bool      `json:"interim,omitempty"` // Partial streaming result, superseded by a later one
+	Words      []Word    `json:"words,omitempty"`   // Word timings, when the backend provides them
+}
+
+// Word is a single recognized word within an utterance
+type Word struct {
+	Text       string    `json:"text"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Confidence float64   `json:"confidence,omitempty"`
+	// Speaker is the backend's diarization label within the chunk, when it
+	// diarizes. All of a chunk's audio comes from one Discord user, so it
+	// only tells apart people sharing a microphone.
+	Speaker *int `json:"speaker,omitempty"`
 }
 
 // VoiceState tracks Discord voice state for a user
@@ -38,6 +85,9 @@ type VoiceState struct {
 // AudioDecoder interface for different audio decoders
 type AudioDecoder interface {
 	Decode(opus []byte) ([]int16, error)
+	DecodeFEC(next []byte) ([]int16, error) // Recover a lost frame from the next packet
+	DecodePLC() ([]int16, error)            // Conceal a lost frame
+	Close()
 }
 
 // VAD interface for Voice Activity Detection
@@ -51,4 +101,40 @@ type Chunker interface {
 	AddSamples(pcm []int16, timestamp time.Time, speakers []string)
 	GetChunk() <-chan *Chunk
 	Stop()
-}
\ No newline at end of file
+	// Lost returns the chunks that could not be handed on for transcription
+	Lost() []LostChunk
+}
+
+// LostChunk is audio that was never transcribed, kept so the notes can say
+// where the transcript has gaps
+type LostChunk struct {
+	Start    time.Time
+	End      time.Time
+	Speakers []string
+	Reason   string
+}
+
+// AppendLost adds lost audio to list. Audio that carries straight on from
+// the last entry for the same reason extends it instead, so a run of
+// dropped frames is kept as one gap rather than one per frame.
+func AppendLost(list []LostChunk, lost LostChunk) []LostChunk {
+	if n := len(list); n > 0 {
+		last := &list[n-1]
+		frame := time.Duration(FrameSize) * time.Second / SampleRate
+		if last.Reason == lost.Reason && !lost.Start.Before(last.Start) && !lost.Start.After(last.End.Add(frame)) {
+			if lost.End.After(last.End) {
+				last.End = lost.End
+			}
+			for _, speaker := range lost.Speakers {
+				if !slices.Contains(last.Speakers, speaker) {
+					last.Speakers = append(last.Speakers, speaker)
+				}
+			}
+			return list
+		}
+	}
+
+	// Speakers grows as entries merge, so it mustn't share the caller's array
+	lost.Speakers = slices.Clone(lost.Speakers)
+	return append(list, lost)
+}
@@ -0,0 +1,48 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendLost(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	frame := 20 * time.Millisecond
+	lost := func(from, to int, reason string, speakers ...string) LostChunk {
+		return LostChunk{
+			Start:    start.Add(time.Duration(from) * frame),
+			End:      start.Add(time.Duration(to) * frame),
+			Speakers: speakers,
+			Reason:   reason,
+		}
+	}
+
+	var list []LostChunk
+	for _, chunk := range []LostChunk{
+		lost(0, 1, "full", "alice"),
+		lost(1, 2, "full", "alice"),
+		// A frame missing in between is still the same run
+		lost(3, 4, "full", "bob"),
+		// A different reason starts a new gap
+		lost(4, 5, "closed", "alice"),
+		// So does a pause
+		lost(20, 21, "closed", "alice"),
+		// And audio from before the last gap
+		lost(10, 11, "closed", "alice"),
+	} {
+		list = AppendLost(list, chunk)
+	}
+
+	parts := make([]string, len(list))
+	for i, chunk := range list {
+		parts[i] = fmt.Sprintf("%d-%d %s %s",
+			chunk.Start.Sub(start)/frame, chunk.End.Sub(start)/frame, chunk.Reason, strings.Join(chunk.Speakers, ","))
+	}
+	got := strings.Join(parts, " | ")
+	want := "0-4 full alice,bob | 4-5 closed alice | 20-21 closed alice | 10-11 closed alice"
+	if got != want {
+		t.Errorf("AppendLost gave %q, want %q", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package audio
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/maxhawkins/go-webrtcvad"
@@ -11,18 +12,21 @@ type WebRTCVAD struct {
 	rmsThreshold float64
 }
 
-func NewWebRTCVAD() (*WebRTCVAD, error) {
+// NewWebRTCVAD creates a WebRTC VAD with the given aggressiveness (0-3, where
+// 3 is most aggressive). rmsThreshold is used for frames WebRTC can't handle.
+func NewWebRTCVAD(mode int, rmsThreshold float64) (*WebRTCVAD, error) {
 	vad, err := webrtcvad.New()
 	if err != nil {
 		return nil, err
 	}
 
-	// Set aggressiveness (0-3, where 3 is most aggressive)
-	vad.SetMode(2)
+	if err := vad.SetMode(mode); err != nil {
+		return nil, fmt.Errorf("invalid VAD aggressiveness %d: %w", mode, err)
+	}
 
 	return &WebRTCVAD{
 		vad:          vad,
-		rmsThreshold: 500.0, // Fallback RMS threshold
+		rmsThreshold: rmsThreshold,
 	}, nil
 }
 
@@ -48,13 +52,7 @@ func (v *WebRTCVAD) rmsIsSpeech(pcm []int16) bool {
 		return false
 	}
 
-	var sum float64
-	for _, sample := range pcm {
-		sum += float64(sample) * float64(sample)
-	}
-
-	rms := math.Sqrt(sum / float64(len(pcm)))
-	return rms > v.rmsThreshold
+	return frameRMS(pcm) > v.rmsThreshold
 }
 
 func (v *WebRTCVAD) Close() error {
@@ -70,3 +68,67 @@ func int16SliceToBytes(samples []int16) []byte {
 	}
 	return bytes
 }
+
+// EnergyVAD classifies frames by comparing their level to an adaptive
+// estimate of the speaker's background noise. Each instance tracks a single
+// speaker, so a noisy mic raises only its own floor.
+type EnergyVAD struct {
+	marginRatio float64 // Linear ratio above the floor that counts as speech
+	minRMS      float64 // Absolute level below which nothing is speech
+	floor       float64
+}
+
+const (
+	// energyFloorDown adapts quickly when the level drops, since the
+	// quietest frames are the best estimate of background noise
+	energyFloorDown = 0.2
+	// energyFloorUp adapts slowly so short bursts don't drag the floor up
+	energyFloorUp = 0.005
+	// energyFloorSpeech still lets the floor creep up during "speech", so a
+	// persistent rise in background noise is eventually absorbed
+	energyFloorSpeech = 0.0005
+)
+
+// NewEnergyVAD creates an adaptive energy VAD. Frames must be marginDB above
+// the tracked noise floor and above minRMS to count as speech.
+func NewEnergyVAD(marginDB, minRMS float64) *EnergyVAD {
+	marginRatio := math.Pow(10, marginDB/20)
+	return &EnergyVAD{
+		marginRatio: marginRatio,
+		minRMS:      minRMS,
+		// Discord only sends audio while someone talks, so the first frames
+		// are speech and can't seed the floor. Start from the floor at which
+		// minRMS is the threshold and let pauses pull it down from there.
+		floor: minRMS / marginRatio,
+	}
+}
+
+func (v *EnergyVAD) IsSpeech(pcm []int16, sampleRate int) bool {
+	if len(pcm) == 0 {
+		return false
+	}
+
+	rms := frameRMS(pcm)
+
+	isSpeech := rms > v.minRMS && rms > v.floor*v.marginRatio
+
+	rate := energyFloorUp
+	switch {
+	case rms < v.floor:
+		rate = energyFloorDown
+	case isSpeech:
+		rate = energyFloorSpeech
+	}
+	v.floor += (rms - v.floor) * rate
+
+	return isSpeech
+}
+
+// NoiseFloor returns the current background level estimate as RMS.
+func (v *EnergyVAD) NoiseFloor() float64 {
+	return v.floor
+}
+
+func (v *EnergyVAD) Close() error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnergyVAD(t *testing.T) {
+	vad := NewEnergyVAD(10, 500)
+
+	// Steps run in order on the same VAD, as one speaker's packets would
+	steps := []struct {
+		name   string
+		level  float64 // Sine amplitude
+		frames int
+		want   bool // For every frame when all is set, else the last one
+		all    bool
+	}{
+		// Packets only arrive while someone talks, so the very first frame
+		// must already count
+		{name: "opening words", level: 4000, frames: 25, want: true, all: true},
+		{name: "quiet background", level: 300, frames: 50, want: false, all: true},
+		{name: "speech over the background", level: 4000, frames: 25, want: true, all: true},
+		// A fan switched on: louder than minRMS, so it first passes as
+		// speech, then is absorbed into the floor
+		{name: "persistent noise is absorbed", level: 1700, frames: 3000, want: false},
+		{name: "speech barely above the noise", level: 3500, frames: 5, want: false},
+		{name: "speech well above the noise", level: 10000, frames: 5, want: true, all: true},
+	}
+
+	for _, step := range steps {
+		frame := sine(440, SampleRate, FrameSize, step.level)
+		for i := 0; i < step.frames; i++ {
+			got := vad.IsSpeech(frame, SampleRate)
+			if (step.all || i == step.frames-1) && got != step.want {
+				t.Errorf("%s: frame %d IsSpeech = %v, want %v (floor %.0f)", step.name, i, got, step.want, vad.NoiseFloor())
+				break
+			}
+		}
+	}
+}
+
+// scriptedVAD classifies frames from a script of 's' (speech) and '.'
+// (silence), one character per frame.
+type scriptedVAD struct {
+	script string
+	next   int
+}
+
+func (v *scriptedVAD) IsSpeech(pcm []int16, sampleRate int) bool {
+	speech := v.script[v.next] == 's'
+	v.next++
+	return speech
+}
+
+func (v *scriptedVAD) Close() error { return nil }
+
+func TestVADGate(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		preRoll  int
+		hangover int
+		want     string // Frames released by each push, "-" when none
+	}{
+		{
+			name:   "no smoothing passes speech only",
+			script: "..ss..s",
+			want:   "- - 2 3 - - 6",
+		},
+		{
+			name:    "pre-roll releases the frames before speech in order",
+			script:  "....ss",
+			preRoll: 2,
+			want:    "- - - - 2,3,4 5",
+		},
+		{
+			name:    "pre-roll is limited to what was seen",
+			script:  ".s",
+			preRoll: 3,
+			want:    "- 0,1",
+		},
+		{
+			name:     "hangover keeps the gate open after speech",
+			script:   "s....s",
+			hangover: 2,
+			want:     "0 1 2 - - 5",
+		},
+		{
+			name:     "speech during the hangover restarts it",
+			script:   "s.s...",
+			hangover: 2,
+			want:     "0 1 2 3 4 -",
+		},
+		{
+			name:     "frames released by the hangover aren't released again as pre-roll",
+			script:   "s...s",
+			preRoll:  2,
+			hangover: 1,
+			want:     "0 1 - - 2,3,4",
+		},
+	}
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := NewVADGate(&scriptedVAD{script: tt.script}, SampleRate, tt.preRoll, tt.hangover)
+
+			pushes := make([]string, len(tt.script))
+			for i := range tt.script {
+				released := gate.Push(make([]int16, FrameSize), start.Add(time.Duration(i)*20*time.Millisecond))
+				if len(released) == 0 {
+					pushes[i] = "-"
+					continue
+				}
+				frames := make([]string, len(released))
+				for j, frame := range released {
+					frames[j] = fmt.Sprint(int(frame.Timestamp.Sub(start) / (20 * time.Millisecond)))
+				}
+				pushes[i] = strings.Join(frames, ",")
+			}
+
+			if got := strings.Join(pushes, " "); got != tt.want {
+				t.Errorf("released %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package audio
+
+import (
+	"fmt"
+	"time"
+)
+
+// VAD implementations
+const (
+	VADTypeWebRTC = "webrtc"
+	VADTypeEnergy = "energy"
+)
+
+// VADFrame is a frame passed through by a VADGate.
+type VADFrame struct {
+	PCM       []int16
+	Timestamp time.Time
+}
+
+// VADGate smooths per-frame VAD decisions. When speech starts, the preceding
+// pre-roll frames are released too so word onsets survive, and after speech
+// stops the gate stays open for the hangover frames so trailing consonants
+// aren't cut off.
+type VADGate struct {
+	vad        VAD
+	sampleRate int
+	preRoll    int
+	hangover   int
+
+	history   []VADFrame // Most recent non-speech frames, oldest first
+	remaining int        // Hangover frames left before the gate closes
+}
+
+// NewVADGate wraps a VAD with pre-roll and hangover, both counted in frames.
+func NewVADGate(vad VAD, sampleRate, preRoll, hangover int) *VADGate {
+	return &VADGate{
+		vad:        vad,
+		sampleRate: sampleRate,
+		preRoll:    preRoll,
+		hangover:   hangover,
+		history:    make([]VADFrame, 0, preRoll),
+	}
+}
+
+// Push classifies a frame and returns the frames that should be treated as
+// speech, in order. The gate keeps references to pcm.
+func (g *VADGate) Push(pcm []int16, timestamp time.Time) []VADFrame {
+	frame := VADFrame{PCM: pcm, Timestamp: timestamp}
+
+	if g.vad.IsSpeech(pcm, g.sampleRate) {
+		frames := append(g.history, frame)
+		g.history = make([]VADFrame, 0, g.preRoll)
+		g.remaining = g.hangover
+		return frames
+	}
+
+	if g.remaining > 0 {
+		g.remaining--
+		return []VADFrame{frame}
+	}
+
+	if g.preRoll > 0 {
+		if len(g.history) == g.preRoll {
+			copy(g.history, g.history[1:])
+			g.history = g.history[:len(g.history)-1]
+		}
+		g.history = append(g.history, frame)
+	}
+
+	return nil
+}
+
+func (g *VADGate) Close() error {
+	return g.vad.Close()
+}
+
+// VADConfig describes the per-speaker voice activity detection.
+type VADConfig struct {
+	Type       string // "webrtc" or "energy"
+	SampleRate int
+
+	Aggressiveness int     // WebRTC mode, 0-3
+	RMSThreshold   float64 // WebRTC fallback / energy absolute minimum
+	EnergyMarginDB float64 // Energy: level above the noise floor that counts as speech
+
+	PreRollFrames  int
+	HangoverFrames int
+}
+
+// VADFactory creates a fresh VAD gate for every speaker.
+type VADFactory interface {
+	NewVADGate() (*VADGate, error)
+}
+
+type configVADFactory struct {
+	cfg VADConfig
+}
+
+// NewVADFactory validates cfg and returns a factory building VAD gates of
+// the configured type.
+func NewVADFactory(cfg VADConfig) (VADFactory, error) {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = SampleRate
+	}
+
+	switch cfg.Type {
+	case VADTypeWebRTC:
+		if cfg.Aggressiveness < 0 || cfg.Aggressiveness > 3 {
+			return nil, fmt.Errorf("VAD aggressiveness must be between 0 and 3, got %d", cfg.Aggressiveness)
+		}
+	case VADTypeEnergy:
+		if cfg.EnergyMarginDB <= 0 {
+			return nil, fmt.Errorf("energy VAD margin must be positive, got %.1f", cfg.EnergyMarginDB)
+		}
+	default:
+		return nil, fmt.Errorf("unknown VAD type %q", cfg.Type)
+	}
+
+	if cfg.PreRollFrames < 0 || cfg.HangoverFrames < 0 {
+		return nil, fmt.Errorf("VAD pre-roll and hangover must not be negative")
+	}
+
+	return &configVADFactory{cfg: cfg}, nil
+}
+
+func (f *configVADFactory) NewVADGate() (*VADGate, error) {
+	var vad VAD
+	if f.cfg.Type == VADTypeEnergy {
+		vad = NewEnergyVAD(f.cfg.EnergyMarginDB, f.cfg.RMSThreshold)
+	} else {
+		webrtc, err := NewWebRTCVAD(f.cfg.Aggressiveness, f.cfg.RMSThreshold)
+		if err != nil {
+			return nil, err
+		}
+		vad = webrtc
+	}
+
+	return NewVADGate(vad, f.cfg.SampleRate, f.cfg.PreRollFrames, f.cfg.HangoverFrames), nil
+}
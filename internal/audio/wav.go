@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const wavHeaderSize = 44
+
+// EncodeWAV wraps 16-bit mono PCM in a WAV container.
+func EncodeWAV(pcm []int16, sampleRate int) []byte {
+	buf := new(bytes.Buffer)
+	buf.Grow(wavHeaderSize + len(pcm)*2)
+
+	writeWAVHeader(buf, sampleRate, len(pcm)*2)
+	binary.Write(buf, binary.LittleEndian, pcm)
+
+	return buf.Bytes()
+}
+
+func writeWAVHeader(w io.Writer, sampleRate, dataSize int) error {
+	header := make([]byte, wavHeaderSize)
+
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+dataSize)) // ChunkSize
+	copy(header[8:12], "WAVE")
+
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)                   // Subchunk1Size
+	binary.LittleEndian.PutUint16(header[20:22], 1)                    // AudioFormat (PCM)
+	binary.LittleEndian.PutUint16(header[22:24], 1)                    // NumChannels
+	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))   // SampleRate
+	binary.LittleEndian.PutUint32(header[28:32], uint32(sampleRate*2)) // ByteRate
+	binary.LittleEndian.PutUint16(header[32:34], 2)                    // BlockAlign
+	binary.LittleEndian.PutUint16(header[34:36], 16)                   // BitsPerSample
+
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize)) // Subchunk2Size
+
+	_, err := w.Write(header)
+	return err
+}
+
+// WAVWriter streams 16-bit mono PCM to a WAV file. The header sizes are
+// patched in on Close, so the destination must be seekable.
+type WAVWriter struct {
+	w          io.WriteSeeker
+	sampleRate int
+	dataSize   int
+}
+
+// NewWAVWriter writes a provisional header and returns a writer.
+func NewWAVWriter(w io.WriteSeeker, sampleRate int) (*WAVWriter, error) {
+	if err := writeWAVHeader(w, sampleRate, 0); err != nil {
+		return nil, fmt.Errorf("failed to write WAV header: %w", err)
+	}
+
+	return &WAVWriter{
+		w:          w,
+		sampleRate: sampleRate,
+	}, nil
+}
+
+// Write appends samples to the data chunk.
+func (w *WAVWriter) Write(pcm []int16) error {
+	if err := binary.Write(w.w, binary.LittleEndian, pcm); err != nil {
+		return fmt.Errorf("failed to write WAV samples: %w", err)
+	}
+	w.dataSize += len(pcm) * 2
+	return nil
+}
+
+// Samples returns the number of samples written so far.
+func (w *WAVWriter) Samples() int {
+	return w.dataSize / 2
+}
+
+// Close rewrites the header with the final sizes. It does not close the
+// underlying writer.
+func (w *WAVWriter) Close() error {
+	if _, err := w.w.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to WAV header: %w", err)
+	}
+	if err := writeWAVHeader(w.w, w.sampleRate, w.dataSize); err != nil {
+		return fmt.Errorf("failed to finalise WAV header: %w", err)
+	}
+	_, err := w.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// ReadWAV decodes a 16-bit PCM WAV file, downmixing to mono. It returns the
+// samples and their sample rate.
+func ReadWAV(r io.Reader) ([]int16, int, error) {
+	riff := make([]byte, 12)
+	if _, err := io.ReadFull(r, riff); err != nil {
+		return nil, 0, fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+
+	var sampleRate, channels int
+	for {
+		chunk := make([]byte, 8)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, 0, fmt.Errorf("failed to find WAV data chunk: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			format := make([]byte, size)
+			if _, err := io.ReadFull(r, format); err != nil {
+				return nil, 0, fmt.Errorf("failed to read WAV format: %w", err)
+			}
+			if size < 16 {
+				return nil, 0, fmt.Errorf("invalid WAV format chunk")
+			}
+			if audioFormat := binary.LittleEndian.Uint16(format[0:2]); audioFormat != 1 {
+				return nil, 0, fmt.Errorf("unsupported WAV encoding %d, only PCM is supported", audioFormat)
+			}
+			if bits := binary.LittleEndian.Uint16(format[14:16]); bits != 16 {
+				return nil, 0, fmt.Errorf("unsupported WAV sample size %d bits, only 16 is supported", bits)
+			}
+			channels = int(binary.LittleEndian.Uint16(format[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(format[4:8]))
+			if size%2 == 1 {
+				io.CopyN(io.Discard, r, 1)
+			}
+
+		case "data":
+			if channels == 0 {
+				return nil, 0, fmt.Errorf("WAV data chunk before format chunk")
+			}
+
+			// A header left unpatched by a crash reports no data; read to the end
+			data, err := io.ReadAll(io.LimitReader(r, size))
+			if size == 0 {
+				data, err = io.ReadAll(r)
+			}
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to read WAV samples: %w", err)
+			}
+
+			frames := len(data) / (2 * channels)
+			pcm := make([]int16, frames)
+			for i := range pcm {
+				sum := 0
+				for c := 0; c < channels; c++ {
+					sum += int(int16(binary.LittleEndian.Uint16(data[(i*channels+c)*2:])))
+				}
+				pcm[i] = int16(sum / channels)
+			}
+			return pcm, sampleRate, nil
+
+		default:
+			// Skip chunks we don't need, which are padded to an even size
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, 0, fmt.Errorf("failed to skip WAV chunk %q: %w", id, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	pcm := sine(440, 16000, 16000, 8000)
+
+	tests := []struct {
+		name   string
+		encode func(t *testing.T) []byte
+	}{
+		{"EncodeWAV", func(t *testing.T) []byte {
+			return EncodeWAV(pcm, 16000)
+		}},
+		{"WAVWriter", func(t *testing.T) []byte {
+			path := filepath.Join(t.TempDir(), "track.wav")
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := NewWAVWriter(file, 16000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Written in frames, as the archive does
+			for offset := 0; offset < len(pcm); offset += 320 {
+				if err := w.Write(pcm[offset:min(offset+320, len(pcm))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if w.Samples() != len(pcm) {
+				t.Errorf("Samples() = %d, want %d", w.Samples(), len(pcm))
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return data
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rate, err := ReadWAV(bytes.NewReader(tt.encode(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rate != 16000 {
+				t.Errorf("sample rate = %d, want 16000", rate)
+			}
+			if len(got) != len(pcm) {
+				t.Fatalf("got %d samples, want %d", len(got), len(pcm))
+			}
+			for i := range pcm {
+				if got[i] != pcm[i] {
+					t.Fatalf("sample %d = %d, want %d", i, got[i], pcm[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadWAVUnpatchedHeader(t *testing.T) {
+	// A recording cut off before Close reports no data in its header
+	pcm := sine(440, SampleRate, FrameSize*10, 8000)
+	data := EncodeWAV(pcm, SampleRate)
+	binary.LittleEndian.PutUint32(data[40:44], 0)
+
+	got, _, err := ReadWAV(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(pcm) {
+		t.Errorf("got %d samples, want %d", len(got), len(pcm))
+	}
+}
+
+func TestReadWAVRejectsUnsupportedFiles(t *testing.T) {
+	eightBit := EncodeWAV(make([]int16, 10), SampleRate)
+	binary.LittleEndian.PutUint16(eightBit[34:36], 8)
+
+	float := EncodeWAV(make([]int16, 10), SampleRate)
+	binary.LittleEndian.PutUint16(float[20:22], 3)
+
+	tests := map[string][]byte{
+		"not a WAV file": []byte("OggS and some more bytes"),
+		"8-bit samples":  eightBit,
+		"float samples":  float,
+		"empty":          nil,
+	}
+	for name, data := range tests {
+		if _, _, err := ReadWAV(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: ReadWAV succeeded", name)
+		}
+	}
+}
@@ -3,25 +3,35 @@ package bot
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/assembler"
 	"github.com/user/discord-notetaker/internal/audio"
 	"github.com/user/discord-notetaker/internal/config"
 	"github.com/user/discord-notetaker/internal/store"
 	"github.com/user/discord-notetaker/internal/stt"
 	"github.com/user/discord-notetaker/internal/stt/deepgram"
+	"github.com/user/discord-notetaker/internal/stt/vosk"
+	"github.com/user/discord-notetaker/internal/stt/whisper"
 	"github.com/user/discord-notetaker/internal/summariser/gemini"
 )
 
 type Bot struct {
-	config      *config.Config
-	session     *discordgo.Session
-	store       *store.FileStore
-	summariser  *gemini.GeminiSummariser
-	transcriber stt.Transcriber
+	config     *config.Config
+	session    *discordgo.Session
+	store      *store.FileStore
+	summariser *gemini.GeminiSummariser
+	scheduler  *stt.Scheduler           // Shares the transcriber between sessions
+	streamer   stt.StreamingTranscriber // Nil unless streaming is enabled
+
+	// Create the per-speaker audio processing chain and VAD
+	processorFactory audio.ProcessorFactory
+	vadFactory       audio.VADFactory
 
 	// Active sessions
 	sessions map[string]*VoiceSession
@@ -54,36 +64,225 @@ func NewBot(cfg *config.Config) (*Bot, error) {
 		return nil, fmt.Errorf("failed to create summariser: %w", err)
 	}
 
-	// Create transcriber - only Deepgram supported in this build
-	var transcriber stt.Transcriber
-	if cfg.STTBackend == "deepgram" {
-		transcriber = deepgram.NewDeepgramTranscriber(
-			cfg.DeepgramAPIKey,
-			cfg.DeepgramTier,
-			cfg.DeepgramDiarize,
-			cfg.DeepgramPunctuate,
-			cfg.DeepgramUtterances,
-		)
-	} else {
-		return nil, fmt.Errorf("only 'deepgram' STT backend is supported in this build. Set STT_BACKEND=deepgram")
+	// Create transcriber
+	transcriber, err := newTranscriber(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var streamer stt.StreamingTranscriber
+	if cfg.STTBackend == "deepgram" && cfg.DeepgramStreaming {
+		streamer = deepgram.NewDeepgramStreamer(deepgramOptions(cfg), cfg.DeepgramStreamURL)
+		if len(cfg.STTFallback) > 0 {
+			// Streams talk to Deepgram directly, outside the pool
+			log.Warn().
+				Strs("fallback", cfg.STTFallback).
+				Msg("STT_FALLBACK does not apply to streaming transcription")
+		}
+	}
+
+	processorFactory, vadFactory, err := newAudioFactories(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	bot := &Bot{
-		config:      cfg,
-		session:     session,
-		store:       store,
-		summariser:  summariser,
-		transcriber: transcriber,
-		sessions:    make(map[string]*VoiceSession),
+		config:           cfg,
+		session:          session,
+		store:            store,
+		summariser:       summariser,
+		scheduler:        stt.NewScheduler(transcriber, cfg.MaxParallelSTT, cfg.MaxParallelStreams),
+		streamer:         streamer,
+		processorFactory: processorFactory,
+		vadFactory:       vadFactory,
+		sessions:         make(map[string]*VoiceSession),
 	}
 
 	// Register handlers
 	session.AddHandler(bot.onReady)
 	session.AddHandler(bot.onMessageCreate)
+	session.AddHandler(bot.onVoiceStateUpdate)
 
 	return bot, nil
 }
 
+// newTranscriber creates the configured STT backend, wrapped in a fallback
+// chain when fallback backends are configured.
+func newTranscriber(cfg *config.Config) (stt.Transcriber, error) {
+	if err := resolveSTTBackend(cfg); err != nil {
+		return nil, err
+	}
+
+	primary, err := newBackend(cfg, cfg.STTBackend)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.STTFallback) == 0 {
+		return primary, nil
+	}
+
+	backends := []stt.FallbackBackend{{Name: cfg.STTBackend, Transcriber: primary}}
+	for _, name := range cfg.STTFallback {
+		transcriber, err := newBackend(cfg, name)
+		if err != nil {
+			for _, backend := range backends {
+				backend.Transcriber.Close()
+			}
+			return nil, err
+		}
+		backends = append(backends, stt.FallbackBackend{Name: name, Transcriber: transcriber})
+	}
+
+	log.Info().
+		Str("primary", cfg.STTBackend).
+		Strs("fallback", cfg.STTFallback).
+		Msg("Using STT fallback chain")
+
+	return stt.NewFallbackTranscriber(backends)
+}
+
+// defaultSTTBackend is Vosk when this build includes it, so the default
+// needs no account, and otherwise Deepgram.
+func defaultSTTBackend() string {
+	if vosk.Available {
+		return "vosk"
+	}
+	return "deepgram"
+}
+
+// resolveSTTBackend fills in the build's default backend when STT_BACKEND
+// is unset, and checks it as the configuration checks a named one.
+func resolveSTTBackend(cfg *config.Config) error {
+	if cfg.STTBackend != "" {
+		return nil
+	}
+
+	cfg.STTBackend = defaultSTTBackend()
+	for _, backend := range cfg.STTFallback {
+		if backend == cfg.STTBackend {
+			return fmt.Errorf("STT_FALLBACK repeats the default STT_BACKEND %q", backend)
+		}
+	}
+	return cfg.ValidateBackend(cfg.STTBackend, "STT_BACKEND")
+}
+
+// newBackend creates a single STT backend by name.
+func newBackend(cfg *config.Config, name string) (stt.Transcriber, error) {
+	switch name {
+	case "deepgram":
+		transcriber, err := deepgram.NewDeepgramTranscriber(deepgramOptions(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create deepgram transcriber: %w", err)
+		}
+		return transcriber, nil
+	case "vosk":
+		if !vosk.Available {
+			return nil, fmt.Errorf("this build has no Vosk support; rebuild with -tags vosk or choose another STT backend")
+		}
+		transcriber, err := vosk.NewVoskTranscriber(cfg.VoskModelPath, cfg.MaxParallelSTT)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vosk transcriber: %w", err)
+		}
+		return transcriber, nil
+	case "whisper":
+		transcriber, err := whisper.NewWhisperTranscriber(whisper.Options{
+			BaseURL:  cfg.WhisperURL,
+			API:      cfg.WhisperAPI,
+			Model:    cfg.WhisperModel,
+			Language: cfg.WhisperLanguage,
+			Prompt:   cfg.WhisperPrompt,
+			APIKey:   cfg.WhisperAPIKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create whisper transcriber: %w", err)
+		}
+		return transcriber, nil
+	default:
+		return nil, fmt.Errorf("unsupported STT backend %q", name)
+	}
+}
+
+// newTranscriberPool creates a worker pool for one session with the
+// configured concurrency, failure handling and result ordering.
+func newTranscriberPool(cfg *config.Config, transcriber stt.Transcriber) *stt.TranscriberPool {
+	pool := stt.NewTranscriberPool(transcriber, cfg.MaxParallelSTT, retryPolicy(cfg))
+	pool.SetReorderWindow(time.Duration(cfg.STTReorderMS) * time.Millisecond)
+	return pool
+}
+
+// turnOptions returns how utterances are merged into speaker turns.
+func turnOptions(cfg *config.Config) assembler.Options {
+	return assembler.Options{
+		MaxGap:      time.Duration(cfg.TurnMaxGapMS) * time.Millisecond,
+		SentenceGap: time.Duration(cfg.TurnSentenceGapMS) * time.Millisecond,
+		MaxTurn:     time.Duration(cfg.TurnMaxSeconds) * time.Second,
+	}
+}
+
+// retryPolicy returns the STT failure handling from the configuration.
+func retryPolicy(cfg *config.Config) stt.RetryPolicy {
+	return stt.RetryPolicy{
+		MaxRetries:       cfg.STTMaxRetries,
+		BaseDelay:        time.Duration(cfg.STTRetryBaseMS) * time.Millisecond,
+		MaxDelay:         time.Duration(cfg.STTRetryMaxMS) * time.Millisecond,
+		BreakerThreshold: cfg.STTBreakerThreshold,
+		BreakerCooldown:  time.Duration(cfg.STTBreakerCooldown) * time.Second,
+	}
+}
+
+// deepgramOptions collects the Deepgram request features from the
+// configuration.
+func deepgramOptions(cfg *config.Config) deepgram.Options {
+	return deepgram.Options{
+		APIKey:      cfg.DeepgramAPIKey,
+		BaseURL:     cfg.DeepgramBaseURL,
+		Model:       cfg.DeepgramTier,
+		Language:    cfg.DeepgramLanguage,
+		Diarize:     cfg.DeepgramDiarize,
+		Punctuate:   cfg.DeepgramPunctuate,
+		Utterances:  cfg.DeepgramUtterances,
+		SmartFormat: cfg.DeepgramSmartFormat,
+		Numerals:    cfg.DeepgramNumerals,
+		Profanity:   cfg.DeepgramProfanityFilter,
+		Redact:      cfg.DeepgramRedact,
+		Keywords:    cfg.DeepgramKeywords,
+		Keyterms:    cfg.DeepgramKeyterms,
+	}
+}
+
+// newAudioFactories creates the per-speaker processing chain and VAD
+// factories from the configuration.
+func newAudioFactories(cfg *config.Config) (audio.ProcessorFactory, audio.VADFactory, error) {
+	// Create audio processing chain factory
+	processorFactory, err := audio.NewProcessorFactory(audio.ProcessorConfig{
+		Stages:          audio.ParseStages(cfg.AudioProcessors),
+		SampleRate:      audio.SampleRate,
+		HighPassCutoff:  cfg.HighPassCutoffHz,
+		GateThresholdDB: cfg.NoiseGateDB,
+		AGCTargetDB:     cfg.AGCTargetDB,
+		AGCMaxGainDB:    cfg.AGCMaxGainDB,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid audio processing configuration: %w", err)
+	}
+
+	// Create VAD factory
+	vadFactory, err := audio.NewVADFactory(audio.VADConfig{
+		Type:           cfg.VADType,
+		SampleRate:     audio.SampleRate,
+		Aggressiveness: cfg.VADAggressiveness,
+		RMSThreshold:   cfg.VADRMSThreshold,
+		EnergyMarginDB: cfg.VADEnergyMarginDB,
+		PreRollFrames:  cfg.VADPreRollFrames,
+		HangoverFrames: cfg.VADHangoverFrames,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid VAD configuration: %w", err)
+	}
+
+	return processorFactory, vadFactory, nil
+}
+
 func (b *Bot) Start() error {
 	// Open connection
 	if err := b.session.Open(); err != nil {
@@ -99,6 +298,8 @@ func (b *Bot) Stop() error {
 	b.mutex.Lock()
 	for _, session := range b.sessions {
 		session.Stop()
+		// There's no time to write notes, but the audio is already paid for
+		session.recordTranscriptionUsage()
 	}
 	b.sessions = make(map[string]*VoiceSession)
 	b.mutex.Unlock()
@@ -109,8 +310,11 @@ func (b *Bot) Stop() error {
 	}
 
 	// Close transcriber
-	if b.transcriber != nil {
-		b.transcriber.Close()
+	if b.scheduler != nil {
+		b.scheduler.Close()
+	}
+	if b.streamer != nil {
+		b.streamer.Close()
 	}
 
 	// Close summariser
@@ -129,6 +333,21 @@ func (b *Bot) onReady(s *discordgo.Session, event *discordgo.Ready) {
 		Msg("Bot is ready")
 }
 
+func (b *Bot) onVoiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	if v == nil || v.VoiceState == nil {
+		return
+	}
+
+	// Forward to the active session in this guild, if any
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for _, session := range b.sessions {
+		if session.GuildID == v.GuildID {
+			session.HandleVoiceStateUpdate(v)
+		}
+	}
+}
+
 func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore bot messages
 	if m.Author.Bot {
@@ -143,6 +362,8 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 		b.handleJoin(s, m)
 	case strings.HasPrefix(content, "!leave"):
 		b.handleLeave(s, m)
+	case strings.HasPrefix(content, "!usage"):
+		b.handleUsage(s, m)
 	}
 }
 
@@ -178,29 +399,26 @@ func (b *Bot) handleJoin(s *discordgo.Session, m *discordgo.MessageCreate) {
 	}
 	b.mutex.RUnlock()
 
-	// Create new session
-	sessionID := store.GenerateSessionID()
-
-	// Create audio components
-	decoder, err := audio.NewOpusDecoder()
-	if err != nil {
-		b.sendError(s, m.ChannelID, "Failed to create audio decoder")
+	// Refuse to record once a monthly budget is used up
+	refusal, budgetWarning := b.checkBudget(m.GuildID)
+	if refusal != "" {
+		b.sendError(s, m.ChannelID, refusal)
 		return
 	}
 
-	vad, err := audio.NewWebRTCVAD()
+	// Create new session
+	sessionID := store.GenerateSessionID()
+
+	// Decoders, VADs and chunkers are created per speaker by the session
+	chunkerFactory, err := audio.NewChunkerFactory(chunkerConfig(b.config.ChunkSettingsForGuild(m.GuildID)))
 	if err != nil {
-		b.sendError(s, m.ChannelID, "Failed to create voice activity detector")
+		b.sendError(s, m.ChannelID, fmt.Sprintf("Invalid chunking configuration: %v", err))
 		return
 	}
 
-	chunker := audio.NewRingChunker(
-		b.config.ChunkSeconds,
-		b.config.ChunkOverlapMS,
-		audio.SampleRate,
-	)
-
-	transcriberPool := stt.NewTranscriberPool(b.transcriber, b.config.MaxParallelSTT)
+	// Sessions share the backend through the scheduler, so a busy guild
+	// can't starve the others or push the bot past MAX_PARALLEL_STT
+	transcriberPool := newTranscriberPool(b.config, b.scheduler.Session(m.GuildID, b.config.STTWeightForGuild(m.GuildID)))
 
 	session := NewVoiceSession(
 		sessionID,
@@ -209,13 +427,21 @@ func (b *Bot) handleJoin(s *discordgo.Session, m *discordgo.MessageCreate) {
 		m.ChannelID,
 		m.Author.ID,
 		s,
-		decoder,
-		vad,
-		chunker,
+		chunkerFactory,
+		b.processorFactory,
+		b.vadFactory,
 		transcriberPool,
 		b.summariser,
 		b.store,
+		b.config.AudioArchive,
+		b.config.MixRecording,
+		b.config.VoiceCapture,
 	)
+	if b.streamer != nil {
+		// Streams hold a stream slot each, so they count against MAX_PARALLEL_STREAMS
+		session.streamer = b.scheduler.Streams(b.streamer, m.GuildID, b.config.STTWeightForGuild(m.GuildID))
+	}
+	session.turnOptions = turnOptions(b.config)
 
 	// Start session
 	if err := session.Start(); err != nil {
@@ -229,7 +455,11 @@ func (b *Bot) handleJoin(s *discordgo.Session, m *discordgo.MessageCreate) {
 	b.mutex.Unlock()
 
 	// Send confirmation
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🎙️ Started recording in <#%s>. Use `!leave` to stop.", voiceChannelID))
+	confirmation := fmt.Sprintf("🎙️ Started recording in <#%s>. Use `!leave` to stop.", voiceChannelID)
+	if budgetWarning != "" {
+		confirmation += "\n" + budgetWarning
+	}
+	s.ChannelMessageSend(m.ChannelID, confirmation)
 
 	log.Info().
 		Str("session_id", sessionID).
@@ -271,7 +501,7 @@ func (b *Bot) handleLeave(s *discordgo.Session, m *discordgo.MessageCreate) {
 	processingMsg, _ := s.ChannelMessageSend(m.ChannelID, "⏳ Processing recording and generating notes...")
 
 	// Finalize and save
-	transcriptPath, notesPath, err := session.Finalize()
+	metadata, err := session.Finalize()
 	if err != nil {
 		b.sendError(s, m.ChannelID, fmt.Sprintf("Failed to process recording: %v", err))
 		return
@@ -281,36 +511,50 @@ func (b *Bot) handleLeave(s *discordgo.Session, m *discordgo.MessageCreate) {
 	s.ChannelMessageEdit(m.ChannelID, processingMsg.ID, "✅ Recording processed!")
 
 	// Send files
-	b.sendFiles(s, m.ChannelID, transcriptPath, notesPath)
+	b.sendFiles(s, m.ChannelID, metadata)
+	b.warnBudget(s, m.ChannelID, session.GuildID)
 
 	log.Info().
 		Str("session_id", session.ID).
-		Str("transcript_path", transcriptPath).
-		Str("notes_path", notesPath).
+		Str("transcript_path", metadata.TranscriptPath).
+		Str("notes_path", metadata.NotesPath).
+		Str("recording_path", metadata.RecordingPath).
 		Msg("Completed voice recording session")
 }
 
+// chunkerConfig converts configured chunk settings into an audio.ChunkerConfig.
+func chunkerConfig(settings config.ChunkSettings) audio.ChunkerConfig {
+	return audio.ChunkerConfig{
+		Strategy:     settings.Strategy,
+		SampleRate:   audio.SampleRate,
+		ChunkSeconds: settings.Seconds,
+		OverlapMS:    settings.OverlapMS,
+		MinChunk:     time.Duration(settings.MinMS) * time.Millisecond,
+		MaxChunk:     time.Duration(settings.MaxSeconds) * time.Second,
+		Hangover:     time.Duration(settings.HangoverMS) * time.Millisecond,
+	}
+}
+
 func (b *Bot) sendError(s *discordgo.Session, channelID, message string) {
 	s.ChannelMessageSend(channelID, "❌ "+message)
 	log.Warn().Str("channel_id", channelID).Str("error", message).Msg("Sent error message")
 }
 
-func (b *Bot) sendFiles(s *discordgo.Session, channelID, transcriptPath, notesPath string) {
+func (b *Bot) sendFiles(s *discordgo.Session, channelID string, metadata *store.SessionMetadata) {
 	// Read files
-	transcriptData, err := os.ReadFile(transcriptPath)
+	transcriptData, err := os.ReadFile(metadata.TranscriptPath)
 	if err != nil {
 		b.sendError(s, channelID, "Failed to read transcript file")
 		return
 	}
 
-	notesData, err := os.ReadFile(notesPath)
+	notesData, err := os.ReadFile(metadata.NotesPath)
 	if err != nil {
 		b.sendError(s, channelID, "Failed to read notes file")
 		return
 	}
 
-	// Send as message with files
-	_, err = s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+	message := &discordgo.MessageSend{
 		Content: "📝 Here are your meeting notes and transcript:",
 		Files: []*discordgo.File{
 			{
@@ -324,8 +568,37 @@ func (b *Bot) sendFiles(s *discordgo.Session, channelID, transcriptPath, notesPa
 				Reader:      strings.NewReader(string(notesData)),
 			},
 		},
-	})
+	}
 
+	// Attach the recording only if the whole message fits the upload limit
+	if metadata.RecordingPath != "" {
+		limit := int64(b.config.UploadLimitMB) << 20
+		size := int64(len(transcriptData) + len(notesData))
+
+		if info, err := os.Stat(metadata.RecordingPath); err != nil {
+			log.Warn().Err(err).Str("path", metadata.RecordingPath).Msg("Failed to stat meeting recording")
+		} else if size+info.Size() > limit {
+			message.Content += fmt.Sprintf("\n🔇 The recording (%.1f MB) is too large to upload and was saved on the server.", float64(info.Size())/(1<<20))
+		} else if file, err := os.Open(metadata.RecordingPath); err != nil {
+			log.Warn().Err(err).Str("path", metadata.RecordingPath).Msg("Failed to open meeting recording")
+		} else {
+			defer file.Close()
+
+			contentType := "audio/ogg"
+			if filepath.Ext(metadata.RecordingPath) == ".wav" {
+				contentType = "audio/wav"
+			}
+			message.Content = "📝 Here are your meeting notes, transcript and recording:"
+			message.Files = append(message.Files, &discordgo.File{
+				Name:        filepath.Base(metadata.RecordingPath),
+				ContentType: contentType,
+				Reader:      file,
+			})
+		}
+	}
+
+	// Send as message with files
+	_, err = s.ChannelMessageSendComplex(channelID, message)
 	if err != nil {
 		b.sendError(s, channelID, "Failed to send files")
 	}
@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/user/discord-notetaker/internal/config"
+	"github.com/user/discord-notetaker/internal/stt/vosk"
+)
+
+func TestResolveSTTBackend(t *testing.T) {
+	buildDefault := "deepgram"
+	if vosk.Available {
+		buildDefault = "vosk"
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "named backend is kept",
+			cfg:  config.Config{STTBackend: "whisper"},
+			want: "whisper",
+		},
+		{
+			name: "unset picks the build's default",
+			cfg:  config.Config{VoskModelPath: "./models/vosk/en", DeepgramAPIKey: "key", DeepgramBaseURL: "https://api.deepgram.com", DeepgramLanguage: "en"},
+			want: buildDefault,
+		},
+		{
+			name:    "default is checked like a named backend",
+			cfg:     config.Config{},
+			wantErr: true,
+		},
+		{
+			name:    "fallback repeating the default",
+			cfg:     config.Config{STTFallback: []string{buildDefault}, VoskModelPath: "./models/vosk/en", DeepgramAPIKey: "key", DeepgramBaseURL: "https://api.deepgram.com", DeepgramLanguage: "en"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			err := resolveSTTBackend(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveSTTBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.STTBackend != tt.want {
+				t.Errorf("STTBackend = %q, want %q", cfg.STTBackend, tt.want)
+			}
+		})
+	}
+}
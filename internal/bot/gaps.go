@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// gapMergeWindow joins lost chunks this close together into one gap, so a
+// backend outage reads as one line rather than one per chunk.
+const gapMergeWindow = 2 * time.Second
+
+// formatGaps renders the audio that was never transcribed as a section to
+// append to the notes, with speakers shown by name. Returns "" when nothing
+// was lost.
+func formatGaps(lost []audio.LostChunk, name func(userID string) string) string {
+	if len(lost) == 0 {
+		return ""
+	}
+
+	sorted := make([]audio.LostChunk, len(lost))
+	copy(sorted, lost)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	// Overlapping chunks of the same cause become one gap
+	var gaps []audio.LostChunk
+	for _, chunk := range sorted {
+		if n := len(gaps); n > 0 && gaps[n-1].Reason == chunk.Reason && !chunk.Start.After(gaps[n-1].End.Add(gapMergeWindow)) {
+			last := &gaps[n-1]
+			if chunk.End.After(last.End) {
+				last.End = chunk.End
+			}
+			last.Speakers = appendUnique(last.Speakers, chunk.Speakers...)
+			continue
+		}
+		chunk.Speakers = appendUnique(nil, chunk.Speakers...)
+		gaps = append(gaps, chunk)
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\n## Transcription gaps\n\n")
+	b.WriteString("Some audio could not be transcribed, so these notes may be missing what was said:\n\n")
+	for _, gap := range gaps {
+		names := make([]string, 0, len(gap.Speakers))
+		for _, speaker := range gap.Speakers {
+			names = append(names, name(speaker))
+		}
+
+		who := ""
+		if len(names) > 0 {
+			who = fmt.Sprintf(" (%s)", strings.Join(names, ", "))
+		}
+
+		fmt.Fprintf(&b, "- %s–%s%s: %s\n",
+			gap.Start.Format("15:04:05"), gap.End.Format("15:04:05"), who, gap.Reason)
+	}
+
+	return b.String()
+}
+
+// appendUnique appends the values not already in list.
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,303 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/audio"
+	"github.com/user/discord-notetaker/internal/stt"
+)
+
+// speakerPipeline holds the stateful audio components for a single SSRC.
+// Opus decoders and VADs carry state between frames, so every speaker needs
+// their own instances to avoid corrupting each other's audio.
+type speakerPipeline struct {
+	jitter    *audio.JitterBuffer
+	decoder   audio.AudioDecoder
+	processor audio.Processor // Gain, filtering and gating before VAD
+	vad       *audio.VADGate  // VAD with pre-roll and hangover
+	chunker   audio.Chunker   // Nil when streaming
+	handoff   *chunkHandoff   // Chunks waiting for the transcriber, nil when streaming
+	stream    stt.Stream      // Live transcription, nil when chunking
+	clock     *audio.RTPClock
+	archive   *audio.SpeakerArchive // Optional raw audio recording
+	mixer     *audio.Mixer          // Optional session mixdown, shared by all speakers
+	speakers  []string              // Speakers of the most recent packet
+	streamed  time.Duration         // Speech sent to the stream, for usage accounting
+	lost      []audio.LostChunk     // Speech the stream refused
+	closed    bool
+	mutex     sync.Mutex
+}
+
+// newSpeakerPipeline creates the decoder, processing chain and VAD for a
+// speaker whose speech is fed to chunker. Streaming pipelines pass a nil
+// chunker and set stream instead.
+func newSpeakerPipeline(chunker audio.Chunker, processorFactory audio.ProcessorFactory, vadFactory audio.VADFactory) (*speakerPipeline, error) {
+	// Decoder and VAD are stateful, so each speaker gets fresh instances
+	decoder, err := audio.NewOpusDecoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio decoder: %w", err)
+	}
+
+	vad, err := vadFactory.NewVADGate()
+	if err != nil {
+		decoder.Close()
+		return nil, fmt.Errorf("failed to create voice activity detector: %w", err)
+	}
+
+	return &speakerPipeline{
+		jitter:    audio.NewJitterBuffer(audio.DefaultJitterDepth, audio.DefaultMaxConcealFrames),
+		decoder:   decoder,
+		processor: processorFactory.NewProcessor(),
+		vad:       vad,
+		chunker:   chunker,
+		clock:     audio.NewRTPClock(audio.SampleRate, audio.DefaultMaxClockLag),
+	}, nil
+}
+
+// process queues a single Opus packet in the jitter buffer, then decodes
+// every frame released in sequence order and feeds it to the chunker if the
+// VAD classifies it as speech. Returns the number of speech frames.
+func (p *speakerPipeline) process(packet *discordgo.Packet, arrival time.Time, speakers []string) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return 0, nil
+	}
+
+	p.speakers = speakers
+	frames := p.jitter.Push(audio.RTPPacket{
+		Sequence:  packet.Sequence,
+		Timestamp: packet.Timestamp,
+		Opus:      packet.Opus,
+		Arrival:   arrival,
+	})
+
+	return p.processFrames(frames)
+}
+
+func (p *speakerPipeline) processFrames(frames []audio.JitterFrame) (int, error) {
+	var lastErr error
+	speechFrames := 0
+
+	for _, frame := range frames {
+		timestamp := p.clock.Timestamp(frame.Timestamp, frame.Sequence, frame.Arrival)
+
+		pcm, err := p.decodeFrame(frame)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// Archive the raw decoded audio before processing alters it
+		if p.archive != nil {
+			if err := p.archive.WriteFrame(frame.Opus, pcm, timestamp); err != nil {
+				log.Warn().Err(err).Str("path", p.archive.Path()).Msg("Failed to archive audio frame")
+			}
+		}
+
+		p.processor.Process(pcm)
+
+		if p.mixer != nil {
+			if err := p.mixer.Add(pcm, timestamp); err != nil {
+				log.Warn().Err(err).Msg("Failed to mix audio frame")
+			}
+		}
+
+		for _, speech := range p.vad.Push(pcm, timestamp) {
+			if p.stream != nil {
+				length := time.Duration(len(speech.PCM)) * time.Second / audio.SampleRate
+				if err := p.stream.Write(speech.PCM, speech.Timestamp); err != nil {
+					p.addLost(speech.Timestamp, length, err)
+				} else {
+					p.streamed += length
+				}
+			} else {
+				p.chunker.AddSamples(speech.PCM, speech.Timestamp, p.speakers)
+			}
+			speechFrames++
+		}
+	}
+
+	return speechFrames, lastErr
+}
+
+// decodeFrame decodes a received frame, or rebuilds a lost one from the
+// next packet's FEC data, falling back to packet loss concealment.
+func (p *speakerPipeline) decodeFrame(frame audio.JitterFrame) ([]int16, error) {
+	if !frame.Lost {
+		return p.decoder.Decode(frame.Opus)
+	}
+
+	if frame.FEC != nil {
+		if pcm, err := p.decoder.DecodeFEC(frame.FEC); err == nil {
+			p.jitter.RecordRecovery(true)
+			return pcm, nil
+		}
+	}
+
+	pcm, err := p.decoder.DecodePLC()
+	if err != nil {
+		return nil, err
+	}
+	p.jitter.RecordRecovery(false)
+	return pcm, nil
+}
+
+// addLost records speech the stream refused, warning once per run of
+// refused frames rather than for each.
+func (p *speakerPipeline) addLost(timestamp time.Time, length time.Duration, err error) {
+	before := len(p.lost)
+	p.lost = audio.AppendLost(p.lost, audio.LostChunk{
+		Start:    timestamp,
+		End:      timestamp.Add(length),
+		Speakers: p.speakers,
+		Reason:   "transcription stream fell behind",
+	})
+	if len(p.lost) > before {
+		log.Warn().Err(err).Time("at", timestamp).Msg("Transcription stream refused speech, dropping it")
+	}
+}
+
+// lostAudio returns the speech the stream refused.
+func (p *speakerPipeline) lostAudio() []audio.LostChunk {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	lost := make([]audio.LostChunk, len(p.lost))
+	copy(lost, p.lost)
+	return lost
+}
+
+// stats returns the packet statistics of this pipeline's jitter buffer.
+func (p *speakerPipeline) stats() audio.JitterStats {
+	return p.jitter.Stats()
+}
+
+// streamedAudio returns how much speech has been sent to the stream.
+func (p *speakerPipeline) streamedAudio() time.Duration {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.streamed
+}
+
+// close drains the jitter buffer, flushes the chunker or stream, finalises
+// the archive and releases the decoder and VAD.
+func (p *speakerPipeline) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+
+	if _, err := p.processFrames(p.jitter.Flush()); err != nil {
+		log.Warn().Err(err).Msg("Failed to decode buffered frames")
+	}
+
+	if p.chunker != nil {
+		p.chunker.Stop()
+	}
+	if p.stream != nil {
+		p.stream.Close()
+	}
+	if p.archive != nil {
+		if err := p.archive.Close(); err != nil {
+			log.Warn().Err(err).Str("path", p.archive.Path()).Msg("Failed to close audio archive")
+		}
+	}
+	p.decoder.Close()
+	if err := p.vad.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close VAD")
+	}
+}
+
+// maxHandoffChunks bounds the chunks a speaker keeps in memory waiting for
+// the transcriber, about a minute and a half of 5 second chunks.
+const maxHandoffChunks = 16
+
+// chunkHandoff queues a speaker's chunks between their chunker and the
+// transcriber pool. Chunkers run on the packet loop, so they hand chunks
+// over without waiting; the queue takes whatever the pool can't yet, and a
+// forwarder submits them in order at the pool's pace. Once limit chunks are
+// waiting, the queue is handed to overflow in order, oldest first, so a
+// stalled pool can't grow it without bound.
+type chunkHandoff struct {
+	chunks   []*audio.Chunk
+	limit    int
+	overflow func(chunk *audio.Chunk)
+	closed   bool
+	mutex    sync.Mutex
+	cond     *sync.Cond
+}
+
+func newChunkHandoff(limit int, overflow func(chunk *audio.Chunk)) *chunkHandoff {
+	h := &chunkHandoff{limit: limit, overflow: overflow}
+	h.cond = sync.NewCond(&h.mutex)
+	return h
+}
+
+// push queues a chunk. It never waits on the forwarder.
+func (h *chunkHandoff) push(chunk *audio.Chunk) {
+	h.mutex.Lock()
+	h.chunks = append(h.chunks, chunk)
+	if len(h.chunks) < h.limit {
+		h.mutex.Unlock()
+		h.cond.Signal()
+		return
+	}
+
+	overflowed := h.chunks
+	h.chunks = nil
+	h.mutex.Unlock()
+
+	for _, chunk := range overflowed {
+		h.overflow(chunk)
+	}
+}
+
+// pop waits for the oldest chunk. ok is false once the handoff is closed
+// and empty.
+func (h *chunkHandoff) pop() (chunk *audio.Chunk, ok bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for len(h.chunks) == 0 && !h.closed {
+		h.cond.Wait()
+	}
+	if len(h.chunks) == 0 {
+		return nil, false
+	}
+
+	chunk = h.chunks[0]
+	h.chunks[0] = nil
+	h.chunks = h.chunks[1:]
+	return chunk, true
+}
+
+// len returns how many chunks are waiting.
+func (h *chunkHandoff) len() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.chunks)
+}
+
+// close lets pop return false once the queued chunks are taken.
+func (h *chunkHandoff) close() {
+	h.mutex.Lock()
+	h.closed = true
+	h.mutex.Unlock()
+	h.cond.Broadcast()
+}
+
+// SpeakerStats reports packet statistics for a single SSRC.
+type SpeakerStats struct {
+	SSRC   uint32 `json:"ssrc"`
+	UserID string `json:"user_id"`
+	audio.JitterStats
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+func TestChunkHandoffNeverBlocksAndKeepsOrder(t *testing.T) {
+	var overflowed []*audio.Chunk
+	handoff := newChunkHandoff(10, func(chunk *audio.Chunk) {
+		overflowed = append(overflowed, chunk)
+	})
+
+	// Nothing is taking chunks, so pushes must queue rather than wait, and
+	// the queue overflows each time it fills
+	const pushed = 25
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < pushed; i++ {
+		handoff.push(&audio.Chunk{Start: start.Add(time.Duration(i) * time.Second)})
+	}
+	handoff.close()
+
+	if len(overflowed) != 20 {
+		t.Fatalf("%d chunks overflowed, want 20", len(overflowed))
+	}
+	if n := handoff.len(); n != pushed-20 {
+		t.Fatalf("len() = %d, want %d", n, pushed-20)
+	}
+
+	// Overflowed chunks come first, then the ones still queued
+	all := overflowed
+	for {
+		chunk, ok := handoff.pop()
+		if !ok {
+			break
+		}
+		all = append(all, chunk)
+	}
+	if len(all) != pushed {
+		t.Fatalf("got %d chunks back, want %d", len(all), pushed)
+	}
+	for i, chunk := range all {
+		if want := start.Add(time.Duration(i) * time.Second); !chunk.Start.Equal(want) {
+			t.Fatalf("chunk %d starts at %s, want %s", i, chunk.Start, want)
+		}
+	}
+}
+
+func TestChunkHandoffPopWaitsForPush(t *testing.T) {
+	handoff := newChunkHandoff(maxHandoffChunks, nil)
+	popped := make(chan bool)
+	go func() {
+		_, ok := handoff.pop()
+		popped <- ok
+	}()
+
+	select {
+	case <-popped:
+		t.Fatal("pop() returned from an empty handoff")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	handoff.push(&audio.Chunk{})
+	if ok := <-popped; !ok {
+		t.Error("pop() = false, want the pushed chunk")
+	}
+}
@@ -0,0 +1,487 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/audio"
+	"github.com/user/discord-notetaker/internal/capture"
+	"github.com/user/discord-notetaker/internal/config"
+	"github.com/user/discord-notetaker/internal/store"
+	"github.com/user/discord-notetaker/internal/summariser/gemini"
+)
+
+// ReplayTrack is one speaker's recorded audio.
+type ReplayTrack struct {
+	Path     string `json:"path"`
+	UserID   string `json:"user_id,omitempty"`   // Defaults to the file name
+	UserTag  string `json:"name,omitempty"`      // Display name in the transcript
+	OffsetMS int    `json:"offset_ms,omitempty"` // Where the track starts on the session timeline
+}
+
+// ReplayManifest describes a multi-track recording to replay.
+type ReplayManifest struct {
+	SessionID string        `json:"session_id,omitempty"`
+	GuildID   string        `json:"guild_id,omitempty"` // Selects per-guild chunk settings
+	StartedAt time.Time     `json:"started_at,omitempty"`
+	Tracks    []ReplayTrack `json:"tracks"`
+}
+
+// LoadReplayManifest reads a replay manifest. A session's metadata.json is
+// accepted too, replaying the speaker archives recorded with it. Relative
+// track paths are resolved against the manifest's directory.
+func LoadReplayManifest(path string) (*ReplayManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest ReplayManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+
+	if len(manifest.Tracks) == 0 {
+		var metadata store.SessionMetadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest: %w", err)
+		}
+
+		// Keep the original session's results; the replay gets a new ID
+		manifest.SessionID = ""
+		manifest.GuildID = metadata.GuildID
+		manifest.StartedAt = metadata.StartedAt
+
+		// Archives always live next to their metadata
+		for _, archive := range metadata.Archives {
+			manifest.Tracks = append(manifest.Tracks, ReplayTrack{
+				Path:   filepath.Join(dir, filepath.Base(archive.Path)),
+				UserID: archive.UserID,
+			})
+		}
+	}
+
+	if len(manifest.Tracks) == 0 {
+		return nil, fmt.Errorf("manifest has no tracks")
+	}
+
+	for i := range manifest.Tracks {
+		if !filepath.IsAbs(manifest.Tracks[i].Path) {
+			manifest.Tracks[i].Path = filepath.Join(dir, manifest.Tracks[i].Path)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// replayTrack is a track being fed into a session as one SSRC.
+type replayTrack struct {
+	ReplayTrack
+	reader audio.PacketReader
+	ssrc   uint32
+	done   bool
+}
+
+// Replay runs recorded speaker tracks through an offline voice session, with
+// the same decoding, VAD, chunking, transcription and summarisation as a
+// live one, and saves the transcript and notes. Audio is processed as fast
+// as the transcriber keeps up rather than in real time.
+func Replay(ctx context.Context, cfg *config.Config, manifest *ReplayManifest) (*store.SessionMetadata, error) {
+	// Open every track before doing any work so a bad path fails fast
+	tracks := make([]*replayTrack, 0, len(manifest.Tracks))
+	defer func() {
+		for _, track := range tracks {
+			track.reader.Close()
+		}
+	}()
+
+	for i, t := range manifest.Tracks {
+		reader, err := audio.OpenTrack(t.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open track %s: %w", t.Path, err)
+		}
+
+		if t.UserID == "" {
+			t.UserID = strings.TrimSuffix(filepath.Base(t.Path), filepath.Ext(t.Path))
+		}
+
+		tracks = append(tracks, &replayTrack{ReplayTrack: t, reader: reader, ssrc: uint32(i + 1)})
+	}
+
+	session, closeSession, err := newOfflineSession(cfg, manifest.SessionID, manifest.GuildID, "")
+	if err != nil {
+		return nil, err
+	}
+	defer closeSession()
+
+	startedAt := manifest.StartedAt
+	if startedAt.IsZero() {
+		startedAt = time.Now()
+	}
+
+	log.Info().
+		Str("session_id", session.ID).
+		Int("tracks", len(tracks)).
+		Msg("Replaying recorded session")
+
+	replayErr := session.ReplayTracks(ctx, tracks, startedAt)
+	session.Stop()
+	if replayErr != nil {
+		return nil, replayErr
+	}
+
+	return session.Finalize()
+}
+
+// ReplayTracks feeds recorded speaker tracks into a session created without
+// a Discord connection, each as its own SSRC mapped to the track's user.
+// It returns once every chunk has been transcribed; Stop and Finalize the
+// session afterwards as for a live one.
+func (vs *VoiceSession) ReplayTracks(ctx context.Context, tracks []*replayTrack, startedAt time.Time) error {
+	if err := vs.startReplay(startedAt); err != nil {
+		return err
+	}
+
+	vs.speakerMux.Lock()
+	vs.membersMux.Lock()
+	for _, track := range tracks {
+		vs.speakerMap[track.ssrc] = track.UserID
+		vs.replayMembers[track.UserID] = true
+		if track.UserTag != "" {
+			vs.replayNames[track.UserID] = track.UserTag
+		}
+	}
+	vs.membersMux.Unlock()
+	vs.speakerMux.Unlock()
+
+	replayed := vs.feedTracks(ctx, tracks, startedAt)
+	vs.endedAt = startedAt.Add(replayed)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("session_id", vs.ID).
+		Dur("audio", replayed).
+		Msg("Replayed recorded tracks, waiting for transcription")
+
+	vs.drain()
+	return nil
+}
+
+// feedTracks pushes every track's packets into the session, one 20ms frame
+// from each track at a time so speakers advance together along the
+// timeline. Packets get synthetic RTP headers and arrival times as if they
+// had been received live. Returns the length of the longest track.
+func (vs *VoiceSession) feedTracks(ctx context.Context, tracks []*replayTrack, startedAt time.Time) time.Duration {
+	var longest time.Duration
+
+	for frame := 0; ; frame++ {
+		if ctx.Err() != nil {
+			return longest
+		}
+
+		active := 0
+		for _, track := range tracks {
+			if track.done {
+				continue
+			}
+
+			opus, err := track.reader.ReadPacket()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					log.Warn().
+						Str("session_id", vs.ID).
+						Str("track", track.Path).
+						Err(err).
+						Msg("Failed to read track, ending it early")
+				}
+				track.done = true
+				continue
+			}
+			active++
+
+			offset := time.Duration(track.OffsetMS)*time.Millisecond + time.Duration(frame)*20*time.Millisecond
+			if end := offset + 20*time.Millisecond; end > longest {
+				longest = end
+			}
+
+			vs.waitForTranscriber(ctx)
+			vs.processAudioPacket(&discordgo.Packet{
+				SSRC:      track.ssrc,
+				Sequence:  uint16(frame),
+				Timestamp: uint32(frame * audio.FrameSize),
+				Opus:      opus,
+			}, startedAt.Add(offset))
+		}
+
+		if active == 0 {
+			return longest
+		}
+	}
+}
+
+// replayBacklog is how many chunks a speaker may have waiting before a
+// capture replay pauses for the transcriber to catch up.
+const replayBacklog = 4
+
+// ReplayCapture feeds a raw voice capture into a session created without a
+// Discord connection, as if it were arriving on OpusRecv: packets keep
+// their captured arrival times, and speaking and voice state updates drive
+// speaker mapping. speed scales the pacing, 1 being real time and 0 as fast
+// as possible. It returns once every chunk has been transcribed; Stop and
+// Finalize the session afterwards as for a live one.
+func (vs *VoiceSession) ReplayCapture(ctx context.Context, reader *capture.Reader, speed float64) error {
+	if err := vs.startReplay(reader.Header().StartedAt); err != nil {
+		return err
+	}
+
+	var first time.Time
+	wallStart := time.Now()
+	events := 0
+
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if speed > 0 {
+			if first.IsZero() {
+				first = event.At
+			}
+			due := wallStart.Add(time.Duration(float64(event.At.Sub(first)) / speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch event.Kind {
+		case capture.KindPacket:
+			vs.waitForTranscriber(ctx)
+			vs.processAudioPacket(event.Packet(), event.At)
+		case capture.KindSpeaking:
+			vs.handleSpeakingUpdate(nil, event.SpeakingUpdate())
+		case capture.KindVoiceState:
+			vs.replayVoiceState(event)
+		default:
+			log.Warn().Str("session_id", vs.ID).Str("kind", event.Kind).Msg("Skipping unknown capture event")
+			continue
+		}
+
+		vs.endedAt = event.At
+		events++
+	}
+
+	log.Info().
+		Str("session_id", vs.ID).
+		Int("events", events).
+		Msg("Replayed voice capture, waiting for transcription")
+
+	vs.drain()
+	return nil
+}
+
+// startReplay starts an offline session whose timeline begins at startedAt.
+func (vs *VoiceSession) startReplay(startedAt time.Time) error {
+	if vs.session != nil {
+		return fmt.Errorf("replay needs a session without a Discord connection")
+	}
+
+	vs.mutex.Lock()
+	if vs.stopped {
+		vs.mutex.Unlock()
+		return fmt.Errorf("session already stopped")
+	}
+	vs.replaying = true
+	vs.replayMembers = make(map[string]bool)
+	vs.replayNames = make(map[string]string)
+	vs.startedAt = startedAt
+	vs.createRecorders()
+	vs.mutex.Unlock()
+
+	if err := vs.transcriber.Start(vs.ctx); err != nil {
+		return fmt.Errorf("failed to start transcriber: %w", err)
+	}
+	go vs.processUtterances()
+	return nil
+}
+
+// replayVoiceState applies a captured voice channel membership change.
+func (vs *VoiceSession) replayVoiceState(event *capture.Event) {
+	vs.membersMux.Lock()
+	if event.ChannelID == vs.ChannelID {
+		vs.replayMembers[event.UserID] = true
+	} else {
+		delete(vs.replayMembers, event.UserID)
+	}
+	vs.membersMux.Unlock()
+
+	update := &discordgo.VoiceStateUpdate{
+		VoiceState: &discordgo.VoiceState{
+			GuildID:   vs.GuildID,
+			ChannelID: event.ChannelID,
+			UserID:    event.UserID,
+		},
+	}
+	if event.BeforeChannelID != "" {
+		update.BeforeUpdate = &discordgo.VoiceState{
+			GuildID:   vs.GuildID,
+			ChannelID: event.BeforeChannelID,
+			UserID:    event.UserID,
+		}
+	}
+	vs.HandleVoiceStateUpdate(update)
+}
+
+// waitForTranscriber pauses while any speaker's chunks are backing up, so a
+// fast replay doesn't queue the whole capture in memory.
+func (vs *VoiceSession) waitForTranscriber(ctx context.Context) {
+	for ctx.Err() == nil {
+		backlog := 0
+		vs.speakerMux.RLock()
+		for _, pipeline := range vs.speakerPipelines {
+			if pipeline.handoff == nil {
+				continue
+			}
+			if n := pipeline.handoff.len(); n > backlog {
+				backlog = n
+			}
+		}
+		vs.speakerMux.RUnlock()
+
+		if backlog < replayBacklog {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// drain flushes every speaker pipeline and waits until all of their chunks
+// have been transcribed.
+func (vs *VoiceSession) drain() {
+	vs.speakerMux.Lock()
+	for ssrc := range vs.speakerPipelines {
+		vs.closePipelineLocked(ssrc)
+	}
+	vs.speakerMux.Unlock()
+
+	vs.chunkWG.Wait()
+	vs.transcriber.Drain()
+	<-vs.utterancesDone
+}
+
+// ReplayCaptureFile replays a raw voice capture through a new offline
+// session and saves its transcript and notes. The results get sessionID, or
+// a new ID if it is empty, so the original session's files are kept.
+func ReplayCaptureFile(ctx context.Context, cfg *config.Config, path, sessionID string, speed float64) (*store.SessionMetadata, error) {
+	reader, err := capture.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	header := reader.Header()
+
+	session, closeSession, err := newOfflineSession(cfg, sessionID, header.GuildID, header.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSession()
+
+	log.Info().
+		Str("session_id", session.ID).
+		Str("captured_session_id", header.SessionID).
+		Str("file", path).
+		Float64("speed", speed).
+		Msg("Replaying voice capture")
+
+	replayErr := session.ReplayCapture(ctx, reader, speed)
+	session.Stop()
+	if replayErr != nil {
+		return nil, replayErr
+	}
+
+	return session.Finalize()
+}
+
+// newOfflineSession creates a session without a Discord connection for
+// replaying recorded audio, with its own store, summariser and transcriber.
+// An empty sessionID gets a new one, so the original session's files are
+// kept. Call closeSession once it is finalized.
+func newOfflineSession(cfg *config.Config, sessionID, guildID, channelID string) (session *VoiceSession, closeSession func(), err error) {
+	fileStore, err := store.NewFileStore("./data")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create store: %w", err)
+	}
+
+	processorFactory, vadFactory, err := newAudioFactories(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chunkerFactory, err := audio.NewChunkerFactory(chunkerConfig(cfg.ChunkSettingsForGuild(guildID)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid chunking configuration: %w", err)
+	}
+
+	summariser, err := gemini.NewGeminiSummariser(cfg.GenAIAPIKey, cfg.GenAIModel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create summariser: %w", err)
+	}
+
+	transcriber, err := newTranscriber(cfg)
+	if err != nil {
+		summariser.Close()
+		return nil, nil, err
+	}
+
+	if sessionID == "" {
+		sessionID = store.GenerateSessionID()
+	}
+
+	session = NewVoiceSession(
+		sessionID,
+		guildID,
+		channelID,
+		"",
+		"",
+		nil,
+		chunkerFactory,
+		processorFactory,
+		vadFactory,
+		newTranscriberPool(cfg, transcriber),
+		summariser,
+		fileStore,
+		cfg.AudioArchive,
+		cfg.MixRecording,
+		false,
+	)
+	session.turnOptions = turnOptions(cfg)
+
+	closeSession = func() {
+		transcriber.Close()
+		summariser.Close()
+	}
+	return session, closeSession, nil
+}
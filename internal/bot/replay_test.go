@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"context"
+	"flag"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+	"github.com/user/discord-notetaker/internal/audio"
+	"github.com/user/discord-notetaker/internal/capture"
+	"github.com/user/discord-notetaker/internal/config"
+	"layeh.com/gopus"
+)
+
+var update = flag.Bool("update", false, "regenerate the capture fixture in testdata")
+
+// twoSpeakersCapture holds two speakers taking turns: alice talks for 1.5s
+// from 0.2s, then bob for 1.5s from 3.2s, and bob leaves afterwards.
+var twoSpeakersCapture = filepath.Join("testdata", "two_speakers.jsonl.gz")
+
+var captureStart = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+const (
+	captureGuild   = "guild"
+	captureChannel = "voice"
+)
+
+// writeTwoSpeakersCapture records the fixture as a live session would:
+// speaking updates, then each speaker's packets at their arrival times.
+func writeTwoSpeakersCapture(t *testing.T, path string) {
+	t.Helper()
+
+	encoder, err := gopus.NewEncoder(audio.SampleRate, audio.Channels, gopus.Voip)
+	if err != nil {
+		t.Fatalf("failed to create opus encoder: %v", err)
+	}
+
+	w, err := capture.NewWriter(path, capture.Header{
+		SessionID: "fixture",
+		GuildID:   captureGuild,
+		ChannelID: captureChannel,
+		StartedAt: captureStart,
+	})
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	speak := func(userID string, ssrc uint32, at time.Time) {
+		t.Helper()
+		if err := w.WriteSpeaking(&discordgo.VoiceSpeakingUpdate{UserID: userID, SSRC: int(ssrc), Speaking: true}, at); err != nil {
+			t.Fatalf("WriteSpeaking failed: %v", err)
+		}
+
+		// Quiet frames either side of 1.5s of voiced sound
+		for i := 0; i < 95; i++ {
+			opus := audio.OpusSilenceFrame
+			if i >= 10 && i < 85 {
+				pcm := make([]int16, audio.FrameSize)
+				for j := range pcm {
+					n := float64(i*audio.FrameSize + j)
+					// A 150Hz voice with a couple of harmonics
+					v := math.Sin(2*math.Pi*150*n/audio.SampleRate) +
+						0.5*math.Sin(2*math.Pi*300*n/audio.SampleRate) +
+						0.25*math.Sin(2*math.Pi*450*n/audio.SampleRate)
+					pcm[j] = int16(6000 * v)
+				}
+				if opus, err = encoder.Encode(pcm, audio.FrameSize, 4000); err != nil {
+					t.Fatalf("failed to encode frame: %v", err)
+				}
+			}
+
+			packet := &discordgo.Packet{
+				SSRC:      ssrc,
+				Sequence:  uint16(1000 + i),
+				Timestamp: uint32(50000 + i*audio.FrameSize),
+				Opus:      opus,
+			}
+			if err := w.WritePacket(packet, at.Add(time.Duration(i)*20*time.Millisecond)); err != nil {
+				t.Fatalf("WritePacket failed: %v", err)
+			}
+		}
+	}
+
+	speak("alice", 11, captureStart)
+	speak("bob", 22, captureStart.Add(3*time.Second))
+
+	if err := w.WriteVoiceState("bob", "", captureChannel, captureStart.Add(5*time.Second)); err != nil {
+		t.Fatalf("WriteVoiceState failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+// echoTranscriber "hears" each chunk as one utterance spanning it.
+type echoTranscriber struct{}
+
+func (echoTranscriber) Transcribe(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+	var speaker string
+	if len(chunk.Speakers) > 0 {
+		speaker = chunk.Speakers[0]
+	}
+	return []audio.Utterance{{
+		ID:      uuid.New(),
+		TSStart: chunk.Start,
+		TSEnd:   chunk.End,
+		UserID:  speaker,
+		UserTag: speaker,
+		Text:    "speech",
+		Source:  "echo",
+	}}, nil
+}
+
+func (echoTranscriber) SampleRate() int { return 0 }
+
+func (echoTranscriber) Close() error { return nil }
+
+func TestReplayCaptureFixture(t *testing.T) {
+	if *update {
+		writeTwoSpeakersCapture(t, twoSpeakersCapture)
+	}
+
+	reader, err := capture.Open(twoSpeakersCapture)
+	if err != nil {
+		t.Fatalf("failed to open fixture (run with -update to create it): %v", err)
+	}
+	defer reader.Close()
+
+	cfg := &config.Config{
+		AudioProcessors:   "",
+		VADType:           audio.VADTypeEnergy,
+		VADRMSThreshold:   500,
+		VADEnergyMarginDB: 10,
+		VADPreRollFrames:  0,
+		VADHangoverFrames: 10,
+		MaxParallelSTT:    2,
+	}
+	processorFactory, vadFactory, err := newAudioFactories(cfg)
+	if err != nil {
+		t.Fatalf("newAudioFactories failed: %v", err)
+	}
+	chunkerFactory, err := audio.NewChunkerFactory(chunkerConfig(config.ChunkSettings{
+		Strategy: "endpoint", Seconds: 5, MinMS: 500, MaxSeconds: 15, HangoverMS: 600,
+	}))
+	if err != nil {
+		t.Fatalf("NewChunkerFactory failed: %v", err)
+	}
+
+	header := reader.Header()
+	session := NewVoiceSession("replay", header.GuildID, header.ChannelID, "", "", nil,
+		chunkerFactory, processorFactory, vadFactory,
+		newTranscriberPool(cfg, echoTranscriber{}), nil, nil,
+		audio.ArchiveNone, audio.ArchiveNone, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := session.ReplayCapture(ctx, reader, 0); err != nil {
+		t.Fatalf("ReplayCapture failed: %v", err)
+	}
+	session.Stop()
+
+	session.utterancesMux.RLock()
+	utterances := append([]audio.Utterance(nil), session.utterances...)
+	session.utterancesMux.RUnlock()
+
+	tests := []struct {
+		userID string
+		start  time.Time // When the voiced sound starts
+	}{
+		{"alice", captureStart.Add(200 * time.Millisecond)},
+		{"bob", captureStart.Add(3*time.Second + 200*time.Millisecond)},
+	}
+	if len(utterances) != len(tests) {
+		t.Fatalf("got %d utterances, want one per speaker: %+v", len(utterances), utterances)
+	}
+
+	const tolerance = 100 * time.Millisecond
+	for i, tt := range tests {
+		utt := utterances[i]
+		if utt.UserID != tt.userID {
+			t.Errorf("utterance %d from %q, want %q", i, utt.UserID, tt.userID)
+		}
+		if diff := utt.TSStart.Sub(tt.start); diff < -tolerance || diff > tolerance {
+			t.Errorf("utterance %d starts at %s, want about %s", i, utt.TSStart, tt.start)
+		}
+		if d := utt.TSEnd.Sub(utt.TSStart); d < time.Second || d > 3*time.Second {
+			t.Errorf("utterance %d lasts %s, want about the 1.5s spoken", i, d)
+		}
+	}
+
+	if got := session.transcribedAudio(); got < 3*time.Second {
+		t.Errorf("transcribed %s of audio, want both speakers' turns", got)
+	}
+}
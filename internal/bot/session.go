@@ -3,18 +3,27 @@ package bot
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/assembler"
 	"github.com/user/discord-notetaker/internal/audio"
+	"github.com/user/discord-notetaker/internal/capture"
 	"github.com/user/discord-notetaker/internal/store"
 	"github.com/user/discord-notetaker/internal/stt"
 	"github.com/user/discord-notetaker/internal/summariser/gemini"
 	"golang.org/x/sync/errgroup"
 )
 
+// deadLetterTimeout bounds the final retry of failed chunks when a session
+// is finalized.
+const deadLetterTimeout = 2 * time.Minute
+
 type VoiceSession struct {
 	ID            string
 	GuildID       string
@@ -22,27 +31,54 @@ type VoiceSession struct {
 	TextChannelID string
 	UserID        string // User who initiated the session
 
-	// Audio pipeline components (used as templates)
-	decoder     audio.AudioDecoder
-	vad         audio.VAD
+	// Transcription and summarisation
 	transcriber *stt.TranscriberPool
+	streamer    stt.StreamingTranscriber // Live per-speaker transcription, nil when chunking
+	streamWG    sync.WaitGroup           // Per-speaker stream result forwarders
 	summariser  *gemini.GeminiSummariser
+	turnOptions assembler.Options // How utterances merge into speaker turns; zero for the defaults
 
 	// Per-speaker audio processing
-	speakerChunkers map[uint32]audio.Chunker // SSRC -> individual chunker
-	speakerMap      map[uint32]string        // SSRC -> UserID mapping
-	speakerMux      sync.RWMutex             // Protects speakerChunkers and speakerMap
-
-	// Chunker template for creating new per-speaker chunkers
-	chunkerTemplate audio.Chunker
-
-	// Discord
+	speakerPipelines map[uint32]*speakerPipeline // SSRC -> decoder, VAD and chunker
+	speakerMap       map[uint32]string           // SSRC -> UserID mapping
+	speakerMux       sync.RWMutex                // Protects speaker pipelines, mappings and closed-pipeline records
+	closedStats      []SpeakerStats              // Packet statistics of torn down pipelines
+	archiveTracks    []store.ArchiveTrack        // Audio archives of torn down pipelines
+	lostChunks       []audio.LostChunk           // Chunks given up on before reaching the transcriber
+	closedStreamed   time.Duration               // Speech streamed by torn down pipelines
+	lostMux          sync.Mutex                  // Protects lostChunks
+
+	// Create new chunkers, processing chains and VADs for every speaker
+	chunkerFactory   audio.ChunkerFactory
+	processorFactory audio.ProcessorFactory
+	vadFactory       audio.VADFactory
+
+	// Discord; session is nil when replaying a capture offline
 	session   *discordgo.Session
 	voiceConn *discordgo.VoiceConnection
 
+	// Raw voice capture, nil when disabled
+	captureEnabled bool
+	capture        *capture.Writer
+
+	// Offline capture replay
+	replaying      bool
+	replayMembers  map[string]bool   // Voice channel members from captured voice states
+	replayNames    map[string]string // User ID -> display name given for a replayed track
+	membersMux     sync.Mutex
+	chunkWG        sync.WaitGroup // Per-speaker chunk forwarders
+	utterancesDone chan struct{}  // Closed when processUtterances returns
+
 	// Storage
-	store      *store.FileStore
-	utterances []audio.Utterance
+	store         *store.FileStore
+	utterances    []audio.Utterance
+	utterancesMux sync.RWMutex // Protects utterances; never held across calls out
+	archiveFormat string       // "none", "wav" or "ogg"
+	mixFormat     string       // "none", "wav" or "ogg"
+	mixer         *audio.Mixer // Mixdown of all speakers, nil when disabled
+
+	startedAt time.Time
+	endedAt   time.Time
 
 	// Control
 	ctx     context.Context
@@ -50,6 +86,9 @@ type VoiceSession struct {
 	stopped bool
 	mutex   sync.RWMutex
 
+	usageRecorded bool // Usage has been added to the ledger
+	usageMux      sync.Mutex
+
 	// STT Worker Pool
 	sttWorkerPool *errgroup.Group
 	sttCtx        context.Context
@@ -59,34 +98,41 @@ type VoiceSession struct {
 func NewVoiceSession(
 	id, guildID, channelID, textChannelID, userID string,
 	session *discordgo.Session,
-	decoder audio.AudioDecoder,
-	vad audio.VAD,
-	chunker audio.Chunker, // This will be used as a template for per-speaker chunkers
+	chunkerFactory audio.ChunkerFactory,
+	processorFactory audio.ProcessorFactory,
+	vadFactory audio.VADFactory,
 	transcriber *stt.TranscriberPool,
 	summariser *gemini.GeminiSummariser,
 	store *store.FileStore,
+	archiveFormat string,
+	mixFormat string,
+	captureEnabled bool,
 ) *VoiceSession {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &VoiceSession{
-		ID:              id,
-		GuildID:         guildID,
-		ChannelID:       channelID,
-		TextChannelID:   textChannelID,
-		UserID:          userID,
-		decoder:         decoder,
-		vad:             vad,
-		chunkerTemplate: chunker,
-		transcriber:     transcriber,
-		summariser:      summariser,
-		session:         session,
-		store:           store,
-		ctx:             ctx,
-		cancel:          cancel,
-		utterances:      make([]audio.Utterance, 0),
-		speakerChunkers: make(map[uint32]audio.Chunker),
-		speakerMap:      make(map[uint32]string),
-		speakerMux:      sync.RWMutex{},
+		ID:               id,
+		GuildID:          guildID,
+		ChannelID:        channelID,
+		TextChannelID:    textChannelID,
+		UserID:           userID,
+		chunkerFactory:   chunkerFactory,
+		processorFactory: processorFactory,
+		vadFactory:       vadFactory,
+		transcriber:      transcriber,
+		summariser:       summariser,
+		session:          session,
+		store:            store,
+		archiveFormat:    archiveFormat,
+		mixFormat:        mixFormat,
+		captureEnabled:   captureEnabled,
+		utterancesDone:   make(chan struct{}),
+		ctx:              ctx,
+		cancel:           cancel,
+		utterances:       make([]audio.Utterance, 0),
+		speakerPipelines: make(map[uint32]*speakerPipeline),
+		speakerMap:       make(map[uint32]string),
+		speakerMux:       sync.RWMutex{},
 	}
 }
 
@@ -98,6 +144,9 @@ func (vs *VoiceSession) Start() error {
 		return fmt.Errorf("session already stopped")
 	}
 
+	vs.startedAt = time.Now()
+	vs.createRecorders()
+
 	// Connect to voice channel
 	// Parameters: guildID, channelID, mute, deaf
 	// mute: false (bot can send audio if needed)
@@ -137,6 +186,16 @@ func (vs *VoiceSession) Start() error {
 		Str("channel_id", vs.ChannelID).
 		Msg("Voice connection established and speaking handler registered")
 
+	// Seed the capture with who is already in the channel
+	if vs.capture != nil {
+		for _, userID := range vs.channelMembers() {
+			vs.captureVoiceState(userID, vs.ChannelID, "")
+		}
+	}
+
+	// Chunks that arrive while the transcriber is behind wait on disk
+	vs.enableSpill()
+
 	// Start transcriber pool
 	if err := vs.transcriber.Start(vs.ctx); err != nil {
 		return fmt.Errorf("failed to start transcriber: %w", err)
@@ -164,7 +223,13 @@ func (vs *VoiceSession) processAudioLoop() {
 				log.Info().Str("session_id", vs.ID).Msg("Voice receive channel closed")
 				return
 			}
-			vs.processAudioPacket(packet)
+			arrival := time.Now()
+			if vs.capture != nil {
+				if err := vs.capture.WritePacket(packet, arrival); err != nil {
+					log.Debug().Str("session_id", vs.ID).Err(err).Msg("Failed to capture packet")
+				}
+			}
+			vs.processAudioPacket(packet, arrival)
 		case <-vs.ctx.Done():
 			log.Info().Str("session_id", vs.ID).Msg("Audio processing context cancelled")
 			return
@@ -172,7 +237,7 @@ func (vs *VoiceSession) processAudioLoop() {
 	}
 }
 
-func (vs *VoiceSession) processAudioPacket(packet *discordgo.Packet) {
+func (vs *VoiceSession) processAudioPacket(packet *discordgo.Packet, arrival time.Time) {
 	if vs.stopped {
 		return
 	}
@@ -183,19 +248,31 @@ func (vs *VoiceSession) processAudioPacket(packet *discordgo.Packet) {
 		Int("opus_size", len(packet.Opus)).
 		Msg("Processing audio packet")
 
-	// Decode Opus to PCM
-	pcm, err := vs.decoder.Decode(packet.Opus)
+	// Get or create the audio pipeline for this SSRC
+	pipeline, err := vs.getOrCreatePipelineForSSRC(packet.SSRC)
 	if err != nil {
 		log.Warn().
 			Str("session_id", vs.ID).
 			Uint32("ssrc", packet.SSRC).
 			Err(err).
-			Msg("Failed to decode opus packet")
+			Msg("Failed to create speaker audio pipeline")
 		return
 	}
 
-	// Apply VAD
-	if !vs.vad.IsSpeech(pcm, 48000) {
+	// Get current speakers for this SSRC
+	speakers := vs.getCurrentSpeakers(packet.SSRC)
+
+	// Reorder, decode, apply VAD and chunk
+	speechFrames, err := pipeline.process(packet, arrival, speakers)
+	if err != nil {
+		log.Warn().
+			Str("session_id", vs.ID).
+			Uint32("ssrc", packet.SSRC).
+			Err(err).
+			Msg("Failed to decode opus packet")
+	}
+
+	if speechFrames == 0 {
 		log.Debug().
 			Str("session_id", vs.ID).
 			Uint32("ssrc", packet.SSRC).
@@ -204,64 +281,59 @@ func (vs *VoiceSession) processAudioPacket(packet *discordgo.Packet) {
 	}
 
 	log.Debug().
-		Str("session_id", vs.ID).
-		Uint32("ssrc", packet.SSRC).
-		Int("pcm_samples", len(pcm)).
-		Msg("VAD detected speech - processing packet")
-
-		// Get current speakers for this SSRC
-	speakers := vs.getCurrentSpeakers(packet.SSRC)
-
-	log.Info().
 		Str("session_id", vs.ID).
 		Uint32("ssrc", packet.SSRC).
 		Strs("speakers", speakers).
-		Msg("SSRC mapped to speakers")
-
-	// Get or create chunker for this SSRC and add samples
-	chunker := vs.getOrCreateChunkerForSSRC(packet.SSRC)
-	timestamp := time.Now()
-	chunker.AddSamples(pcm, timestamp, speakers)
-
+		Int("speech_frames", speechFrames).
+		Msg("VAD detected speech - processed packet")
 }
 
 // processChunks is now handled per-speaker in processChunksForSpeaker
 
 func (vs *VoiceSession) processUtterances() {
+	defer close(vs.utterancesDone)
 	defer log.Debug().Str("session_id", vs.ID).Msg("Utterance processing stopped")
 
-	for {
-		select {
-		case utterances, ok := <-vs.transcriber.GetUtterances():
-			if !ok {
-				return
-			}
+	// Results held back for ordering are flushed when the pool stops, so
+	// read until it closes the channel rather than until the session ends
+	for utterances := range vs.transcriber.GetUtterances() {
+		vs.addUtterances(utterances)
+	}
+}
 
-			vs.mutex.Lock()
-			// Resolve user tags
-			for i := range utterances {
-				if utterances[i].UserID != "" {
-					if user, err := vs.session.User(utterances[i].UserID); err == nil {
-						utterances[i].UserTag = user.Username
-					} else {
-						utterances[i].UserTag = utterances[i].UserID
-					}
-				}
+// addUtterances resolves user tags and adds final utterances to the
+// session transcript, keeping it in start time order.
+func (vs *VoiceSession) addUtterances(utterances []audio.Utterance) {
+	// Resolve user tags before locking; lookups can go to Discord
+	for i := range utterances {
+		if utterances[i].UserID != "" && vs.session != nil {
+			if user, err := vs.session.User(utterances[i].UserID); err == nil {
+				utterances[i].UserTag = user.Username
+			} else {
+				utterances[i].UserTag = utterances[i].UserID
 			}
-
-			vs.utterances = append(vs.utterances, utterances...)
-			vs.mutex.Unlock()
-
-			log.Debug().
-				Int("new_utterances", len(utterances)).
-				Int("total_utterances", len(vs.utterances)).
-				Str("session_id", vs.ID).
-				Msg("Added utterances")
-
-		case <-vs.ctx.Done():
-			return
 		}
 	}
+
+	vs.utterancesMux.Lock()
+	defer vs.utterancesMux.Unlock()
+
+	for _, utt := range utterances {
+		// Usually the newest; late results and streams of other speakers
+		// slot in further back
+		i := sort.Search(len(vs.utterances), func(i int) bool {
+			return vs.utterances[i].TSStart.After(utt.TSStart)
+		})
+		vs.utterances = append(vs.utterances, audio.Utterance{})
+		copy(vs.utterances[i+1:], vs.utterances[i:])
+		vs.utterances[i] = utt
+	}
+
+	log.Debug().
+		Int("new_utterances", len(utterances)).
+		Int("total_utterances", len(vs.utterances)).
+		Str("session_id", vs.ID).
+		Msg("Added utterances")
 }
 
 func (vs *VoiceSession) handleSpeakingUpdate(vc *discordgo.VoiceConnection, vs2 *discordgo.VoiceSpeakingUpdate) {
@@ -269,6 +341,12 @@ func (vs *VoiceSession) handleSpeakingUpdate(vc *discordgo.VoiceConnection, vs2
 		return
 	}
 
+	if vs.capture != nil {
+		if err := vs.capture.WriteSpeaking(vs2, time.Now()); err != nil {
+			log.Debug().Str("session_id", vs.ID).Err(err).Msg("Failed to capture speaking update")
+		}
+	}
+
 	vs.speakerMux.Lock()
 	defer vs.speakerMux.Unlock()
 
@@ -327,27 +405,50 @@ func (vs *VoiceSession) getCurrentSpeakers(ssrc uint32) []string {
 	}
 
 	// Fallback: return first available user in voice channel
-	if vs.voiceConn == nil {
+	if vs.voiceConn == nil && !vs.replaying {
 		return []string{}
 	}
 
+	for _, userID := range vs.channelMembers() {
+		log.Warn().
+			Str("session_id", vs.ID).
+			Uint32("ssrc", ssrc).
+			Str("fallback_user_id", userID).
+			Msg("Using fallback speaker detection")
+		return []string{userID}
+	}
+
+	return []string{}
+}
+
+// channelMembers returns the users in the session's voice channel. When
+// replaying a capture, membership comes from the captured voice states.
+func (vs *VoiceSession) channelMembers() []string {
+	if vs.replaying {
+		vs.membersMux.Lock()
+		defer vs.membersMux.Unlock()
+
+		members := make([]string, 0, len(vs.replayMembers))
+		for userID := range vs.replayMembers {
+			members = append(members, userID)
+		}
+		// Map iteration order is random; keep replays deterministic
+		sort.Strings(members)
+		return members
+	}
+
 	guild, err := vs.session.State.Guild(vs.GuildID)
 	if err != nil {
-		return []string{}
+		return nil
 	}
 
+	var members []string
 	for _, voiceState := range guild.VoiceStates {
 		if voiceState.ChannelID == vs.ChannelID {
-			log.Warn().
-				Str("session_id", vs.ID).
-				Uint32("ssrc", ssrc).
-				Str("fallback_user_id", voiceState.UserID).
-				Msg("Using fallback speaker detection")
-			return []string{voiceState.UserID}
+			members = append(members, voiceState.UserID)
 		}
 	}
-
-	return []string{}
+	return members
 }
 
 func (vs *VoiceSession) autoMapSSRCToUser(ssrc uint32) string {
@@ -356,21 +457,14 @@ func (vs *VoiceSession) autoMapSSRCToUser(ssrc uint32) string {
 		Uint32("ssrc", ssrc).
 		Msg("Attempting auto-mapping based on speaking order")
 
-	vs.speakerMux.Lock()
-	defer vs.speakerMux.Unlock()
-
 	// Get all users in the voice channel
-	guild, err := vs.session.State.Guild(vs.GuildID)
-	if err != nil {
+	usersInChannel := vs.channelMembers()
+	if len(usersInChannel) == 0 {
 		return ""
 	}
 
-	var usersInChannel []string
-	for _, voiceState := range guild.VoiceStates {
-		if voiceState.ChannelID == vs.ChannelID {
-			usersInChannel = append(usersInChannel, voiceState.UserID)
-		}
-	}
+	vs.speakerMux.Lock()
+	defer vs.speakerMux.Unlock()
 
 	// Find users that aren't mapped to any SSRC yet
 	mappedUsers := make(map[string]bool)
@@ -411,188 +505,750 @@ func (vs *VoiceSession) autoMapSSRCToUser(ssrc uint32) string {
 
 func (vs *VoiceSession) Stop() error {
 	vs.mutex.Lock()
-	defer vs.mutex.Unlock()
-
 	if vs.stopped {
+		vs.mutex.Unlock()
 		return nil
 	}
 
 	vs.stopped = true
+	if vs.endedAt.IsZero() {
+		vs.endedAt = time.Now()
+	}
 	vs.cancel()
 
-	// Stop all speaker chunkers
-	vs.speakerMux.RLock()
-	for ssrc, chunker := range vs.speakerChunkers {
-		if chunker != nil {
-			chunker.Stop()
-			log.Debug().
-				Str("session_id", vs.ID).
-				Uint32("ssrc", ssrc).
-				Msg("Stopped speaker chunker")
-		}
+	// The rest runs unlocked: stopping the transcriber waits for its last
+	// results to be added to the session
+	vs.mutex.Unlock()
+
+	// Tear down all speaker pipelines
+	vs.speakerMux.Lock()
+	for ssrc := range vs.speakerPipelines {
+		vs.closePipelineLocked(ssrc)
+		log.Debug().
+			Str("session_id", vs.ID).
+			Uint32("ssrc", ssrc).
+			Msg("Stopped speaker pipeline")
+	}
+	vs.speakerMux.Unlock()
+
+	// Chunkers flush their buffered audio when stopped; hand it all to the
+	// transcriber before it stops, so it is kept for a retry
+	vs.chunkWG.Wait()
+
+	for _, stats := range vs.SpeakerStats() {
+		log.Info().
+			Str("session_id", vs.ID).
+			Uint32("ssrc", stats.SSRC).
+			Str("user_id", stats.UserID).
+			Int("received", stats.Received).
+			Int("lost", stats.Lost).
+			Int("recovered", stats.Recovered).
+			Int("concealed", stats.Concealed).
+			Int("late", stats.Late).
+			Float64("loss_rate", stats.LossRate()).
+			Msg("Speaker packet statistics")
 	}
-	vs.speakerMux.RUnlock()
 
 	if vs.transcriber != nil {
 		vs.transcriber.Stop()
 	}
 
+	if vs.capture != nil {
+		if err := vs.capture.Close(); err != nil {
+			log.Warn().Str("session_id", vs.ID).Err(err).Msg("Failed to close voice capture")
+		} else {
+			log.Info().
+				Str("session_id", vs.ID).
+				Str("file", vs.capture.Path()).
+				Int("events", vs.capture.Events()).
+				Msg("Saved voice capture")
+		}
+	}
+
 	// Disconnect from voice
 	if vs.voiceConn != nil {
 		vs.voiceConn.Disconnect()
 	}
 
+	vs.utterancesMux.RLock()
+	total := len(vs.utterances)
+	vs.utterancesMux.RUnlock()
+
 	log.Info().
 		Str("session_id", vs.ID).
-		Int("total_utterances", len(vs.utterances)).
+		Int("total_utterances", total).
 		Msg("Voice session stopped")
 
 	return nil
 }
 
-func (vs *VoiceSession) Finalize() (string, string, error) {
-	vs.mutex.RLock()
+func (vs *VoiceSession) Finalize() (*store.SessionMetadata, error) {
+	if vs.mixer != nil {
+		defer vs.mixer.Close()
+	}
+
+	// Streams deliver their final results after the pipelines are closed,
+	// and the transcriber its held back results once stopped
+	vs.streamWG.Wait()
+	<-vs.utterancesDone
+
+	// Chunks that failed during the meeting get a last attempt; recovered
+	// speech slots in earlier in the transcript
+	if vs.transcriber != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+		recovered := vs.transcriber.RetryDeadLetters(ctx)
+		cancel()
+
+		if len(recovered) > 0 {
+			vs.addUtterances(recovered)
+		}
+	}
+
+	// Transcription is paid for however the rest goes, so usage is
+	// recorded on every way out
+	usage := store.Usage{STTSeconds: vs.transcribedAudio().Seconds(), Sessions: 1}
+	defer func() { vs.recordUsage(usage) }()
+
+	lost := vs.lostAudio()
+	if len(lost) > 0 {
+		log.Warn().
+			Str("session_id", vs.ID).
+			Int("chunks", len(lost)).
+			Msg("Some audio could not be transcribed")
+	}
+
+	vs.utterancesMux.RLock()
 	utterances := make([]audio.Utterance, len(vs.utterances))
 	copy(utterances, vs.utterances)
-	vs.mutex.RUnlock()
+	vs.utterancesMux.RUnlock()
+
+	// Overlapping chunks transcribe the words at their boundary twice
+	utterances = assembler.DedupeOverlaps(utterances)
 
 	// Map User IDs to usernames using Discord Guild Members API
 	log.Info().
 		Str("session_id", vs.ID).
 		Msg("Resolving User IDs to usernames using Guild Members API")
 
+	names := make(map[string]string)
+	displayName := func(userID string) string {
+		name, ok := names[userID]
+		if !ok {
+			name = vs.resolveDisplayName(userID)
+			names[userID] = name
+		}
+		return name
+	}
+
 	for i := range utterances {
 		if utterances[i].UserID != "" {
-			// Get user info from Discord API
-			member, err := vs.session.GuildMember(vs.GuildID, utterances[i].UserID)
-			if err != nil {
-				// Fallback to User API if Guild Member fails
-				user, userErr := vs.session.User(utterances[i].UserID)
-				if userErr == nil {
-					utterances[i].UserTag = user.Username
-					log.Debug().
-						Str("session_id", vs.ID).
-						Str("user_id", utterances[i].UserID).
-						Str("username", user.Username).
-						Msg("Resolved User ID to username via User API")
-				} else {
-					log.Warn().
-						Str("session_id", vs.ID).
-						Str("user_id", utterances[i].UserID).
-						Err(err).
-						Err(userErr).
-						Msg("Failed to resolve User ID to username")
-					utterances[i].UserTag = "Unknown User"
-				}
-			} else {
-				// Use nickname if available, otherwise username
-				displayName := member.User.Username
-				if member.Nick != "" {
-					displayName = member.Nick
-				}
-				utterances[i].UserTag = displayName
-				log.Debug().
-					Str("session_id", vs.ID).
-					Str("user_id", utterances[i].UserID).
-					Str("username", member.User.Username).
-					Str("nickname", member.Nick).
-					Str("display_name", displayName).
-					Msg("Resolved User ID to username via Guild Member API")
-			}
+			utterances[i].UserTag = displayName(utterances[i].UserID)
 		}
 	}
 
-	// Save transcript
+	// Save transcript. It keeps the utterances as transcribed, with their
+	// own timings and confidence, for anything that processes it later
 	transcriptPath, err := vs.store.SaveTranscript(vs.ID, utterances)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to save transcript: %w", err)
+		return nil, fmt.Errorf("failed to save transcript: %w", err)
 	}
 
+	// The notes read by speaker turn, not by chunk
+	turns := assembler.Assemble(utterances, vs.turnOptions)
+
 	// Generate and save notes
 	// Use a fresh context for summarization since the session context may be cancelled
 	summaryCtx := context.Background()
-	notes, err := vs.summariser.Summarise(summaryCtx, utterances)
+	notes, tokens, err := vs.summariser.Summarise(summaryCtx, turns)
+	usage.InputTokens = tokens.InputTokens
+	usage.OutputTokens = tokens.OutputTokens
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate notes: %w", err)
+		return nil, fmt.Errorf("failed to generate notes: %w", err)
 	}
 
+	// Say where the notes have holes rather than leave readers guessing
+	notes += formatGaps(lost, displayName)
+
 	notesPath, err := vs.store.SaveNotes(vs.ID, notes)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to save notes: %w", err)
+		return nil, fmt.Errorf("failed to save notes: %w", err)
+	}
+
+	vs.speakerMux.RLock()
+	archives := make([]store.ArchiveTrack, len(vs.archiveTracks))
+	copy(archives, vs.archiveTracks)
+	vs.speakerMux.RUnlock()
+
+	recordingPath := vs.renderRecording()
+
+	var capturePath string
+	if vs.capture != nil {
+		capturePath = vs.capture.Path()
+	}
+
+	// Record the artifacts of this session
+	metadata := &store.SessionMetadata{
+		SessionID:      vs.ID,
+		GuildID:        vs.GuildID,
+		ChannelID:      vs.ChannelID,
+		TextChannelID:  vs.TextChannelID,
+		StartedAt:      vs.startedAt,
+		EndedAt:        vs.endedAt,
+		TranscriptPath: transcriptPath,
+		NotesPath:      notesPath,
+		RecordingPath:  recordingPath,
+		CapturePath:    capturePath,
+		Archives:       archives,
+		Usage:          &usage,
+	}
+	if _, err := vs.store.SaveMetadata(metadata); err != nil {
+		return nil, fmt.Errorf("failed to save session metadata: %w", err)
+	}
+
+	return metadata, nil
+}
+
+// recordTranscriptionUsage adds the audio a stopped session transcribed to
+// the usage ledger, for sessions that end without Finalize, such as at
+// shutdown.
+func (vs *VoiceSession) recordTranscriptionUsage() {
+	vs.recordUsage(store.Usage{STTSeconds: vs.transcribedAudio().Seconds(), Sessions: 1})
+}
+
+// recordUsage adds the session's usage to the ledger, once. Replays are
+// billed like any session but kept under their own key, so they don't use
+// up the guild's budget for meetings.
+func (vs *VoiceSession) recordUsage(usage store.Usage) {
+	vs.usageMux.Lock()
+	defer vs.usageMux.Unlock()
+
+	if vs.usageRecorded {
+		return
+	}
+	vs.usageRecorded = true
+
+	key := vs.GuildID
+	if vs.replaying {
+		key = store.ReplayUsageKey(vs.GuildID)
+	}
+
+	endedAt := vs.endedAt
+	if endedAt.IsZero() {
+		endedAt = time.Now()
+	}
+
+	if _, err := vs.store.AddUsage(key, endedAt, usage); err != nil {
+		log.Error().Err(err).Str("session_id", vs.ID).Msg("Failed to record session usage")
+	}
+}
+
+// resolveDisplayName returns a user's nickname in the guild, or their
+// username. Without a Discord connection it is the name given for a
+// replayed track, or else the ID as is.
+func (vs *VoiceSession) resolveDisplayName(userID string) string {
+	if vs.session == nil {
+		if name, ok := vs.replayNames[userID]; ok {
+			return name
+		}
+		return userID
+	}
+
+	// Get user info from Discord API
+	member, err := vs.session.GuildMember(vs.GuildID, userID)
+	if err != nil {
+		// Fallback to User API if Guild Member fails
+		user, userErr := vs.session.User(userID)
+		if userErr != nil {
+			log.Warn().
+				Str("session_id", vs.ID).
+				Str("user_id", userID).
+				Err(err).
+				Err(userErr).
+				Msg("Failed to resolve User ID to username")
+			return "Unknown User"
+		}
+
+		log.Debug().
+			Str("session_id", vs.ID).
+			Str("user_id", userID).
+			Str("username", user.Username).
+			Msg("Resolved User ID to username via User API")
+		return user.Username
+	}
+
+	// Use nickname if available, otherwise username
+	displayName := member.User.Username
+	if member.Nick != "" {
+		displayName = member.Nick
+	}
+	log.Debug().
+		Str("session_id", vs.ID).
+		Str("user_id", userID).
+		Str("username", member.User.Username).
+		Str("nickname", member.Nick).
+		Str("display_name", displayName).
+		Msg("Resolved User ID to username via Guild Member API")
+	return displayName
+}
+
+// lostAudio returns every chunk that was never transcribed: those chunkers
+// gave up on and those the transcriber couldn't recover.
+func (vs *VoiceSession) lostAudio() []audio.LostChunk {
+	vs.lostMux.Lock()
+	lost := make([]audio.LostChunk, len(vs.lostChunks))
+	copy(lost, vs.lostChunks)
+	vs.lostMux.Unlock()
+
+	if vs.transcriber != nil {
+		lost = append(lost, vs.transcriber.Lost()...)
+	}
+	return lost
+}
+
+// enableSpill gives the transcriber a spill directory in the session
+// directory. Without one, chunks wait briefly for room in the queue and are
+// kept for a retry at the end if none frees up.
+func (vs *VoiceSession) enableSpill() {
+	dir, err := vs.store.SessionDir(vs.ID)
+	if err == nil {
+		err = vs.transcriber.EnableSpill(filepath.Join(dir, "spill"))
+	}
+	if err != nil {
+		log.Warn().
+			Str("session_id", vs.ID).
+			Err(err).
+			Msg("Failed to create chunk spill directory")
 	}
+}
 
-	return transcriptPath, notesPath, nil
+// createRecorders sets up the optional mixdown and voice capture. Both are
+// best effort; transcription works without them.
+func (vs *VoiceSession) createRecorders() {
+	if vs.mixFormat != "" && vs.mixFormat != audio.ArchiveNone {
+		mixer, err := vs.createMixer()
+		if err != nil {
+			log.Warn().
+				Str("session_id", vs.ID).
+				Err(err).
+				Msg("Failed to create meeting recording mixer")
+		}
+		vs.mixer = mixer
+	}
+
+	if vs.captureEnabled {
+		writer, err := vs.createCapture()
+		if err != nil {
+			log.Warn().
+				Str("session_id", vs.ID).
+				Err(err).
+				Msg("Failed to create voice capture")
+		}
+		vs.capture = writer
+	}
+}
+
+// createCapture opens the raw voice capture in the session directory.
+func (vs *VoiceSession) createCapture() (*capture.Writer, error) {
+	dir, err := vs.store.SessionDir(vs.ID)
+	if err != nil {
+		return nil, err
+	}
+	return capture.NewWriter(filepath.Join(dir, "capture.jsonl.gz"), capture.Header{
+		SessionID: vs.ID,
+		GuildID:   vs.GuildID,
+		ChannelID: vs.ChannelID,
+		StartedAt: vs.startedAt,
+	})
+}
+
+// captureVoiceState records a voice channel membership change.
+func (vs *VoiceSession) captureVoiceState(userID, channelID, beforeChannelID string) {
+	if err := vs.capture.WriteVoiceState(userID, channelID, beforeChannelID, time.Now()); err != nil {
+		log.Debug().Str("session_id", vs.ID).Err(err).Msg("Failed to capture voice state")
+	}
+}
+
+// createMixer starts a mixdown whose scratch data lives in the session
+// directory until the recording is rendered.
+func (vs *VoiceSession) createMixer() (*audio.Mixer, error) {
+	dir, err := vs.store.SessionDir(vs.ID)
+	if err != nil {
+		return nil, err
+	}
+	return audio.NewMixer(filepath.Join(dir, "mix.pcm"), vs.startedAt)
 }
 
-func (vs *VoiceSession) getOrCreateChunkerForSSRC(ssrc uint32) audio.Chunker {
+// renderRecording writes the mixed meeting recording and returns its path,
+// or "" if recording is disabled or rendering failed.
+func (vs *VoiceSession) renderRecording() string {
+	if vs.mixer == nil {
+		return ""
+	}
+
+	dir, err := vs.store.SessionDir(vs.ID)
+	if err != nil {
+		log.Warn().Str("session_id", vs.ID).Err(err).Msg("Failed to render meeting recording")
+		return ""
+	}
+
+	path := filepath.Join(dir, "recording."+vs.mixFormat)
+	if err := vs.mixer.Render(path, vs.mixFormat); err != nil {
+		log.Warn().Str("session_id", vs.ID).Err(err).Msg("Failed to render meeting recording")
+		return ""
+	}
+
+	log.Info().
+		Str("session_id", vs.ID).
+		Str("file", path).
+		Dur("duration", vs.mixer.Duration()).
+		Msg("Saved meeting recording")
+
+	return path
+}
+
+func (vs *VoiceSession) getOrCreatePipelineForSSRC(ssrc uint32) (*speakerPipeline, error) {
 	vs.speakerMux.Lock()
 	defer vs.speakerMux.Unlock()
 
-	// Check if we already have a chunker for this speaker
-	if chunker, exists := vs.speakerChunkers[ssrc]; exists {
-		return chunker
+	// Check if we already have a pipeline for this speaker
+	if pipeline, exists := vs.speakerPipelines[ssrc]; exists {
+		return pipeline, nil
+	}
+
+	// Streaming sessions send speech straight to the backend; otherwise
+	// create a new chunker from the session's configuration
+	var chunker audio.Chunker
+	var stream stt.Stream
+	if vs.streamer != nil {
+		var err error
+		stream, err = vs.streamer.NewStream()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open transcription stream: %w", err)
+		}
+	} else {
+		chunker = vs.chunkerFactory.NewChunker()
+	}
+
+	pipeline, err := newSpeakerPipeline(chunker, vs.processorFactory, vs.vadFactory)
+	if err != nil {
+		if stream != nil {
+			stream.Close()
+		}
+		return nil, err
+	}
+	pipeline.stream = stream
+
+	// Archiving is best effort; a failure shouldn't stop transcription
+	archive, err := vs.createArchive(ssrc)
+	if err != nil {
+		log.Warn().
+			Str("session_id", vs.ID).
+			Uint32("ssrc", ssrc).
+			Err(err).
+			Msg("Failed to create speaker audio archive")
 	}
 
-	// Create a new chunker for this speaker based on the template
-	// We need to create a new instance, not reuse the template
-	newChunker := audio.NewRingChunker(10, 500, 48000) // 10s chunks, 500ms overlap, 48kHz
-	vs.speakerChunkers[ssrc] = newChunker
+	pipeline.archive = archive
+	pipeline.mixer = vs.mixer
+	vs.speakerPipelines[ssrc] = pipeline
 
-	// Start processing chunks from this speaker
-	go vs.processChunksForSpeaker(ssrc, newChunker)
+	// Start processing chunks or stream results from this speaker
+	if stream != nil {
+		vs.streamWG.Add(1)
+		go vs.processStreamForSpeaker(ssrc, stream)
+	} else {
+		pipeline.handoff = newChunkHandoff(maxHandoffChunks, vs.overflowChunk)
+		vs.chunkWG.Add(1)
+		go vs.processChunksForSpeaker(ssrc, chunker, pipeline.handoff)
+	}
 
 	log.Debug().
 		Str("session_id", vs.ID).
 		Uint32("ssrc", ssrc).
-		Msg("Created new chunker for speaker")
+		Msg("Created new audio pipeline for speaker")
+
+	return pipeline, nil
+}
+
+// createArchive opens an audio archive for an SSRC in the session directory,
+// or returns nil if archiving is disabled. A speaker who rejoins with the
+// same SSRC gets a new numbered file.
+func (vs *VoiceSession) createArchive(ssrc uint32) (*audio.SpeakerArchive, error) {
+	if vs.archiveFormat == "" || vs.archiveFormat == audio.ArchiveNone {
+		return nil, nil
+	}
+
+	dir, err := vs.store.SessionDir(vs.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("speaker_%d.%s", ssrc, vs.archiveFormat))
+	for n := 2; ; n++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("speaker_%d_%d.%s", ssrc, n, vs.archiveFormat))
+	}
 
-	return newChunker
+	return audio.NewSpeakerArchive(path, vs.archiveFormat, vs.startedAt)
 }
 
-func (vs *VoiceSession) processChunksForSpeaker(ssrc uint32, chunker audio.Chunker) {
+// removeSpeaker tears down the audio pipelines and SSRC mappings of a user
+// who left the voice channel. Any buffered audio is flushed as a final chunk.
+func (vs *VoiceSession) removeSpeaker(userID string) {
+	vs.speakerMux.Lock()
+	defer vs.speakerMux.Unlock()
+
+	for ssrc, mappedUser := range vs.speakerMap {
+		if mappedUser != userID {
+			continue
+		}
+
+		vs.closePipelineLocked(ssrc)
+		delete(vs.speakerMap, ssrc)
+
+		log.Info().
+			Str("session_id", vs.ID).
+			Uint32("ssrc", ssrc).
+			Str("user_id", userID).
+			Msg("Speaker left - removed audio pipeline")
+	}
+}
+
+// closePipelineLocked tears down the pipeline of an SSRC and keeps its
+// packet statistics and archive. The caller must hold speakerMux.
+func (vs *VoiceSession) closePipelineLocked(ssrc uint32) {
+	pipeline, exists := vs.speakerPipelines[ssrc]
+	if !exists {
+		return
+	}
+
+	pipeline.close()
+	delete(vs.speakerPipelines, ssrc)
+
+	vs.lostMux.Lock()
+	if pipeline.chunker != nil {
+		vs.lostChunks = append(vs.lostChunks, pipeline.chunker.Lost()...)
+	}
+	vs.lostChunks = append(vs.lostChunks, pipeline.lostAudio()...)
+	vs.lostMux.Unlock()
+
+	vs.closedStats = append(vs.closedStats, SpeakerStats{
+		SSRC:        ssrc,
+		UserID:      vs.speakerMap[ssrc],
+		JitterStats: pipeline.stats(),
+	})
+	vs.closedStreamed += pipeline.streamedAudio()
+
+	if pipeline.archive != nil {
+		vs.archiveTracks = append(vs.archiveTracks, store.ArchiveTrack{
+			SSRC:   ssrc,
+			UserID: vs.speakerMap[ssrc],
+			Format: pipeline.archive.Format(),
+			Path:   pipeline.archive.Path(),
+		})
+	}
+}
+
+// transcribedAudio returns how much audio the session has sent for
+// transcription so far, chunked or streamed.
+func (vs *VoiceSession) transcribedAudio() time.Duration {
+	var total time.Duration
+	if vs.transcriber != nil {
+		total = vs.transcriber.Transcribed()
+	}
+
+	vs.speakerMux.RLock()
+	defer vs.speakerMux.RUnlock()
+
+	total += vs.closedStreamed
+	for _, pipeline := range vs.speakerPipelines {
+		total += pipeline.streamedAudio()
+	}
+	return total
+}
+
+// SpeakerStats returns packet loss statistics for every speaker seen in the
+// session, including those who already left.
+func (vs *VoiceSession) SpeakerStats() []SpeakerStats {
+	vs.speakerMux.RLock()
+	defer vs.speakerMux.RUnlock()
+
+	stats := make([]SpeakerStats, 0, len(vs.closedStats)+len(vs.speakerPipelines))
+	stats = append(stats, vs.closedStats...)
+	for ssrc, pipeline := range vs.speakerPipelines {
+		stats = append(stats, SpeakerStats{
+			SSRC:        ssrc,
+			UserID:      vs.speakerMap[ssrc],
+			JitterStats: pipeline.stats(),
+		})
+	}
+
+	return stats
+}
+
+// HandleVoiceStateUpdate reacts to users leaving the session's voice channel.
+func (vs *VoiceSession) HandleVoiceStateUpdate(update *discordgo.VoiceStateUpdate) {
+	if update == nil || update.VoiceState == nil || update.GuildID != vs.GuildID {
+		return
+	}
+
+	var beforeChannelID string
+	if update.BeforeUpdate != nil {
+		beforeChannelID = update.BeforeUpdate.ChannelID
+	}
+
+	if vs.capture != nil && (update.ChannelID == vs.ChannelID || beforeChannelID == vs.ChannelID) && update.ChannelID != beforeChannelID {
+		vs.captureVoiceState(update.UserID, update.ChannelID, beforeChannelID)
+	}
+
+	if update.BeforeUpdate != nil && update.BeforeUpdate.ChannelID != vs.ChannelID {
+		return
+	}
+
+	if update.ChannelID == vs.ChannelID {
+		return
+	}
+
+	vs.removeSpeaker(update.UserID)
+}
+
+// overflowChunk spills a chunk from a speaker's handoff that filled up
+// while the transcriber was behind.
+func (vs *VoiceSession) overflowChunk(chunk *audio.Chunk) {
+	if err := vs.transcriber.SpillChunk(chunk); err != nil {
+		log.Warn().
+			Err(err).
+			Str("session_id", vs.ID).
+			Str("chunk_id", chunk.ID.String()).
+			Msg("Failed to spill chunk from a full handoff, keeping it for a retry")
+	}
+}
+
+func (vs *VoiceSession) processChunksForSpeaker(ssrc uint32, chunker audio.Chunker, handoff *chunkHandoff) {
+	defer vs.chunkWG.Done()
 	defer log.Debug().
 		Str("session_id", vs.ID).
 		Uint32("ssrc", ssrc).
 		Msg("Speaker chunk processing stopped")
 
+	// Take chunks off the chunker's channel as soon as they arrive, so the
+	// chunker never finds it full while the pool is busy. Runs until the
+	// chunker is stopped and closes its channel, so the final chunk flushed
+	// on the way out isn't left behind.
+	go func() {
+		for chunk := range chunker.GetChunk() {
+			handoff.push(chunk)
+		}
+		handoff.close()
+	}()
+
 	chunkCount := 0
 	for {
-		select {
-		case chunk, ok := <-chunker.GetChunk():
-			if !ok {
-				return
+		chunk, ok := handoff.pop()
+		if !ok {
+			break
+		}
+		chunkCount++
+		log.Debug().
+			Str("session_id", vs.ID).
+			Uint32("ssrc", ssrc).
+			Str("chunk_id", chunk.ID.String()).
+			Int("chunk_count", chunkCount).
+			Int("pcm_samples", len(chunk.PCM)).
+			Strs("speakers", chunk.Speakers).
+			Msg("Received audio chunk from speaker")
+
+		// Send chunk to transcriber pool. Replays run faster than real
+		// time, so they wait for room; live chunks that can't be queued
+		// are kept by the pool for a retry.
+		var err error
+		if vs.replaying {
+			err = vs.transcriber.SubmitChunk(vs.ctx, chunk)
+			if err != nil {
+				vs.lostMux.Lock()
+				vs.lostChunks = append(vs.lostChunks, audio.LostChunk{
+					Start:    chunk.Start,
+					End:      chunk.End,
+					Speakers: chunk.Speakers,
+					Reason:   "not transcribed before the session ended",
+				})
+				vs.lostMux.Unlock()
 			}
-
-			chunkCount++
+		} else {
+			err = vs.transcriber.ProcessChunk(chunk)
+		}
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("chunk_id", chunk.ID.String()).
+				Str("session_id", vs.ID).
+				Uint32("ssrc", ssrc).
+				Msg("Failed to process speaker chunk")
+		} else {
 			log.Debug().
 				Str("session_id", vs.ID).
 				Uint32("ssrc", ssrc).
 				Str("chunk_id", chunk.ID.String()).
-				Int("chunk_count", chunkCount).
-				Int("pcm_samples", len(chunk.PCM)).
-				Strs("speakers", chunk.Speakers).
-				Msg("Received audio chunk from speaker")
-
-			// Send chunk to transcriber pool
-			if err := vs.transcriber.ProcessChunk(chunk); err != nil {
-				log.Warn().
-					Err(err).
-					Str("chunk_id", chunk.ID.String()).
-					Str("session_id", vs.ID).
-					Uint32("ssrc", ssrc).
-					Msg("Failed to process speaker chunk")
-			} else {
-				log.Debug().
-					Str("session_id", vs.ID).
-					Uint32("ssrc", ssrc).
-					Str("chunk_id", chunk.ID.String()).
-					Msg("Sent speaker chunk to transcriber pool")
-			}
+				Msg("Sent speaker chunk to transcriber pool")
+		}
+	}
+}
 
-		case <-vs.ctx.Done():
-			return
+// processStreamForSpeaker adds the final results of a speaker's stream to
+// the transcript until the stream closes. Interim results are only logged.
+func (vs *VoiceSession) processStreamForSpeaker(ssrc uint32, stream stt.Stream) {
+	defer vs.streamWG.Done()
+	defer log.Debug().
+		Str("session_id", vs.ID).
+		Uint32("ssrc", ssrc).
+		Msg("Speaker stream processing stopped")
+
+	// Results can arrive after the speaker left and their mapping was
+	// removed, so remember who they were
+	var userID string
+
+	for utterance := range stream.Results() {
+		vs.speakerMux.RLock()
+		if mapped, ok := vs.speakerMap[ssrc]; ok {
+			userID = mapped
+		}
+		vs.speakerMux.RUnlock()
+
+		if utterance.Interim {
+			log.Debug().
+				Str("session_id", vs.ID).
+				Uint32("ssrc", ssrc).
+				Str("user_id", userID).
+				Str("text", utterance.Text).
+				Msg("Interim transcript")
+			continue
+		}
+
+		utterance.UserID = userID
+		utterance.UserTag = userID
+		vs.addUtterances([]audio.Utterance{utterance})
+	}
+
+	// Audio the stream accepted but never transcribed
+	lost := stream.Lost()
+	if len(lost) == 0 {
+		return
+	}
+	if userID == "" {
+		vs.speakerMux.RLock()
+		userID = vs.speakerMap[ssrc]
+		vs.speakerMux.RUnlock()
+	}
+	vs.lostMux.Lock()
+	for _, chunk := range lost {
+		if userID != "" {
+			chunk.Speakers = []string{userID}
 		}
+		vs.lostChunks = append(vs.lostChunks, chunk)
 	}
+	vs.lostMux.Unlock()
 }
 
 func (vs *VoiceSession) refreshSpeakerMappings() {
@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/config"
+	"github.com/user/discord-notetaker/internal/store"
+)
+
+// budgetState is how far a guild is through one of its monthly budgets.
+type budgetState struct {
+	name  string  // What the budget pays for
+	used  float64 // In unit
+	limit float64 // Zero is unlimited
+	unit  string
+}
+
+func guildBudgets(usage store.Usage, budget config.Budget) []budgetState {
+	return []budgetState{
+		{name: "transcription", used: usage.STTMinutes(), limit: float64(budget.STTMinutes), unit: "minutes"},
+		{name: "notes", used: float64(usage.Tokens()), limit: float64(budget.Tokens), unit: "tokens"},
+	}
+}
+
+func (s budgetState) exceeded() bool {
+	return s.limit > 0 && s.used >= s.limit
+}
+
+func (s budgetState) nearing(percent int) bool {
+	return s.limit > 0 && s.used >= s.limit*float64(percent)/100
+}
+
+func (s budgetState) String() string {
+	return fmt.Sprintf("%s budget (%.0f of %.0f %s)", s.name, s.used, s.limit, s.unit)
+}
+
+// budgetsMatching joins the budgets that satisfy match into a phrase such
+// as "transcription budget (610 of 600 minutes)".
+func budgetsMatching(states []budgetState, match func(budgetState) bool) string {
+	var parts []string
+	for _, state := range states {
+		if match(state) {
+			parts = append(parts, state.String())
+		}
+	}
+	return strings.Join(parts, " and ")
+}
+
+// nextBudgetMonth returns when the budgets in force at now reset.
+func nextBudgetMonth(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// guildBudgetStates loads a guild's usage this month against its budgets.
+func (b *Bot) guildBudgetStates(guildID string) ([]budgetState, store.Usage, error) {
+	usage, err := b.store.GuildUsage(guildID, store.UsageMonth(time.Now()))
+	if err != nil {
+		return nil, store.Usage{}, err
+	}
+	return guildBudgets(usage, b.config.BudgetForGuild(guildID)), usage, nil
+}
+
+// checkBudget decides whether a guild may start recording. It returns a
+// reason to refuse once a budget is used up, or a warning to show with the
+// confirmation once one is nearly used up. A ledger that can't be read is
+// logged and doesn't block recording.
+func (b *Bot) checkBudget(guildID string) (refusal, warning string) {
+	states, _, err := b.guildBudgetStates(guildID)
+	if err != nil {
+		log.Error().Err(err).Str("guild_id", guildID).Msg("Failed to load usage")
+		return "", ""
+	}
+
+	if exceeded := budgetsMatching(states, budgetState.exceeded); exceeded != "" {
+		return fmt.Sprintf("This server has used its monthly %s. Recording is available again on %s.",
+			exceeded, nextBudgetMonth(time.Now()).Format("2 January")), ""
+	}
+
+	nearing := budgetsMatching(states, func(s budgetState) bool { return s.nearing(b.config.BudgetWarnPercent) })
+	if nearing != "" {
+		return "", fmt.Sprintf("⚠️ This server has used most of its monthly %s.", nearing)
+	}
+	return "", ""
+}
+
+// warnBudget posts a warning after a session when the guild's usage has
+// reached a budget's warning share, saying whether recording is now refused.
+func (b *Bot) warnBudget(s *discordgo.Session, channelID, guildID string) {
+	refusal, warning := b.checkBudget(guildID)
+	switch {
+	case refusal != "":
+		s.ChannelMessageSend(channelID, "⚠️ "+refusal)
+	case warning != "":
+		s.ChannelMessageSend(channelID, warning+" `!join` is refused once it is used up.")
+	}
+}
+
+func (b *Bot) handleUsage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	states, usage, err := b.guildBudgetStates(m.GuildID)
+	if err != nil {
+		log.Error().Err(err).Str("guild_id", m.GuildID).Msg("Failed to load usage")
+		b.sendError(s, m.ChannelID, "Failed to load usage")
+		return
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "📊 Usage for %s\n", time.Now().UTC().Format("January 2006"))
+	fmt.Fprintf(&msg, "Sessions: %d\n", usage.Sessions)
+	fmt.Fprintf(&msg, "Transcription: %.1f minutes%s\n", usage.STTMinutes(), budgetLimit(states[0]))
+	fmt.Fprintf(&msg, "Notes: %d input + %d output tokens%s\n", usage.InputTokens, usage.OutputTokens, budgetLimit(states[1]))
+
+	// Replays are billed but don't count against the budgets
+	replays, err := b.store.GuildUsage(store.ReplayUsageKey(m.GuildID), store.UsageMonth(time.Now()))
+	if err != nil {
+		log.Error().Err(err).Str("guild_id", m.GuildID).Msg("Failed to load replay usage")
+	} else if replays.Sessions > 0 {
+		fmt.Fprintf(&msg, "Replays: %d, %.1f minutes and %d tokens (not counted against the budgets)\n",
+			replays.Sessions, replays.STTMinutes(), replays.Tokens())
+	}
+
+	// The session in progress is only added to the totals when it ends
+	b.mutex.RLock()
+	for _, session := range b.sessions {
+		if session.GuildID == m.GuildID {
+			fmt.Fprintf(&msg, "Current recording: %.1f minutes transcribed so far\n", session.transcribedAudio().Minutes())
+		}
+	}
+	b.mutex.RUnlock()
+
+	s.ChannelMessageSend(m.ChannelID, msg.String())
+}
+
+// budgetLimit describes a budget's limit for the usage report.
+func budgetLimit(state budgetState) string {
+	if state.limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" of %.0f (%.0f%%)", state.limit, state.used/state.limit*100)
+}
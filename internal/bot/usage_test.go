@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/config"
+	"github.com/user/discord-notetaker/internal/store"
+)
+
+func TestBudgetStates(t *testing.T) {
+	tests := []struct {
+		name         string
+		usage        store.Usage
+		budget       config.Budget
+		wantExceeded string
+		wantNearing  string
+	}{
+		{
+			name:   "unlimited",
+			usage:  store.Usage{STTSeconds: 1e6, InputTokens: 1e9},
+			budget: config.Budget{},
+		},
+		{
+			name:   "well within",
+			usage:  store.Usage{STTSeconds: 60 * 60, InputTokens: 1000},
+			budget: config.Budget{STTMinutes: 600, Tokens: 100000},
+		},
+		{
+			name:        "nearing transcription",
+			usage:       store.Usage{STTSeconds: 500 * 60},
+			budget:      config.Budget{STTMinutes: 600, Tokens: 100000},
+			wantNearing: "transcription budget (500 of 600 minutes)",
+		},
+		{
+			name:         "transcription used up",
+			usage:        store.Usage{STTSeconds: 610 * 60, InputTokens: 85000},
+			budget:       config.Budget{STTMinutes: 600, Tokens: 100000},
+			wantExceeded: "transcription budget (610 of 600 minutes)",
+			wantNearing:  "transcription budget (610 of 600 minutes) and notes budget (85000 of 100000 tokens)",
+		},
+		{
+			name:         "both used up",
+			usage:        store.Usage{STTSeconds: 600 * 60, InputTokens: 90000, OutputTokens: 10000},
+			budget:       config.Budget{STTMinutes: 600, Tokens: 100000},
+			wantExceeded: "transcription budget (600 of 600 minutes) and notes budget (100000 of 100000 tokens)",
+			wantNearing:  "transcription budget (600 of 600 minutes) and notes budget (100000 of 100000 tokens)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			states := guildBudgets(tt.usage, tt.budget)
+
+			if got := budgetsMatching(states, budgetState.exceeded); got != tt.wantExceeded {
+				t.Errorf("exceeded = %q, want %q", got, tt.wantExceeded)
+			}
+			nearing := func(s budgetState) bool { return s.nearing(80) }
+			if got := budgetsMatching(states, nearing); got != tt.wantNearing {
+				t.Errorf("nearing = %q, want %q", got, tt.wantNearing)
+			}
+		})
+	}
+}
+
+func TestNextBudgetMonth(t *testing.T) {
+	east := time.FixedZone("UTC+10", 10*60*60)
+
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Still May in UTC, so the reset is 1 June
+		{time.Date(2024, 6, 1, 8, 0, 0, 0, east), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := nextBudgetMonth(tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextBudgetMonth(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestBudgetLimit(t *testing.T) {
+	if got := budgetLimit(budgetState{used: 30, limit: 0}); got != "" {
+		t.Errorf("unlimited budgetLimit = %q, want none", got)
+	}
+	if got, want := budgetLimit(budgetState{used: 30, limit: 120}), " of 120 (25%)"; got != want {
+		t.Errorf("budgetLimit = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,251 @@
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Version of the capture format
+const Version = 1
+
+// Event kinds
+const (
+	KindPacket     = "packet"
+	KindSpeaking   = "speaking"
+	KindVoiceState = "voice_state"
+)
+
+// Header is the first line of a capture and describes the session.
+type Header struct {
+	Version   int       `json:"version"`
+	SessionID string    `json:"session_id"`
+	GuildID   string    `json:"guild_id"`
+	ChannelID string    `json:"channel_id"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Event is a single captured voice event: a received Opus packet, a
+// speaking update or a change in voice channel membership.
+type Event struct {
+	Kind string    `json:"kind"`
+	At   time.Time `json:"at"` // Arrival time
+
+	// Packets, and the SSRC of speaking updates
+	SSRC      uint32 `json:"ssrc,omitempty"`
+	Sequence  uint16 `json:"seq,omitempty"`
+	Timestamp uint32 `json:"ts,omitempty"`
+	Opus      []byte `json:"opus,omitempty"`
+
+	// Speaking updates and voice states
+	UserID          string `json:"user_id,omitempty"`
+	Speaking        bool   `json:"speaking,omitempty"`
+	ChannelID       string `json:"channel_id,omitempty"`        // Channel after the update, empty when leaving voice
+	BeforeChannelID string `json:"before_channel_id,omitempty"` // Channel before the update, empty when joining voice
+}
+
+// Packet returns a packet event as received from OpusRecv.
+func (e *Event) Packet() *discordgo.Packet {
+	return &discordgo.Packet{
+		SSRC:      e.SSRC,
+		Sequence:  e.Sequence,
+		Timestamp: e.Timestamp,
+		Opus:      e.Opus,
+	}
+}
+
+// SpeakingUpdate returns a speaking event as received from the voice
+// connection.
+func (e *Event) SpeakingUpdate() *discordgo.VoiceSpeakingUpdate {
+	return &discordgo.VoiceSpeakingUpdate{
+		UserID:   e.UserID,
+		SSRC:     int(e.SSRC),
+		Speaking: e.Speaking,
+	}
+}
+
+// Writer records voice events to a gzip-compressed JSON lines file. It is
+// safe for concurrent use.
+type Writer struct {
+	path    string
+	file    *os.File
+	gzip    *gzip.Writer
+	encoder *json.Encoder
+	events  int
+	mutex   sync.Mutex
+}
+
+// NewWriter creates a capture file and writes its header.
+func NewWriter(path string, header Header) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+
+	zw := gzip.NewWriter(file)
+	w := &Writer{
+		path:    path,
+		file:    file,
+		gzip:    zw,
+		encoder: json.NewEncoder(zw),
+	}
+
+	header.Version = Version
+	if err := w.encoder.Encode(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+
+	return w, nil
+}
+
+// Path returns the capture file path.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// WritePacket records a packet received at arrival.
+func (w *Writer) WritePacket(packet *discordgo.Packet, arrival time.Time) error {
+	return w.write(&Event{
+		Kind:      KindPacket,
+		At:        arrival,
+		SSRC:      packet.SSRC,
+		Sequence:  packet.Sequence,
+		Timestamp: packet.Timestamp,
+		Opus:      packet.Opus,
+	})
+}
+
+// WriteSpeaking records a speaking update.
+func (w *Writer) WriteSpeaking(update *discordgo.VoiceSpeakingUpdate, at time.Time) error {
+	return w.write(&Event{
+		Kind:     KindSpeaking,
+		At:       at,
+		SSRC:     uint32(update.SSRC),
+		UserID:   update.UserID,
+		Speaking: update.Speaking,
+	})
+}
+
+// WriteVoiceState records a user moving from beforeChannelID to channelID.
+func (w *Writer) WriteVoiceState(userID, channelID, beforeChannelID string, at time.Time) error {
+	return w.write(&Event{
+		Kind:            KindVoiceState,
+		At:              at,
+		UserID:          userID,
+		ChannelID:       channelID,
+		BeforeChannelID: beforeChannelID,
+	})
+}
+
+func (w *Writer) write(event *Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.encoder == nil {
+		return fmt.Errorf("capture closed")
+	}
+
+	if err := w.encoder.Encode(event); err != nil {
+		return fmt.Errorf("failed to write capture event: %w", err)
+	}
+	w.events++
+	return nil
+}
+
+// Events returns the number of events written.
+func (w *Writer) Events() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.events
+}
+
+// Close flushes and closes the capture file.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.encoder == nil {
+		return nil
+	}
+	w.encoder = nil
+
+	err := w.gzip.Close()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Reader reads events back from a capture file.
+type Reader struct {
+	file    *os.File
+	decoder *json.Decoder
+	header  Header
+}
+
+// Open opens a capture file and reads its header. Both compressed and
+// plain JSON lines captures are accepted, so hand-written fixtures work.
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture file: %w", err)
+	}
+
+	buffered := bufio.NewReader(file)
+	var r io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to decompress capture: %w", err)
+		}
+		r = zr
+	}
+
+	reader := &Reader{
+		file:    file,
+		decoder: json.NewDecoder(r),
+	}
+
+	if err := reader.decoder.Decode(&reader.header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read capture header: %w", err)
+	}
+	if reader.header.Version != Version {
+		file.Close()
+		return nil, fmt.Errorf("unsupported capture version %d", reader.header.Version)
+	}
+
+	return reader, nil
+}
+
+// Header returns the capture's session description.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next returns the next event, or io.EOF at the end of the capture. A
+// capture cut short by a crash ends at the last complete event.
+func (r *Reader) Next() (*Event, error) {
+	var event Event
+	if err := r.decoder.Decode(&event); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read capture event: %w", err)
+	}
+	return &event, nil
+}
+
+// Close closes the capture file.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
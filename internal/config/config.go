@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
@@ -14,33 +16,140 @@ type Config struct {
 	DiscordToken string
 
 	// STT Backend
-	STTBackend string // "vosk" or "deepgram"
+	STTBackend  string   // "vosk", "deepgram" or "whisper"; empty picks the build's default
+	STTFallback []string // Backends tried in order when STTBackend fails
 
 	// Vosk settings
 	VoskModelPath string
 
 	// Deepgram settings
-	DeepgramAPIKey    string
-	DeepgramTier      string
-	DeepgramDiarize   bool
-	DeepgramPunctuate bool
-	DeepgramUtterances bool
+	DeepgramAPIKey          string
+	DeepgramTier            string
+	DeepgramDiarize         bool
+	DeepgramPunctuate       bool
+	DeepgramUtterances      bool
+	DeepgramStreaming       bool // Live WebSocket per speaker instead of chunks
+	DeepgramStreamURL       string
+	DeepgramBaseURL         string
+	DeepgramLanguage        string // BCP-47 tag or "auto"
+	DeepgramSmartFormat     bool
+	DeepgramNumerals        bool
+	DeepgramProfanityFilter bool
+	DeepgramRedact          []string
+	DeepgramKeywords        []string
+	DeepgramKeyterms        []string
+
+	// Whisper server settings
+	WhisperURL      string
+	WhisperAPI      string // "openai" or "whispercpp"
+	WhisperModel    string
+	WhisperLanguage string
+	WhisperPrompt   string
+	WhisperAPIKey   string
 
 	// Gemini settings
-	GenAIAPIKey string
+	GenAIAPIKey  string
 	GenAIBackend string // "gemini" or "vertex"
-	GenAIModel  string
+	GenAIModel   string
 
 	// Chunking settings
-	ChunkSeconds    int
-	ChunkOverlapMS  int
-	MaxParallelSTT  int
+	ChunkStrategy      string // "ring" or "endpoint"
+	ChunkSeconds       int
+	ChunkOverlapMS     int
+	ChunkMinMS         int // endpoint: shorter utterances merge into the next
+	ChunkMaxSeconds    int // endpoint: longer utterances are split
+	ChunkHangoverMS    int // endpoint: pause length that ends an utterance
+	MaxParallelSTT     int // STT requests in flight across all sessions
+	MaxParallelStreams int // Deepgram streams open across all sessions
+	STTReorderMS       int // How long results wait for earlier chunks to finish
+
+	// Transcript assembly into speaker turns
+	TurnMaxGapMS      int // Pause that always ends a turn
+	TurnSentenceGapMS int // Pause that ends a turn after a finished sentence
+	TurnMaxSeconds    int // Longer turns split at the next sentence end
+
+	// STT failure handling
+	STTMaxRetries       int
+	STTRetryBaseMS      int
+	STTRetryMaxMS       int
+	STTBreakerThreshold int // Consecutive transient failures that pause dispatch
+	STTBreakerCooldown  int // Seconds before a trial request
+
+	// Audio processing chain applied before VAD
+	AudioProcessors  string // Comma-separated: highpass, gate, agc; empty leaves audio untouched
+	HighPassCutoffHz float64
+	NoiseGateDB      float64
+	AGCTargetDB      float64
+	AGCMaxGainDB     float64
+
+	// Voice activity detection
+	VADType           string // "webrtc" or "energy"
+	VADAggressiveness int    // webrtc: 0-3
+	VADRMSThreshold   float64
+	VADEnergyMarginDB float64 // energy: level above the noise floor
+	VADPreRollFrames  int     // 20ms frames kept before speech onset
+	VADHangoverFrames int     // 20ms frames kept after speech ends
+
+	// Raw per-speaker audio archive: "none", "wav" or "ogg"
+	AudioArchive string
+
+	// Mixed-down meeting recording: "none", "wav" or "ogg"
+	MixRecording  string
+	UploadLimitMB int // Largest total attachment size Discord accepts
+
+	// Record raw voice packets and speaking updates for replay
+	VoiceCapture bool
+
+	// Per-guild chunking overrides, fully resolved against the settings above
+	ChunkOverrides map[string]ChunkSettings
+
+	// Per-guild shares of the STT backend when sessions compete; others get 1
+	STTGuildWeights map[string]float64
+
+	// Monthly usage budgets per guild; zero is unlimited
+	BudgetSTTMinutes  int
+	BudgetTokens      int // Summariser input and output tokens
+	BudgetWarnPercent int // Share of a budget that triggers a warning
+	BudgetOverrides   map[string]Budget
 
 	// Logging
 	LogLevel string
 }
 
+// ChunkSettings controls how a guild's audio is split into chunks for STT.
+type ChunkSettings struct {
+	Strategy   string // "ring" or "endpoint"
+	Seconds    int
+	OverlapMS  int
+	MinMS      int
+	MaxSeconds int
+	HangoverMS int
+}
+
+// Budget limits what a guild can use in a calendar month (UTC). Zero
+// fields are unlimited.
+type Budget struct {
+	STTMinutes int
+	Tokens     int
+}
+
+// Load reads the configuration for running the bot.
 func Load() (*Config, error) {
+	cfg, err := LoadOffline()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DiscordToken == "" {
+		return nil, fmt.Errorf("DISCORD_TOKEN is required")
+	}
+
+	return cfg, nil
+}
+
+// LoadOffline reads the configuration for tools that don't connect to
+// Discord, such as replaying recorded audio.
+func LoadOffline() (*Config, error) {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
 		log.Warn().Err(err).Msg("No .env file found, using environment variables only")
@@ -51,17 +160,36 @@ func Load() (*Config, error) {
 		DiscordToken: os.Getenv("DISCORD_TOKEN"),
 
 		// STT Backend
-		STTBackend: getEnvOrDefault("STT_BACKEND", "vosk"),
+		STTBackend:  os.Getenv("STT_BACKEND"),
+		STTFallback: getListEnv("STT_FALLBACK"),
 
 		// Vosk
 		VoskModelPath: getEnvOrDefault("VOSK_MODEL_PATH", "./models/vosk/en"),
 
 		// Deepgram
-		DeepgramAPIKey:     os.Getenv("DEEPGRAM_API_KEY"),
-		DeepgramTier:       getEnvOrDefault("DEEPGRAM_TIER", "nova-2"),
-		DeepgramDiarize:    getBoolEnvOrDefault("DEEPGRAM_DIARIZE", true),
-		DeepgramPunctuate:  getBoolEnvOrDefault("DEEPGRAM_PUNCTUATE", true),
-		DeepgramUtterances: getBoolEnvOrDefault("DEEPGRAM_UTTERANCES", true),
+		DeepgramAPIKey:          os.Getenv("DEEPGRAM_API_KEY"),
+		DeepgramTier:            getEnvOrDefault("DEEPGRAM_TIER", "nova-2"),
+		DeepgramDiarize:         getBoolEnvOrDefault("DEEPGRAM_DIARIZE", true),
+		DeepgramPunctuate:       getBoolEnvOrDefault("DEEPGRAM_PUNCTUATE", true),
+		DeepgramUtterances:      getBoolEnvOrDefault("DEEPGRAM_UTTERANCES", true),
+		DeepgramStreaming:       getBoolEnvOrDefault("DEEPGRAM_STREAMING", false),
+		DeepgramStreamURL:       os.Getenv("DEEPGRAM_STREAM_URL"),
+		DeepgramBaseURL:         getEnvOrDefault("DEEPGRAM_BASE_URL", "https://api.deepgram.com"),
+		DeepgramLanguage:        getEnvOrDefault("DEEPGRAM_LANGUAGE", "en"),
+		DeepgramSmartFormat:     getBoolEnvOrDefault("DEEPGRAM_SMART_FORMAT", true),
+		DeepgramNumerals:        getBoolEnvOrDefault("DEEPGRAM_NUMERALS", false),
+		DeepgramProfanityFilter: getBoolEnvOrDefault("DEEPGRAM_PROFANITY_FILTER", false),
+		DeepgramRedact:          getListEnv("DEEPGRAM_REDACT"),
+		DeepgramKeywords:        getListEnv("DEEPGRAM_KEYWORDS"),
+		DeepgramKeyterms:        getListEnv("DEEPGRAM_KEYTERMS"),
+
+		// Whisper
+		WhisperURL:      getEnvOrDefault("WHISPER_URL", "http://localhost:8000"),
+		WhisperAPI:      getEnvOrDefault("WHISPER_API", "openai"),
+		WhisperModel:    getEnvOrDefault("WHISPER_MODEL", "whisper-1"),
+		WhisperLanguage: getEnvOrDefault("WHISPER_LANGUAGE", "en"),
+		WhisperPrompt:   os.Getenv("WHISPER_PROMPT"),
+		WhisperAPIKey:   os.Getenv("WHISPER_API_KEY"),
 
 		// Gemini
 		GenAIAPIKey:  os.Getenv("GENAI_API_KEY"),
@@ -69,28 +197,356 @@ func Load() (*Config, error) {
 		GenAIModel:   getEnvOrDefault("GENAI_MODEL", "gemini-2.5-flash"),
 
 		// Chunking
-		ChunkSeconds:   getIntEnvOrDefault("CHUNK_SECONDS", 5),
-		ChunkOverlapMS: getIntEnvOrDefault("CHUNK_OVERLAP_MS", 300),
-		MaxParallelSTT: getIntEnvOrDefault("MAX_PARALLEL_STT", 4),
+		ChunkStrategy:      getEnvOrDefault("CHUNK_STRATEGY", "ring"),
+		ChunkSeconds:       getIntEnvOrDefault("CHUNK_SECONDS", 5),
+		ChunkOverlapMS:     getIntEnvOrDefault("CHUNK_OVERLAP_MS", 300),
+		ChunkMinMS:         getIntEnvOrDefault("CHUNK_MIN_MS", 500),
+		ChunkMaxSeconds:    getIntEnvOrDefault("CHUNK_MAX_SECONDS", 15),
+		ChunkHangoverMS:    getIntEnvOrDefault("CHUNK_HANGOVER_MS", 600),
+		MaxParallelSTT:     getIntEnvOrDefault("MAX_PARALLEL_STT", 4),
+		MaxParallelStreams: getIntEnvOrDefault("MAX_PARALLEL_STREAMS", 20),
+		STTReorderMS:       getIntEnvOrDefault("STT_REORDER_WINDOW_MS", 10000),
+
+		// Transcript assembly
+		TurnMaxGapMS:      getIntEnvOrDefault("TURN_MAX_GAP_MS", 2000),
+		TurnSentenceGapMS: getIntEnvOrDefault("TURN_SENTENCE_GAP_MS", 700),
+		TurnMaxSeconds:    getIntEnvOrDefault("TURN_MAX_SECONDS", 60),
+
+		// STT failure handling
+		STTMaxRetries:       getIntEnvOrDefault("STT_MAX_RETRIES", 3),
+		STTRetryBaseMS:      getIntEnvOrDefault("STT_RETRY_BASE_MS", 500),
+		STTRetryMaxMS:       getIntEnvOrDefault("STT_RETRY_MAX_MS", 30000),
+		STTBreakerThreshold: getIntEnvOrDefault("STT_BREAKER_THRESHOLD", 5),
+		STTBreakerCooldown:  getIntEnvOrDefault("STT_BREAKER_COOLDOWN_SECONDS", 30),
+
+		// Audio processing
+		AudioProcessors:  os.Getenv("AUDIO_PROCESSORS"),
+		HighPassCutoffHz: getFloatEnvOrDefault("HIGHPASS_CUTOFF_HZ", 80),
+		NoiseGateDB:      getFloatEnvOrDefault("NOISE_GATE_DB", -50),
+		AGCTargetDB:      getFloatEnvOrDefault("AGC_TARGET_DB", -20),
+		AGCMaxGainDB:     getFloatEnvOrDefault("AGC_MAX_GAIN_DB", 20),
+
+		// VAD
+		VADType:           getEnvOrDefault("VAD_TYPE", "webrtc"),
+		VADAggressiveness: getIntEnvOrDefault("VAD_AGGRESSIVENESS", 2),
+		VADRMSThreshold:   getFloatEnvOrDefault("VAD_RMS_THRESHOLD", 500),
+		VADEnergyMarginDB: getFloatEnvOrDefault("VAD_ENERGY_MARGIN_DB", 10),
+		VADPreRollFrames:  getIntEnvOrDefault("VAD_PREROLL_FRAMES", 5),
+		VADHangoverFrames: getIntEnvOrDefault("VAD_HANGOVER_FRAMES", 10),
+
+		// Archive
+		AudioArchive: getEnvOrDefault("AUDIO_ARCHIVE", "none"),
+
+		// Recording
+		MixRecording:  getEnvOrDefault("MIX_RECORDING", "none"),
+		UploadLimitMB: getIntEnvOrDefault("DISCORD_UPLOAD_LIMIT_MB", 10),
+		VoiceCapture:  getBoolEnvOrDefault("VOICE_CAPTURE", false),
+
+		// Budgets
+		BudgetSTTMinutes:  getIntEnvOrDefault("BUDGET_STT_MINUTES", 0),
+		BudgetTokens:      getIntEnvOrDefault("BUDGET_TOKENS", 0),
+		BudgetWarnPercent: getIntEnvOrDefault("BUDGET_WARN_PERCENT", 80),
 
 		// Logging
 		LogLevel: getEnvOrDefault("LOG_LEVEL", "info"),
 	}
 
+	overrides, err := parseChunkOverrides(os.Getenv("CHUNK_GUILD_OVERRIDES"), cfg.defaultChunkSettings())
+	if err != nil {
+		return nil, fmt.Errorf("invalid CHUNK_GUILD_OVERRIDES: %w", err)
+	}
+	cfg.ChunkOverrides = overrides
+
+	weights, err := parseGuildWeights(os.Getenv("STT_GUILD_WEIGHTS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid STT_GUILD_WEIGHTS: %w", err)
+	}
+	cfg.STTGuildWeights = weights
+
+	budgets, err := parseBudgetOverrides(os.Getenv("BUDGET_GUILD_OVERRIDES"), cfg.defaultBudget())
+	if err != nil {
+		return nil, fmt.Errorf("invalid BUDGET_GUILD_OVERRIDES: %w", err)
+	}
+	cfg.BudgetOverrides = budgets
+
 	return cfg, cfg.validate()
 }
 
+// ChunkSettingsForGuild returns the chunking settings for a guild, applying
+// any per-guild override.
+func (c *Config) ChunkSettingsForGuild(guildID string) ChunkSettings {
+	if settings, ok := c.ChunkOverrides[guildID]; ok {
+		return settings
+	}
+	return c.defaultChunkSettings()
+}
+
+// STTWeightForGuild returns a guild's share of the STT backend relative to
+// other guilds transcribing at the same time.
+func (c *Config) STTWeightForGuild(guildID string) float64 {
+	if weight, ok := c.STTGuildWeights[guildID]; ok {
+		return weight
+	}
+	return 1
+}
+
+// BudgetForGuild returns a guild's monthly budget, applying any per-guild
+// override.
+func (c *Config) BudgetForGuild(guildID string) Budget {
+	if budget, ok := c.BudgetOverrides[guildID]; ok {
+		return budget
+	}
+	return c.defaultBudget()
+}
+
+func (c *Config) defaultBudget() Budget {
+	return Budget{
+		STTMinutes: c.BudgetSTTMinutes,
+		Tokens:     c.BudgetTokens,
+	}
+}
+
+func (c *Config) defaultChunkSettings() ChunkSettings {
+	return ChunkSettings{
+		Strategy:   c.ChunkStrategy,
+		Seconds:    c.ChunkSeconds,
+		OverlapMS:  c.ChunkOverlapMS,
+		MinMS:      c.ChunkMinMS,
+		MaxSeconds: c.ChunkMaxSeconds,
+		HangoverMS: c.ChunkHangoverMS,
+	}
+}
+
+// parseChunkOverrides parses per-guild chunk settings of the form
+// "guildID:key=value,key=value;guildID:key=value". Keys not given for a guild
+// keep their default values.
+func parseChunkOverrides(value string, defaults ChunkSettings) (map[string]ChunkSettings, error) {
+	overrides := make(map[string]ChunkSettings)
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		guildID, options, ok := strings.Cut(entry, ":")
+		guildID = strings.TrimSpace(guildID)
+		if !ok || guildID == "" {
+			return nil, fmt.Errorf("expected guildID:key=value, got %q", entry)
+		}
+
+		settings := defaults
+		for _, option := range strings.Split(options, ",") {
+			key, val, ok := strings.Cut(strings.TrimSpace(option), "=")
+			if !ok {
+				return nil, fmt.Errorf("expected key=value for guild %s, got %q", guildID, option)
+			}
+
+			if key == "strategy" {
+				settings.Strategy = val
+				continue
+			}
+
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s in guild %s: %w", key, guildID, err)
+			}
+
+			switch key {
+			case "seconds":
+				settings.Seconds = n
+			case "overlap_ms":
+				settings.OverlapMS = n
+			case "min_ms":
+				settings.MinMS = n
+			case "max_seconds":
+				settings.MaxSeconds = n
+			case "hangover_ms":
+				settings.HangoverMS = n
+			default:
+				return nil, fmt.Errorf("unknown chunk setting %q for guild %s", key, guildID)
+			}
+		}
+
+		overrides[guildID] = settings
+	}
+
+	return overrides, nil
+}
+
+// parseGuildWeights parses per-guild STT weights of the form
+// "guildID:weight,guildID:weight".
+func parseGuildWeights(value string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		guildID, val, ok := strings.Cut(entry, ":")
+		guildID = strings.TrimSpace(guildID)
+		if !ok || guildID == "" {
+			return nil, fmt.Errorf("expected guildID:weight, got %q", entry)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight for guild %s: %w", guildID, err)
+		}
+		if weight <= 0 {
+			return nil, fmt.Errorf("weight for guild %s must be positive", guildID)
+		}
+
+		weights[guildID] = weight
+	}
+
+	return weights, nil
+}
+
+// parseBudgetOverrides parses per-guild budgets of the form
+// "guildID:key=value,key=value;guildID:key=value". Keys not given for a
+// guild keep their default values.
+func parseBudgetOverrides(value string, defaults Budget) (map[string]Budget, error) {
+	overrides := make(map[string]Budget)
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		guildID, options, ok := strings.Cut(entry, ":")
+		guildID = strings.TrimSpace(guildID)
+		if !ok || guildID == "" {
+			return nil, fmt.Errorf("expected guildID:key=value, got %q", entry)
+		}
+
+		budget := defaults
+		for _, option := range strings.Split(options, ",") {
+			key, val, ok := strings.Cut(strings.TrimSpace(option), "=")
+			if !ok {
+				return nil, fmt.Errorf("expected key=value for guild %s, got %q", guildID, option)
+			}
+
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s in guild %s: %w", key, guildID, err)
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("%s for guild %s must not be negative", key, guildID)
+			}
+
+			switch key {
+			case "stt_minutes":
+				budget.STTMinutes = n
+			case "tokens":
+				budget.Tokens = n
+			default:
+				return nil, fmt.Errorf("unknown budget setting %q for guild %s", key, guildID)
+			}
+		}
+
+		overrides[guildID] = budget
+	}
+
+	return overrides, nil
+}
+
 func (c *Config) validate() error {
-	if c.DiscordToken == "" {
-		return fmt.Errorf("DISCORD_TOKEN is required")
+	// The bot picks and checks the default backend, which depends on the build
+	if c.STTBackend != "" {
+		if err := c.ValidateBackend(c.STTBackend, "STT_BACKEND"); err != nil {
+			return err
+		}
+	}
+
+	if c.DeepgramStreaming && c.DeepgramStreamURL != "" {
+		u, err := url.Parse(c.DeepgramStreamURL)
+		if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") {
+			return fmt.Errorf("DEEPGRAM_STREAM_URL must be a ws:// or wss:// URL")
+		}
+	}
+
+	seen := map[string]bool{c.STTBackend: true}
+	for _, backend := range c.STTFallback {
+		if seen[backend] {
+			return fmt.Errorf("STT_FALLBACK lists %q more than once or repeats STT_BACKEND", backend)
+		}
+		seen[backend] = true
+
+		if err := c.ValidateBackend(backend, "STT_FALLBACK"); err != nil {
+			return err
+		}
+	}
+
+	if c.MaxParallelSTT <= 0 {
+		return fmt.Errorf("MAX_PARALLEL_STT must be positive")
+	}
+	if c.DeepgramStreaming && c.MaxParallelStreams <= 0 {
+		return fmt.Errorf("MAX_PARALLEL_STREAMS must be positive")
+	}
+
+	if c.STTMaxRetries < 0 {
+		return fmt.Errorf("STT_MAX_RETRIES must not be negative")
+	}
+
+	if c.STTReorderMS < 0 {
+		return fmt.Errorf("STT_REORDER_WINDOW_MS must not be negative")
+	}
+
+	if c.TurnMaxGapMS <= 0 || c.TurnSentenceGapMS <= 0 || c.TurnSentenceGapMS > c.TurnMaxGapMS || c.TurnMaxSeconds <= 0 {
+		return fmt.Errorf("TURN_MAX_GAP_MS, TURN_SENTENCE_GAP_MS and TURN_MAX_SECONDS must be positive, and TURN_SENTENCE_GAP_MS no larger than TURN_MAX_GAP_MS")
+	}
+
+	if c.STTRetryBaseMS <= 0 || c.STTRetryMaxMS < c.STTRetryBaseMS {
+		return fmt.Errorf("STT_RETRY_BASE_MS must be positive and no larger than STT_RETRY_MAX_MS")
+	}
+
+	if c.STTBreakerThreshold < 0 || c.STTBreakerCooldown <= 0 {
+		return fmt.Errorf("STT_BREAKER_THRESHOLD must not be negative and STT_BREAKER_COOLDOWN_SECONDS must be positive")
+	}
+
+	if c.BudgetSTTMinutes < 0 || c.BudgetTokens < 0 {
+		return fmt.Errorf("BUDGET_STT_MINUTES and BUDGET_TOKENS must not be negative")
+	}
+
+	if c.BudgetWarnPercent <= 0 || c.BudgetWarnPercent > 100 {
+		return fmt.Errorf("BUDGET_WARN_PERCENT must be between 1 and 100")
+	}
+
+	if c.ChunkStrategy != "ring" && c.ChunkStrategy != "endpoint" {
+		return fmt.Errorf("CHUNK_STRATEGY must be 'ring' or 'endpoint'")
 	}
 
-	if c.STTBackend != "vosk" && c.STTBackend != "deepgram" {
-		return fmt.Errorf("STT_BACKEND must be 'vosk' or 'deepgram'")
+	if err := validateChunkSettings(c.defaultChunkSettings()); err != nil {
+		return fmt.Errorf("invalid chunk settings: %w", err)
 	}
 
-	if c.STTBackend == "deepgram" && c.DeepgramAPIKey == "" {
-		return fmt.Errorf("DEEPGRAM_API_KEY is required when using deepgram backend")
+	if c.VADType != "webrtc" && c.VADType != "energy" {
+		return fmt.Errorf("VAD_TYPE must be 'webrtc' or 'energy'")
+	}
+
+	if c.AudioArchive != "none" && c.AudioArchive != "wav" && c.AudioArchive != "ogg" {
+		return fmt.Errorf("AUDIO_ARCHIVE must be 'none', 'wav' or 'ogg'")
+	}
+
+	if c.MixRecording != "none" && c.MixRecording != "wav" && c.MixRecording != "ogg" {
+		return fmt.Errorf("MIX_RECORDING must be 'none', 'wav' or 'ogg'")
+	}
+
+	if c.UploadLimitMB <= 0 {
+		return fmt.Errorf("DISCORD_UPLOAD_LIMIT_MB must be positive")
+	}
+
+	for guildID, settings := range c.ChunkOverrides {
+		if settings.Strategy != "ring" && settings.Strategy != "endpoint" {
+			return fmt.Errorf("chunk strategy for guild %s must be 'ring' or 'endpoint'", guildID)
+		}
+		if err := validateChunkSettings(settings); err != nil {
+			return fmt.Errorf("invalid chunk settings for guild %s: %w", guildID, err)
+		}
 	}
 
 	if c.GenAIAPIKey == "" {
@@ -100,6 +556,57 @@ func (c *Config) validate() error {
 	return nil
 }
 
+// validateChunkSettings checks the numeric chunk settings, whichever
+// strategy is selected, so mistakes show up at startup rather than at !join.
+func validateChunkSettings(settings ChunkSettings) error {
+	if settings.Seconds <= 0 {
+		return fmt.Errorf("CHUNK_SECONDS must be positive")
+	}
+	if settings.OverlapMS < 0 || settings.OverlapMS >= settings.Seconds*1000 {
+		return fmt.Errorf("CHUNK_OVERLAP_MS must be between 0 and the chunk length")
+	}
+	if settings.MaxSeconds <= 0 || settings.HangoverMS <= 0 {
+		return fmt.Errorf("CHUNK_MAX_SECONDS and CHUNK_HANGOVER_MS must be positive")
+	}
+	if settings.MinMS < 0 || settings.MinMS > settings.MaxSeconds*1000 {
+		return fmt.Errorf("CHUNK_MIN_MS must be between 0 and CHUNK_MAX_SECONDS")
+	}
+	return nil
+}
+
+// ValidateBackend checks that an STT backend named in setting is known and
+// has the settings it needs. Whether this build includes Vosk is checked
+// when the backend is created.
+func (c *Config) ValidateBackend(backend, setting string) error {
+	switch backend {
+	case "vosk":
+		if c.VoskModelPath == "" {
+			return fmt.Errorf("VOSK_MODEL_PATH is required when using vosk backend")
+		}
+	case "deepgram":
+		if c.DeepgramAPIKey == "" {
+			return fmt.Errorf("DEEPGRAM_API_KEY is required when using deepgram backend")
+		}
+		u, err := url.Parse(c.DeepgramBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("DEEPGRAM_BASE_URL must be an http:// or https:// URL")
+		}
+		if c.DeepgramLanguage == "" {
+			return fmt.Errorf("DEEPGRAM_LANGUAGE must be a language code or 'auto'")
+		}
+	case "whisper":
+		if c.WhisperURL == "" {
+			return fmt.Errorf("WHISPER_URL is required when using whisper backend")
+		}
+		if c.WhisperAPI != "openai" && c.WhisperAPI != "whispercpp" {
+			return fmt.Errorf("WHISPER_API must be 'openai' or 'whispercpp'")
+		}
+	default:
+		return fmt.Errorf("%s must be 'vosk', 'deepgram' or 'whisper', got %q", setting, backend)
+	}
+	return nil
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -116,6 +623,26 @@ func getIntEnvOrDefault(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getFloatEnvOrDefault(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
+// getListEnv splits a comma separated variable, dropping empty entries.
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getBoolEnvOrDefault(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolVal, err := strconv.ParseBool(value); err == nil {
@@ -123,4 +650,4 @@ func getBoolEnvOrDefault(key string, defaultValue bool) bool {
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
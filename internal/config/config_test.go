@@ -0,0 +1,203 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChunkOverrides(t *testing.T) {
+	defaults := ChunkSettings{Strategy: "ring", Seconds: 5, OverlapMS: 300, MinMS: 500, MaxSeconds: 15, HangoverMS: 600}
+
+	tests := []struct {
+		value   string
+		want    map[string]ChunkSettings
+		wantErr bool
+	}{
+		{value: "", want: map[string]ChunkSettings{}},
+		{
+			value: "123:strategy=endpoint,hangover_ms=900;456:seconds=10",
+			want: map[string]ChunkSettings{
+				"123": {Strategy: "endpoint", Seconds: 5, OverlapMS: 300, MinMS: 500, MaxSeconds: 15, HangoverMS: 900},
+				"456": {Strategy: "ring", Seconds: 10, OverlapMS: 300, MinMS: 500, MaxSeconds: 15, HangoverMS: 600},
+			},
+		},
+		{value: "123:seconds", wantErr: true},
+		{value: "123:seconds=five", wantErr: true},
+		{value: "123:speed=2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseChunkOverrides(tt.value, defaults)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseChunkOverrides(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseChunkOverrides(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidateChunkSettings(t *testing.T) {
+	valid := ChunkSettings{Strategy: "ring", Seconds: 5, OverlapMS: 300, MinMS: 500, MaxSeconds: 15, HangoverMS: 600}
+
+	tests := []struct {
+		name    string
+		modify  func(*ChunkSettings)
+		wantErr bool
+	}{
+		{name: "defaults", modify: func(*ChunkSettings) {}},
+		{name: "no overlap", modify: func(s *ChunkSettings) { s.OverlapMS = 0 }},
+		{name: "zero seconds", modify: func(s *ChunkSettings) { s.Seconds = 0 }, wantErr: true},
+		{name: "overlap as long as the chunk", modify: func(s *ChunkSettings) { s.OverlapMS = 5000 }, wantErr: true},
+		{name: "negative overlap", modify: func(s *ChunkSettings) { s.OverlapMS = -1 }, wantErr: true},
+		{name: "zero max seconds", modify: func(s *ChunkSettings) { s.MaxSeconds = 0 }, wantErr: true},
+		{name: "zero hangover", modify: func(s *ChunkSettings) { s.HangoverMS = 0 }, wantErr: true},
+		{name: "minimum past the maximum", modify: func(s *ChunkSettings) { s.MinMS = 16000 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := valid
+			tt.modify(&settings)
+			if err := validateChunkSettings(settings); (err != nil) != tt.wantErr {
+				t.Errorf("validateChunkSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChunkSettingsForGuild(t *testing.T) {
+	cfg := &Config{
+		ChunkStrategy:  "ring",
+		ChunkSeconds:   5,
+		ChunkOverrides: map[string]ChunkSettings{"slow": {Strategy: "endpoint", Seconds: 10}},
+	}
+
+	if got := cfg.ChunkSettingsForGuild("slow"); got.Strategy != "endpoint" || got.Seconds != 10 {
+		t.Errorf("ChunkSettingsForGuild(slow) = %+v, want the override", got)
+	}
+	if got := cfg.ChunkSettingsForGuild("other"); got.Strategy != "ring" || got.Seconds != 5 {
+		t.Errorf("ChunkSettingsForGuild(other) = %+v, want the defaults", got)
+	}
+}
+
+func TestValidateMaxParallelSTT(t *testing.T) {
+	for _, workers := range []int{0, -1} {
+		cfg := &Config{STTBackend: "whisper", WhisperURL: "http://localhost:8000", WhisperAPI: "openai", MaxParallelSTT: workers}
+		if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "MAX_PARALLEL_STT") {
+			t.Errorf("validate() with MAX_PARALLEL_STT=%d = %v, want it rejected", workers, err)
+		}
+	}
+}
+
+func TestValidateMaxParallelStreams(t *testing.T) {
+	tests := []struct {
+		streaming bool
+		streams   int
+		wantErr   bool
+	}{
+		{streaming: false, streams: 0, wantErr: false},
+		{streaming: true, streams: 0, wantErr: true},
+		{streaming: true, streams: -1, wantErr: true},
+		{streaming: true, streams: 20, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{STTBackend: "whisper", WhisperURL: "http://localhost:8000", WhisperAPI: "openai", MaxParallelSTT: 4,
+			DeepgramStreaming: tt.streaming, MaxParallelStreams: tt.streams}
+		err := cfg.validate()
+		if got := err != nil && strings.Contains(err.Error(), "MAX_PARALLEL_STREAMS"); got != tt.wantErr {
+			t.Errorf("validate() streaming=%v with MAX_PARALLEL_STREAMS=%d = %v, want rejected %v", tt.streaming, tt.streams, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseGuildWeights(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{value: "", want: map[string]float64{}},
+		{value: "123:2, 456:0.5,", want: map[string]float64{"123": 2, "456": 0.5}},
+		{value: "123", wantErr: true},
+		{value: ":2", wantErr: true},
+		{value: "123:heavy", wantErr: true},
+		{value: "123:0", wantErr: true},
+		{value: "123:-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseGuildWeights(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGuildWeights(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseGuildWeights(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSTTWeightForGuild(t *testing.T) {
+	cfg := &Config{STTGuildWeights: map[string]float64{"heavy": 3}}
+
+	if got := cfg.STTWeightForGuild("heavy"); got != 3 {
+		t.Errorf("STTWeightForGuild(heavy) = %v, want 3", got)
+	}
+	if got := cfg.STTWeightForGuild("other"); got != 1 {
+		t.Errorf("STTWeightForGuild(other) = %v, want 1", got)
+	}
+}
+
+func TestParseBudgetOverrides(t *testing.T) {
+	defaults := Budget{STTMinutes: 600, Tokens: 100000}
+
+	tests := []struct {
+		value   string
+		want    map[string]Budget
+		wantErr bool
+	}{
+		{value: "", want: map[string]Budget{}},
+		{
+			value: "123:stt_minutes=60; 456:tokens=0,stt_minutes=0",
+			want: map[string]Budget{
+				"123": {STTMinutes: 60, Tokens: 100000},
+				"456": {},
+			},
+		},
+		{value: "123", wantErr: true},
+		{value: "123:stt_minutes", wantErr: true},
+		{value: "123:stt_minutes=lots", wantErr: true},
+		{value: "123:stt_minutes=-1", wantErr: true},
+		{value: "123:dollars=5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseBudgetOverrides(tt.value, defaults)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBudgetOverrides(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseBudgetOverrides(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBudgetForGuild(t *testing.T) {
+	cfg := &Config{
+		BudgetSTTMinutes: 600,
+		BudgetTokens:     100000,
+		BudgetOverrides:  map[string]Budget{"small": {STTMinutes: 60}},
+	}
+
+	if got, want := cfg.BudgetForGuild("small"), (Budget{STTMinutes: 60}); got != want {
+		t.Errorf("BudgetForGuild(small) = %+v, want %+v", got, want)
+	}
+	if got, want := cfg.BudgetForGuild("other"), (Budget{STTMinutes: 600, Tokens: 100000}); got != want {
+		t.Errorf("BudgetForGuild(other) = %+v, want %+v", got, want)
+	}
+}
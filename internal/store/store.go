@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/user/discord-notetaker/internal/audio"
@@ -12,7 +13,32 @@ import (
 )
 
 type FileStore struct {
-	baseDir string
+	baseDir  string
+	usageMux sync.Mutex // Serialises updates to the usage ledger
+}
+
+// SessionMetadata describes a recorded session and its artifacts.
+type SessionMetadata struct {
+	SessionID      string         `json:"session_id"`
+	GuildID        string         `json:"guild_id"`
+	ChannelID      string         `json:"channel_id"`
+	TextChannelID  string         `json:"text_channel_id"`
+	StartedAt      time.Time      `json:"started_at"`
+	EndedAt        time.Time      `json:"ended_at"`
+	TranscriptPath string         `json:"transcript_path,omitempty"`
+	NotesPath      string         `json:"notes_path,omitempty"`
+	RecordingPath  string         `json:"recording_path,omitempty"`
+	CapturePath    string         `json:"capture_path,omitempty"`
+	Archives       []ArchiveTrack `json:"archives,omitempty"`
+	Usage          *Usage         `json:"usage,omitempty"`
+}
+
+// ArchiveTrack is a single speaker's archived audio.
+type ArchiveTrack struct {
+	SSRC   uint32 `json:"ssrc"`
+	UserID string `json:"user_id"`
+	Format string `json:"format"` // "wav" or "ogg"
+	Path   string `json:"path"`
 }
 
 func NewFileStore(baseDir string) (*FileStore, error) {
@@ -100,6 +126,57 @@ func (s *FileStore) LoadTranscript(sessionID string) ([]audio.Utterance, error)
 	return utterances, nil
 }
 
+// SessionDir returns the directory holding a session's artifacts, creating it
+// if needed.
+func (s *FileStore) SessionDir(sessionID string) (string, error) {
+	dir := filepath.Join(s.baseDir, "sessions", sessionID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return dir, nil
+}
+
+func (s *FileStore) SaveMetadata(metadata *SessionMetadata) (string, error) {
+	dir, err := s.SessionDir(metadata.SessionID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	filepath := filepath.Join(dir, "metadata.json")
+	if err := os.WriteFile(filepath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write metadata file: %w", err)
+	}
+
+	log.Info().
+		Str("session_id", metadata.SessionID).
+		Str("file", filepath).
+		Int("archives", len(metadata.Archives)).
+		Msg("Saved session metadata")
+
+	return filepath, nil
+}
+
+func (s *FileStore) LoadMetadata(sessionID string) (*SessionMetadata, error) {
+	filepath := filepath.Join(s.baseDir, "sessions", sessionID, "metadata.json")
+
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata file: %w", err)
+	}
+
+	var metadata SessionMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	return &metadata, nil
+}
+
 func GenerateSessionID() string {
 	return fmt.Sprintf("session_%s", time.Now().Format("20060102_150405"))
 }
\ No newline at end of file
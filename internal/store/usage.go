@@ -0,0 +1,130 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Usage is how much of the paid services a session or guild used.
+type Usage struct {
+	STTSeconds   float64 `json:"stt_seconds"`   // Audio sent for transcription
+	InputTokens  int64   `json:"input_tokens"`  // Summariser prompt tokens
+	OutputTokens int64   `json:"output_tokens"` // Summariser response tokens
+	Sessions     int     `json:"sessions,omitempty"`
+}
+
+// Add adds other's totals to u.
+func (u *Usage) Add(other Usage) {
+	u.STTSeconds += other.STTSeconds
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.Sessions += other.Sessions
+}
+
+// STTMinutes returns the transcribed audio in minutes, the unit STT vendors
+// bill in.
+func (u Usage) STTMinutes() float64 {
+	return u.STTSeconds / 60
+}
+
+// Tokens returns the summariser tokens used in both directions.
+func (u Usage) Tokens() int64 {
+	return u.InputTokens + u.OutputTokens
+}
+
+// usageLedger holds usage totals by month ("2006-01", UTC), then guild.
+type usageLedger map[string]map[string]Usage
+
+// UsageMonth returns the billing month t falls in.
+func UsageMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// ReplayUsageKey is the ledger key for a guild's offline replays, which
+// are billed but kept apart from the guild's meetings.
+func ReplayUsageKey(guildID string) string {
+	return "replay:" + guildID
+}
+
+// AddUsage adds a session's usage to its guild's total for the month it
+// ended in, and returns the new total. guildID may also be a ReplayUsageKey.
+func (s *FileStore) AddUsage(guildID string, at time.Time, usage Usage) (Usage, error) {
+	s.usageMux.Lock()
+	defer s.usageMux.Unlock()
+
+	ledger, err := s.loadUsageLocked()
+	if err != nil {
+		return Usage{}, err
+	}
+
+	month := UsageMonth(at)
+	if ledger[month] == nil {
+		ledger[month] = make(map[string]Usage)
+	}
+	total := ledger[month][guildID]
+	total.Add(usage)
+	ledger[month][guildID] = total
+
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to encode usage: %w", err)
+	}
+
+	// Write then rename, so a crash never leaves a truncated ledger
+	path := s.usagePath()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return Usage{}, fmt.Errorf("failed to write usage file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return Usage{}, fmt.Errorf("failed to replace usage file: %w", err)
+	}
+
+	log.Info().
+		Str("guild_id", guildID).
+		Str("month", month).
+		Float64("stt_minutes", total.STTMinutes()).
+		Int64("tokens", total.Tokens()).
+		Msg("Recorded usage")
+
+	return total, nil
+}
+
+// GuildUsage returns a guild's usage totals for a month.
+func (s *FileStore) GuildUsage(guildID, month string) (Usage, error) {
+	s.usageMux.Lock()
+	defer s.usageMux.Unlock()
+
+	ledger, err := s.loadUsageLocked()
+	if err != nil {
+		return Usage{}, err
+	}
+	return ledger[month][guildID], nil
+}
+
+func (s *FileStore) loadUsageLocked() (usageLedger, error) {
+	ledger := make(usageLedger)
+
+	data, err := os.ReadFile(s.usagePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &ledger); err != nil {
+		return nil, fmt.Errorf("failed to decode usage: %w", err)
+	}
+	return ledger, nil
+}
+
+func (s *FileStore) usagePath() string {
+	return filepath.Join(s.baseDir, "usage.json")
+}
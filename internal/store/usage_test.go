@@ -0,0 +1,107 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsageTotals(t *testing.T) {
+	total := Usage{STTSeconds: 60, InputTokens: 100, OutputTokens: 20, Sessions: 1}
+	total.Add(Usage{STTSeconds: 30, InputTokens: 50, OutputTokens: 10, Sessions: 1})
+
+	want := Usage{STTSeconds: 90, InputTokens: 150, OutputTokens: 30, Sessions: 2}
+	if total != want {
+		t.Errorf("Add = %+v, want %+v", total, want)
+	}
+	if got := total.STTMinutes(); got != 1.5 {
+		t.Errorf("STTMinutes = %v, want 1.5", got)
+	}
+	if got := total.Tokens(); got != 180 {
+		t.Errorf("Tokens = %v, want 180", got)
+	}
+}
+
+func TestUsageMonth(t *testing.T) {
+	// Billing months are UTC whatever the local zone
+	east := time.FixedZone("UTC+10", 10*60*60)
+
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2024, 5, 31, 23, 59, 0, 0, time.UTC), "2024-05"},
+		{time.Date(2024, 6, 1, 8, 0, 0, 0, east), "2024-05"},
+		{time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC), "2024-12"},
+	}
+
+	for _, tt := range tests {
+		if got := UsageMonth(tt.at); got != tt.want {
+			t.Errorf("UsageMonth(%s) = %q, want %q", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestFileStoreUsage(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+
+	may := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)
+	session := Usage{STTSeconds: 120, InputTokens: 1000, OutputTokens: 200, Sessions: 1}
+
+	adds := []struct {
+		guildID string
+		at      time.Time
+	}{
+		{"guild", may},
+		{"guild", may.Add(24 * time.Hour)},
+		{"guild", june},
+		{"other", may},
+		{ReplayUsageKey("guild"), may},
+	}
+	for _, add := range adds {
+		if _, err := s.AddUsage(add.guildID, add.at, session); err != nil {
+			t.Fatalf("AddUsage(%s) failed: %v", add.guildID, err)
+		}
+	}
+
+	tests := []struct {
+		guildID string
+		month   string
+		want    int // Sessions added
+	}{
+		{"guild", "2024-05", 2},
+		{"guild", "2024-06", 1},
+		{"other", "2024-05", 1},
+		{"other", "2024-06", 0},
+		// Replays are kept apart from the guild's meetings
+		{ReplayUsageKey("guild"), "2024-05", 1},
+		{"unknown", "2024-05", 0},
+	}
+	for _, tt := range tests {
+		got, err := s.GuildUsage(tt.guildID, tt.month)
+		if err != nil {
+			t.Fatalf("GuildUsage(%s, %s) failed: %v", tt.guildID, tt.month, err)
+		}
+		want := Usage{
+			STTSeconds:   session.STTSeconds * float64(tt.want),
+			InputTokens:  session.InputTokens * int64(tt.want),
+			OutputTokens: session.OutputTokens * int64(tt.want),
+			Sessions:     tt.want,
+		}
+		if got != want {
+			t.Errorf("GuildUsage(%s, %s) = %+v, want %+v", tt.guildID, tt.month, got, want)
+		}
+	}
+
+	// A fresh store reads the ledger back from disk
+	reopened, err := NewFileStore(s.baseDir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	if got, _ := reopened.GuildUsage("guild", "2024-05"); got.Sessions != 2 {
+		t.Errorf("reopened GuildUsage = %+v, want 2 sessions", got)
+	}
+}
@@ -0,0 +1,127 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// errPoolStopped is returned to workers waiting on a retry or the breaker
+// when the pool stops.
+var errPoolStopped = errors.New("transcriber pool stopped")
+
+// breakerPollInterval is how often workers check whether a trial request
+// through a half-open breaker has finished.
+const breakerPollInterval = 250 * time.Millisecond
+
+// circuitBreaker stops workers hammering a backend that is down. After
+// threshold consecutive transient failures it opens for cooldown, then lets
+// a single trial request through; success closes it, failure reopens it.
+type circuitBreaker struct {
+	name      string // Logged with state changes
+	threshold int
+	cooldown  time.Duration
+
+	failures  int       // Consecutive transient failures
+	openUntil time.Time // Zero while closed
+	probing   bool      // A trial request is in flight
+	mutex     sync.Mutex
+}
+
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// wait blocks until a request may be sent. trial is set when the request
+// is the half-open breaker's trial, whose outcome must be reported with
+// success, failure or abort so other workers stop waiting for it.
+func (b *circuitBreaker) wait(ctx context.Context, stop <-chan struct{}) (trial bool, err error) {
+	for {
+		b.mutex.Lock()
+		var delay time.Duration
+		switch {
+		case b.openUntil.IsZero():
+			b.mutex.Unlock()
+			return false, nil
+		case time.Now().Before(b.openUntil):
+			delay = time.Until(b.openUntil)
+		case !b.probing:
+			b.probing = true
+			b.mutex.Unlock()
+			log.Info().Str("breaker", b.name).Msg("STT circuit breaker half-open, sending trial request")
+			return true, nil
+		default:
+			delay = breakerPollInterval
+		}
+		b.mutex.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-stop:
+			return false, errPoolStopped
+		}
+	}
+}
+
+// isOpen reports whether requests are currently being held back.
+func (b *circuitBreaker) isOpen() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return !b.openUntil.IsZero() && time.Now().Before(b.openUntil)
+}
+
+// success records a response from the backend, which closes the breaker.
+// Permanent errors count too: the backend is up, the request was bad.
+func (b *circuitBreaker) success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.openUntil.IsZero() {
+		log.Info().Str("breaker", b.name).Msg("STT backend recovered, circuit breaker closed")
+	}
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.probing = false
+}
+
+// abort gives up a trial request that ended without an answer from the
+// backend, such as one cancelled by shutdown, so the next request becomes
+// the trial instead.
+func (b *circuitBreaker) abort() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+}
+
+// failure records a transient failure.
+func (b *circuitBreaker) failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	if b.threshold <= 0 {
+		return
+	}
+
+	// Failures of requests sent before the breaker opened don't extend it;
+	// the first failure after the cooldown reopens it
+	open := !b.openUntil.IsZero() && time.Now().Before(b.openUntil)
+	if b.probing || (!open && b.failures >= b.threshold) {
+		b.probing = false
+		b.openUntil = time.Now().Add(b.cooldown)
+		log.Warn().
+			Str("breaker", b.name).
+			Int("consecutive_failures", b.failures).
+			Dur("cooldown", b.cooldown).
+			Msg("STT backend failing, circuit breaker open")
+	}
+}
@@ -0,0 +1,133 @@
+package stt
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// funcTranscriber is a Transcriber backed by a function.
+type funcTranscriber func(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error)
+
+func (f funcTranscriber) Transcribe(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+	return f(ctx, chunk)
+}
+
+func (f funcTranscriber) SampleRate() int { return 0 }
+func (f funcTranscriber) Close() error    { return nil }
+
+var errUnavailable = &APIError{Backend: "test", StatusCode: http.StatusServiceUnavailable}
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    func(b *circuitBreaker)
+		wantOpen bool
+	}{
+		{"closed after fewer failures than the threshold", func(b *circuitBreaker) {
+			b.failure()
+			b.failure()
+		}, false},
+		{"opens at the threshold", func(b *circuitBreaker) {
+			b.failure()
+			b.failure()
+			b.failure()
+		}, true},
+		{"success resets the count", func(b *circuitBreaker) {
+			b.failure()
+			b.failure()
+			b.success()
+			b.failure()
+		}, false},
+		{"disabled without a threshold", func(b *circuitBreaker) {
+			b.threshold = 0
+			for i := 0; i < 10; i++ {
+				b.failure()
+			}
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker("test", 3, time.Minute)
+			tt.steps(b)
+			if got := b.isOpen(); got != tt.wantOpen {
+				t.Errorf("isOpen() = %v, want %v", got, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	ctx := context.Background()
+	b := newCircuitBreaker("test", 1, 10*time.Millisecond)
+	b.failure()
+
+	trial, err := b.wait(ctx, nil)
+	if err != nil || !trial {
+		t.Fatalf("wait() = %v, %v, want the trial request", trial, err)
+	}
+
+	// Others wait for the trial's outcome
+	waitCtx, cancel := context.WithTimeout(ctx, 3*breakerPollInterval)
+	defer cancel()
+	if _, err := b.wait(waitCtx, nil); err == nil {
+		t.Fatal("a second request got through while the trial was in flight")
+	}
+
+	// A failed trial reopens the breaker
+	b.failure()
+	if !b.isOpen() {
+		t.Fatal("breaker not reopened after the trial failed")
+	}
+
+	trial, err = b.wait(ctx, nil)
+	if err != nil || !trial {
+		t.Fatalf("wait() after cooldown = %v, %v, want another trial", trial, err)
+	}
+	b.success()
+	if trial, err := b.wait(ctx, nil); err != nil || trial {
+		t.Fatalf("wait() once closed = %v, %v, want a normal request", trial, err)
+	}
+}
+
+func TestTranscribeCancelledTrialReleasesBreaker(t *testing.T) {
+	calls := 0
+	pool := NewTranscriberPool(funcTranscriber(func(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+		calls++
+		if calls == 2 {
+			// The trial request is cut short by shutdown
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		if calls == 1 {
+			return nil, errUnavailable
+		}
+		return []audio.Utterance{{Text: "hello"}}, nil
+	}), 1, RetryPolicy{BreakerThreshold: 1, BreakerCooldown: 10 * time.Millisecond})
+
+	chunk := &audio.Chunk{PCM: make([]int16, audio.FrameSize)}
+	if _, err := pool.transcribe(context.Background(), chunk, nil); err == nil {
+		t.Fatal("first request succeeded, want the backend error")
+	}
+	if !pool.breaker.isOpen() {
+		t.Fatal("breaker not open after a failure at threshold 1")
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.transcribe(ctx, chunk, nil); err == nil {
+		t.Fatal("cancelled trial succeeded")
+	}
+
+	// The next request must become the trial rather than wait forever
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), time.Second)
+	defer cancelWait()
+	utterances, err := pool.transcribe(waitCtx, chunk, nil)
+	if err != nil || len(utterances) != 1 {
+		t.Fatalf("transcribe after the cancelled trial = %v, %v, want one utterance", utterances, err)
+	}
+}
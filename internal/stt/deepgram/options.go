@@ -0,0 +1,119 @@
+package deepgram
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is Deepgram's hosted API.
+const DefaultBaseURL = "https://api.deepgram.com"
+
+// LanguageAuto asks Deepgram to detect the spoken language.
+const LanguageAuto = "auto"
+
+const (
+	// requestTimeout bounds a single prerecorded request
+	requestTimeout = 60 * time.Second
+
+	// maxIdleConnsPerHost keeps connections to the API warm for every STT
+	// worker; the default of 2 forces new TLS handshakes under load
+	maxIdleConnsPerHost = 16
+)
+
+// Options are the request features shared by prerecorded and streaming
+// transcription.
+type Options struct {
+	APIKey      string
+	BaseURL     string // REST API root, DefaultBaseURL unless self-hosted or faked
+	Model       string
+	Language    string // BCP-47 tag such as "en" or "en-GB", or LanguageAuto
+	Diarize     bool
+	Punctuate   bool
+	Utterances  bool // Prerecorded only
+	SmartFormat bool
+	Numerals    bool
+	Profanity   bool     // Mask profanity
+	Redact      []string // e.g. pci, ssn, numbers
+	Keywords    []string // "word" or "word:boost"; older models
+	Keyterms    []string // Phrases to boost; nova-3 models
+}
+
+// query returns the request parameters for these options. Streaming takes
+// the same features apart from utterance segmentation and language
+// detection, which it can only approximate with multilingual recognition.
+func (o Options) query(streaming bool) url.Values {
+	params := url.Values{}
+
+	if o.Model != "" {
+		params.Set("model", o.Model)
+	}
+
+	switch {
+	case o.Language == LanguageAuto && streaming:
+		params.Set("language", "multi")
+	case o.Language == LanguageAuto:
+		params.Set("detect_language", "true")
+	case o.Language != "":
+		params.Set("language", o.Language)
+	}
+
+	params.Set("punctuate", strconv.FormatBool(o.Punctuate))
+	params.Set("diarize", strconv.FormatBool(o.Diarize))
+	params.Set("smart_format", strconv.FormatBool(o.SmartFormat))
+	if !streaming {
+		params.Set("utterances", strconv.FormatBool(o.Utterances))
+	}
+	if o.Numerals {
+		params.Set("numerals", "true")
+	}
+	if o.Profanity {
+		params.Set("profanity_filter", "true")
+	}
+
+	for _, redact := range o.Redact {
+		params.Add("redact", redact)
+	}
+	for _, keyword := range o.Keywords {
+		params.Add("keywords", keyword)
+	}
+	for _, keyterm := range o.Keyterms {
+		params.Add("keyterm", keyterm)
+	}
+
+	return params
+}
+
+// listenURL returns the prerecorded transcription endpoint.
+func (o Options) listenURL() (string, error) {
+	base := o.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+
+	u, err := url.Parse(strings.TrimRight(base, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid Deepgram base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("Deepgram base URL must use http or https, got %q", base)
+	}
+
+	u.Path += "/v1/listen"
+	return u.String(), nil
+}
+
+// newHTTPClient returns the client shared by every request, so connections
+// are reused instead of negotiated per chunk.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+	}
+}
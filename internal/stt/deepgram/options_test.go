@@ -0,0 +1,166 @@
+package deepgram
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestOptionsQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		streaming bool
+		want      string // Encoded, so keys are sorted
+	}{
+		{
+			name: "defaults",
+			want: "diarize=false&punctuate=false&smart_format=false&utterances=false",
+		},
+		{
+			name: "features",
+			opts: Options{
+				Model:       "nova-2",
+				Language:    "en-GB",
+				Diarize:     true,
+				Punctuate:   true,
+				Utterances:  true,
+				SmartFormat: true,
+				Numerals:    true,
+				Profanity:   true,
+			},
+			want: "diarize=true&language=en-GB&model=nova-2&numerals=true&profanity_filter=true&punctuate=true&smart_format=true&utterances=true",
+		},
+		{
+			name: "repeated parameters",
+			opts: Options{
+				Redact:   []string{"pci", "ssn"},
+				Keywords: []string{"Kubernetes:2", "Grafana"},
+				Keyterms: []string{"service mesh"},
+			},
+			want: "diarize=false&keyterm=service+mesh&keywords=Kubernetes%3A2&keywords=Grafana&punctuate=false&redact=pci&redact=ssn&smart_format=false&utterances=false",
+		},
+		{
+			name: "auto language detects it",
+			opts: Options{Language: LanguageAuto},
+			want: "detect_language=true&diarize=false&punctuate=false&smart_format=false&utterances=false",
+		},
+		{
+			name:      "auto language streams multilingual",
+			opts:      Options{Language: LanguageAuto},
+			streaming: true,
+			want:      "diarize=false&language=multi&punctuate=false&smart_format=false",
+		},
+		{
+			name:      "streaming drops utterances",
+			opts:      Options{Diarize: true, Utterances: true},
+			streaming: true,
+			want:      "diarize=true&punctuate=false&smart_format=false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.query(tt.streaming).Encode(); got != tt.want {
+				t.Errorf("query(%v) = %q, want %q", tt.streaming, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionsListenURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{"", "https://api.deepgram.com/v1/listen", false},
+		{"http://localhost:8080/", "http://localhost:8080/v1/listen", false},
+		{"https://deepgram.internal/proxy", "https://deepgram.internal/proxy/v1/listen", false},
+		{"wss://api.deepgram.com", "", true},
+		{"://", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Options{BaseURL: tt.baseURL}.listenURL()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("listenURL(%q) error = %v, wantErr %v", tt.baseURL, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("listenURL(%q) = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
+}
+
+func TestStreamURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		endpoint string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "audio format is fixed",
+			opts:     Options{Model: "nova-3", Diarize: true},
+			endpoint: "wss://api.deepgram.com/v1/listen",
+			want: map[string]string{
+				"model":           "nova-3",
+				"diarize":         "true",
+				"encoding":        "linear16",
+				"sample_rate":     "48000",
+				"channels":        "1",
+				"interim_results": "true",
+				"utterances":      "",
+			},
+		},
+		{
+			name:     "endpoint parameters override the options",
+			opts:     Options{Model: "nova-3"},
+			endpoint: "ws://localhost:8080/v1/listen?model=custom&encoding=opus",
+			want: map[string]string{
+				"model":    "custom",
+				"encoding": "linear16",
+			},
+		},
+		{
+			name:     "an empty endpoint uses Deepgram's",
+			endpoint: "",
+			want:     map[string]string{"encoding": "linear16"},
+		},
+		{
+			name:     "HTTP endpoints are rejected",
+			endpoint: "https://api.deepgram.com/v1/listen",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamer := NewDeepgramStreamer(tt.opts, tt.endpoint)
+			defer streamer.Close()
+
+			got, err := streamer.streamURL()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("streamURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			u, err := url.Parse(got)
+			if err != nil {
+				t.Fatalf("streamURL() = %q, not a URL: %v", got, err)
+			}
+			if tt.endpoint == "" && u.Host != "api.deepgram.com" {
+				t.Errorf("streamURL() host = %q, want api.deepgram.com", u.Host)
+			}
+			params := u.Query()
+			for key, want := range tt.want {
+				if got := params.Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package deepgram
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// DeepgramResponse is the prerecorded transcription response. Utterances
+// are only returned when requested with utterances=true.
+type DeepgramResponse struct {
+	Results struct {
+		Channels   []DeepgramChannel   `json:"channels"`
+		Utterances []DeepgramUtterance `json:"utterances"`
+	} `json:"results"`
+}
+
+type DeepgramChannel struct {
+	Alternatives []DeepgramAlternative `json:"alternatives"`
+}
+
+type DeepgramAlternative struct {
+	Transcript string         `json:"transcript"`
+	Confidence float64        `json:"confidence"`
+	Words      []DeepgramWord `json:"words"`
+}
+
+// DeepgramWord is a recognized word with its timing in seconds from the
+// start of the audio. Speaker is only set when diarizing.
+type DeepgramWord struct {
+	Word              string  `json:"word"`
+	PunctuatedWord    string  `json:"punctuated_word"`
+	Start             float64 `json:"start"`
+	End               float64 `json:"end"`
+	Confidence        float64 `json:"confidence"`
+	Speaker           *int    `json:"speaker"`
+	SpeakerConfidence float64 `json:"speaker_confidence"`
+}
+
+// DeepgramUtterance is a span of speech Deepgram segmented on pauses and
+// speaker changes.
+type DeepgramUtterance struct {
+	Start      float64        `json:"start"`
+	End        float64        `json:"end"`
+	Confidence float64        `json:"confidence"`
+	Channel    int            `json:"channel"`
+	Transcript string         `json:"transcript"`
+	Words      []DeepgramWord `json:"words"`
+	Speaker    int            `json:"speaker"`
+}
+
+// parseResponse converts a prerecorded response into utterances on the
+// chunk's timeline, preferring Deepgram's own utterance segmentation, then
+// speaker turns in the word list, then the whole transcript.
+//
+// A chunk only ever holds one Discord user's audio, so diarization labels
+// can't be matched to other users; they only split the speech into turns.
+func parseResponse(result *DeepgramResponse, chunk *audio.Chunk) []audio.Utterance {
+	at := func(seconds float64) time.Time {
+		return chunk.TimeAt(time.Duration(seconds * float64(time.Second)))
+	}
+
+	var speaker string
+	if len(chunk.Speakers) > 0 {
+		speaker = chunk.Speakers[0]
+	}
+
+	newUtterance := func(text string, start, end time.Time, confidence float64, words []DeepgramWord) audio.Utterance {
+		return audio.Utterance{
+			ID:         uuid.New(),
+			TSStart:    start,
+			TSEnd:      end,
+			UserID:     speaker,
+			UserTag:    speaker,
+			Text:       text,
+			Source:     "deepgram",
+			Confidence: confidence,
+			Words:      toWords(words, at),
+		}
+	}
+
+	var utterances []audio.Utterance
+
+	if len(result.Results.Utterances) > 0 {
+		for _, u := range result.Results.Utterances {
+			text := strings.TrimSpace(u.Transcript)
+			if text == "" {
+				continue
+			}
+			utterances = append(utterances, newUtterance(text, at(u.Start), at(u.End), u.Confidence, u.Words))
+		}
+		return utterances
+	}
+
+	// Other alternatives are competing hypotheses for the same speech
+	if len(result.Results.Channels) == 0 || len(result.Results.Channels[0].Alternatives) == 0 {
+		return nil
+	}
+	alternative := result.Results.Channels[0].Alternatives[0]
+
+	text := strings.TrimSpace(alternative.Transcript)
+	if text == "" {
+		return nil
+	}
+
+	if len(alternative.Words) == 0 {
+		return []audio.Utterance{newUtterance(text, chunk.Start, chunk.End, alternative.Confidence, nil)}
+	}
+
+	turns := speakerTurns(alternative.Words)
+	if len(turns) == 1 {
+		words := turns[0]
+		return []audio.Utterance{newUtterance(text, at(words[0].Start), at(words[len(words)-1].End), alternative.Confidence, words)}
+	}
+
+	for _, words := range turns {
+		utterances = append(utterances, newUtterance(
+			joinWords(words),
+			at(words[0].Start),
+			at(words[len(words)-1].End),
+			meanConfidence(words),
+			words,
+		))
+	}
+	return utterances
+}
+
+// speakerTurns splits words into runs by the same diarized speaker.
+func speakerTurns(words []DeepgramWord) [][]DeepgramWord {
+	var turns [][]DeepgramWord
+	start := 0
+	for i := 1; i <= len(words); i++ {
+		if i == len(words) || !sameSpeaker(words[i].Speaker, words[start].Speaker) {
+			turns = append(turns, words[start:i])
+			start = i
+		}
+	}
+	return turns
+}
+
+// sameSpeaker reports whether two words carry the same diarization label,
+// counting two unlabelled words as the same speaker.
+func sameSpeaker(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// toWords converts word timings with at, which maps seconds from the start
+// of the audio to capture time.
+func toWords(words []DeepgramWord, at func(seconds float64) time.Time) []audio.Word {
+	if len(words) == 0 {
+		return nil
+	}
+
+	converted := make([]audio.Word, 0, len(words))
+	for _, word := range words {
+		converted = append(converted, audio.Word{
+			Text:       word.text(),
+			Start:      at(word.Start),
+			End:        at(word.End),
+			Confidence: word.Confidence,
+			Speaker:    word.Speaker,
+		})
+	}
+	return converted
+}
+
+func joinWords(words []DeepgramWord) string {
+	texts := make([]string, 0, len(words))
+	for _, word := range words {
+		texts = append(texts, word.text())
+	}
+	return strings.Join(texts, " ")
+}
+
+func meanConfidence(words []DeepgramWord) float64 {
+	var total float64
+	for _, word := range words {
+		total += word.Confidence
+	}
+	return total / float64(len(words))
+}
+
+// text prefers the punctuated and formatted form of the word.
+func (w DeepgramWord) text() string {
+	if w.PunctuatedWord != "" {
+		return w.PunctuatedWord
+	}
+	return w.Word
+}
@@ -0,0 +1,193 @@
+package deepgram
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// describeUtterances summarises utterances as "text@start-end" in
+// milliseconds from base, joined with " | ".
+func describeUtterances(utterances []audio.Utterance, base time.Time) string {
+	parts := make([]string, len(utterances))
+	for i, utt := range utterances {
+		parts[i] = fmt.Sprintf("%s@%d-%d", utt.Text, utt.TSStart.Sub(base).Milliseconds(), utt.TSEnd.Sub(base).Milliseconds())
+	}
+	return strings.Join(parts, " | ")
+}
+
+func TestParseResponse(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	chunk := &audio.Chunk{
+		Start:    start,
+		End:      start.Add(5 * time.Second),
+		Speakers: []string{"alice"},
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "utterances are preferred",
+			body: `{"results": {
+				"channels": [{"alternatives": [{"transcript": "ignored", "confidence": 0.5}]}],
+				"utterances": [
+					{"start": 0.5, "end": 1.5, "confidence": 0.9, "transcript": "hello there"},
+					{"start": 2, "end": 2.5, "transcript": "  "},
+					{"start": 3, "end": 4, "confidence": 0.8, "transcript": "general kenobi"}
+				]}}`,
+			want: "hello there@500-1500 | general kenobi@3000-4000",
+		},
+		{
+			name: "single speaker narrows to the words",
+			body: `{"results": {"channels": [{"alternatives": [{
+				"transcript": "hello world", "confidence": 0.9,
+				"words": [
+					{"word": "hello", "start": 1, "end": 1.4, "confidence": 0.9},
+					{"word": "world", "start": 1.5, "end": 2, "confidence": 0.9}
+				]}]}]}}`,
+			want: "hello world@1000-2000",
+		},
+		{
+			name: "speaker changes split the transcript",
+			body: `{"results": {"channels": [{"alternatives": [{
+				"transcript": "yes no maybe", "confidence": 0.9,
+				"words": [
+					{"word": "yes", "punctuated_word": "Yes.", "start": 0, "end": 0.5, "confidence": 0.9, "speaker": 0},
+					{"word": "no", "punctuated_word": "No.", "start": 1, "end": 1.5, "confidence": 0.7, "speaker": 1},
+					{"word": "maybe", "start": 2, "end": 2.5, "confidence": 0.9, "speaker": 1}
+				]}]}]}}`,
+			want: "Yes.@0-500 | No. maybe@1000-2500",
+		},
+		{
+			name: "transcript without words spans the chunk",
+			body: `{"results": {"channels": [{"alternatives": [{"transcript": "hi", "confidence": 0.9}]}]}}`,
+			want: "hi@0-5000",
+		},
+		{
+			name: "empty transcript",
+			body: `{"results": {"channels": [{"alternatives": [{"transcript": " "}]}]}}`,
+			want: "",
+		},
+		{
+			name: "no channels",
+			body: `{"results": {}}`,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response DeepgramResponse
+			if err := json.Unmarshal([]byte(tt.body), &response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			utterances := parseResponse(&response, chunk)
+			if got := describeUtterances(utterances, start); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			for _, utt := range utterances {
+				if utt.UserID != "alice" || utt.Source != "deepgram" {
+					t.Errorf("utterance %q from %q via %q, want alice via deepgram", utt.Text, utt.UserID, utt.Source)
+				}
+			}
+		})
+	}
+}
+
+func TestParseResponseMapsOffsetsThroughAnchors(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	resumed := start.Add(30 * time.Second)
+
+	// One second of speech, a long pause, then another second
+	chunk := &audio.Chunk{
+		Start: start,
+		End:   resumed.Add(time.Second),
+		Anchors: []audio.TimeAnchor{
+			{Offset: 0, At: start},
+			{Offset: time.Second, At: resumed},
+		},
+	}
+
+	var response DeepgramResponse
+	body := `{"results": {"channels": [{"alternatives": [{
+		"transcript": "before after", "confidence": 0.9,
+		"words": [
+			{"word": "before", "start": 0.25, "end": 0.75, "confidence": 0.9},
+			{"word": "after", "start": 1.25, "end": 1.75, "confidence": 0.9}
+		]}]}]}}`
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	utterances := parseResponse(&response, chunk)
+	if len(utterances) != 1 {
+		t.Fatalf("got %d utterances, want 1", len(utterances))
+	}
+	utt := utterances[0]
+
+	if want := start.Add(250 * time.Millisecond); !utt.TSStart.Equal(want) {
+		t.Errorf("TSStart = %s, want %s", utt.TSStart, want)
+	}
+	if want := resumed.Add(750 * time.Millisecond); !utt.TSEnd.Equal(want) {
+		t.Errorf("TSEnd = %s, want %s", utt.TSEnd, want)
+	}
+	if len(utt.Words) != 2 || !utt.Words[1].Start.Equal(resumed.Add(250*time.Millisecond)) {
+		t.Errorf("words = %+v, want the second starting 250ms after the pause", utt.Words)
+	}
+}
+
+func TestParseResponseKeepsWordSpeakers(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	chunk := &audio.Chunk{Start: start, End: start.Add(2 * time.Second)}
+
+	var response DeepgramResponse
+	body := `{"results": {"channels": [{"alternatives": [{
+		"transcript": "yes yes", "confidence": 0.9,
+		"words": [
+			{"word": "yes", "start": 0, "end": 0.5, "confidence": 0.9, "speaker": 1},
+			{"word": "yes", "start": 1, "end": 1.5, "confidence": 0.9, "speaker": 1}
+		]}]}]}}`
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	utterances := parseResponse(&response, chunk)
+	if len(utterances) != 1 || len(utterances[0].Words) != 2 {
+		t.Fatalf("got %+v, want one utterance of two words", utterances)
+	}
+
+	// The label survives the transcript's JSONL encoding
+	encoded, err := json.Marshal(utterances[0])
+	if err != nil {
+		t.Fatalf("failed to encode utterance: %v", err)
+	}
+	var decoded audio.Utterance
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("failed to decode utterance: %v", err)
+	}
+	for i, word := range decoded.Words {
+		if word.Speaker == nil || *word.Speaker != 1 {
+			t.Errorf("word %d speaker = %v, want 1", i, word.Speaker)
+		}
+	}
+
+	// Without diarization the words carry no speaker at all
+	var plain DeepgramResponse
+	undiarized := strings.ReplaceAll(body, `, "speaker": 1`, "")
+	if err := json.Unmarshal([]byte(undiarized), &plain); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, word := range parseResponse(&plain, chunk)[0].Words {
+		if word.Speaker != nil {
+			t.Errorf("word %q speaker = %d, want none", word.Text, *word.Speaker)
+		}
+	}
+}
@@ -0,0 +1,495 @@
+package deepgram
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/audio"
+	"github.com/user/discord-notetaker/internal/stt"
+)
+
+// DefaultStreamURL is Deepgram's live transcription endpoint.
+const DefaultStreamURL = "wss://api.deepgram.com/v1/listen"
+
+const (
+	// Audio is streamed at the capture rate. The resampler works on whole
+	// buffers, so resampling 20ms frames one by one would click at every
+	// frame boundary.
+	streamSampleRate = audio.SampleRate
+
+	// streamBufferFrames bounds the audio queued while connecting or
+	// reconnecting: 10 seconds of 20ms frames
+	streamBufferFrames = 500
+
+	// Deepgram closes streams that receive no audio for 10 seconds, which
+	// happens whenever a speaker is quiet
+	keepAliveInterval = 5 * time.Second
+
+	// streamCloseTimeout bounds the wait for final results after CloseStream
+	streamCloseTimeout = 10 * time.Second
+
+	minReconnectDelay = 250 * time.Millisecond
+	maxReconnectDelay = 10 * time.Second
+)
+
+// DeepgramStreamer opens live transcription WebSockets, one per speaker.
+type DeepgramStreamer struct {
+	opts     Options
+	endpoint string
+	dialer   *websocket.Dialer
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// StreamResponse is a message received on a live transcription stream.
+// Start and Duration are seconds of audio since the connection opened.
+type StreamResponse struct {
+	Type     string  `json:"type"`
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+	IsFinal  bool    `json:"is_final"`
+	Channel  struct {
+		Alternatives []DeepgramAlternative `json:"alternatives"`
+	} `json:"channel"`
+}
+
+// NewDeepgramStreamer creates a streamer for endpoint, or DefaultStreamURL
+// when it is empty; tests can point it at a local server.
+func NewDeepgramStreamer(opts Options, endpoint string) *DeepgramStreamer {
+	if endpoint == "" {
+		endpoint = DefaultStreamURL
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &DeepgramStreamer{
+		opts:     opts,
+		endpoint: endpoint,
+		dialer:   &websocket.Dialer{HandshakeTimeout: 10 * time.Second},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// NewStream starts a stream that connects in the background.
+func (d *DeepgramStreamer) NewStream() (stt.Stream, error) {
+	streamURL, err := d.streamURL()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &deepgramStream{
+		streamer: d,
+		url:      streamURL,
+		frames:   make(chan streamFrame, streamBufferFrames),
+		results:  make(chan audio.Utterance, 16),
+		closing:  make(chan struct{}),
+	}
+	go s.run()
+
+	return s, nil
+}
+
+func (d *DeepgramStreamer) streamURL() (string, error) {
+	u, err := url.Parse(d.endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid Deepgram stream URL: %w", err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return "", fmt.Errorf("Deepgram stream URL must use ws or wss, got %q", d.endpoint)
+	}
+
+	params := d.opts.query(true)
+	for key, values := range u.Query() {
+		params[key] = values
+	}
+	params.Set("encoding", "linear16")
+	params.Set("sample_rate", strconv.Itoa(streamSampleRate))
+	params.Set("channels", "1")
+	params.Set("interim_results", "true")
+	u.RawQuery = params.Encode()
+
+	return u.String(), nil
+}
+
+// Close ends every stream without waiting for final results.
+func (d *DeepgramStreamer) Close() error {
+	d.cancel()
+	return nil
+}
+
+type streamFrame struct {
+	pcm       []int16
+	timestamp time.Time
+}
+
+// deepgramStream is one speaker's WebSocket. A single goroutine owns the
+// connection, writing audio and reconnecting when it drops; each connection
+// has its own reader.
+type deepgramStream struct {
+	streamer *DeepgramStreamer
+	url      string
+
+	frames    chan streamFrame
+	results   chan audio.Utterance
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	dropped int               // Frames Write refused
+	lost    []audio.LostChunk // Frames accepted but never sent
+	mutex   sync.Mutex
+}
+
+func (s *deepgramStream) Write(pcm []int16, timestamp time.Time) error {
+	select {
+	case <-s.closing:
+		return fmt.Errorf("stream closed")
+	default:
+	}
+
+	// The caller reuses its buffers
+	frame := streamFrame{
+		pcm:       append([]int16(nil), pcm...),
+		timestamp: timestamp,
+	}
+
+	select {
+	case s.frames <- frame:
+		return nil
+	default:
+		s.mutex.Lock()
+		s.dropped++
+		s.mutex.Unlock()
+		return fmt.Errorf("stream buffer full, dropping audio")
+	}
+}
+
+func (s *deepgramStream) Results() <-chan audio.Utterance {
+	return s.results
+}
+
+func (s *deepgramStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	return nil
+}
+
+func (s *deepgramStream) Lost() []audio.LostChunk {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lost := make([]audio.LostChunk, len(s.lost))
+	copy(lost, s.lost)
+	return lost
+}
+
+// addLost records a frame that never reached Deepgram.
+func (s *deepgramStream) addLost(frame streamFrame, reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lost = audio.AppendLost(s.lost, audio.LostChunk{
+		Start:  frame.timestamp,
+		End:    frame.timestamp.Add(samplesToDuration(len(frame.pcm))),
+		Reason: reason,
+	})
+}
+
+// dropBuffered records every frame still waiting to be sent as lost.
+func (s *deepgramStream) dropBuffered(reason string) {
+	for {
+		select {
+		case frame := <-s.frames:
+			s.addLost(frame, reason)
+		default:
+			return
+		}
+	}
+}
+
+// run connects, serves the connection until it ends and reconnects with
+// backoff until the stream is closed.
+func (s *deepgramStream) run() {
+	defer close(s.results)
+	defer s.dropBuffered("transcription stream ended before the audio was sent")
+	defer func() {
+		s.mutex.Lock()
+		dropped := s.dropped
+		s.mutex.Unlock()
+		if dropped > 0 {
+			log.Warn().Int("dropped_frames", dropped).Msg("Deepgram stream dropped audio")
+		}
+	}()
+
+	ctx := s.streamer.ctx
+	delay := minReconnectDelay
+
+	for {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Dur("retry_in", delay).
+				Msg("Failed to connect Deepgram stream")
+
+			select {
+			case <-time.After(delay):
+			case <-s.closing:
+				return
+			case <-ctx.Done():
+				return
+			}
+
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		}
+		delay = minReconnectDelay
+
+		if s.serve(ctx, conn) {
+			return
+		}
+		log.Warn().Msg("Deepgram stream disconnected, reconnecting")
+	}
+}
+
+func (s *deepgramStream) dial(ctx context.Context) (*websocket.Conn, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Token "+s.streamer.opts.APIKey)
+
+	conn, resp, err := s.streamer.dialer.DialContext(ctx, s.url, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("Deepgram stream handshake failed with status %d: %w", resp.StatusCode, err)
+		}
+		return nil, fmt.Errorf("Deepgram stream connection failed: %w", err)
+	}
+
+	log.Debug().Str("url", s.url).Msg("Connected Deepgram stream")
+	return conn, nil
+}
+
+// serve writes queued audio to conn until the stream is closed or the
+// connection fails. Returns true if the stream is finished.
+func (s *deepgramStream) serve(ctx context.Context, conn *websocket.Conn) bool {
+	defer conn.Close()
+
+	timeline := &streamTimeline{}
+	readDone := make(chan error, 1)
+	go func() {
+		readDone <- s.read(ctx, conn, timeline)
+	}()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case frame := <-s.frames:
+			if err := s.send(conn, timeline, frame); err != nil {
+				log.Warn().Err(err).Msg("Failed to send audio to Deepgram stream")
+				s.addLost(frame, "transcription stream disconnected")
+				conn.Close()
+				<-readDone
+				return false
+			}
+
+		case <-keepAlive.C:
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"KeepAlive"}`)); err != nil {
+				conn.Close()
+				<-readDone
+				return false
+			}
+
+		case err := <-readDone:
+			log.Warn().Err(err).Msg("Deepgram stream closed by server")
+			return false
+
+		case <-s.closing:
+			// Flush what is buffered, then ask for the final results; the
+			// server closes the connection once it has sent them
+			for flushed := false; !flushed; {
+				select {
+				case frame := <-s.frames:
+					if err := s.send(conn, timeline, frame); err != nil {
+						log.Warn().Err(err).Msg("Failed to flush audio to Deepgram stream")
+						s.addLost(frame, "transcription stream disconnected")
+						flushed = true
+					}
+				default:
+					flushed = true
+				}
+			}
+
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"CloseStream"}`)); err == nil {
+				select {
+				case <-readDone:
+					return true
+				case <-time.After(streamCloseTimeout):
+					log.Warn().Msg("Timed out waiting for final Deepgram stream results")
+				case <-ctx.Done():
+				}
+			}
+			conn.Close()
+			<-readDone
+			return true
+
+		case <-ctx.Done():
+			conn.Close()
+			<-readDone
+			return true
+		}
+	}
+}
+
+func (s *deepgramStream) send(conn *websocket.Conn, timeline *streamTimeline, frame streamFrame) error {
+	timeline.add(frame)
+
+	data := make([]byte, len(frame.pcm)*2)
+	for i, sample := range frame.pcm {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(sample))
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// read turns transcription results into utterances until the connection
+// ends.
+func (s *deepgramStream) read(ctx context.Context, conn *websocket.Conn, timeline *streamTimeline) error {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var response StreamResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			log.Debug().
+				Str("message", string(data)).
+				Msg("Failed to parse Deepgram stream message")
+			continue
+		}
+
+		// Metadata, SpeechStarted and UtteranceEnd carry no transcript
+		if response.Type != "Results" || len(response.Channel.Alternatives) == 0 {
+			continue
+		}
+
+		alternative := response.Channel.Alternatives[0]
+		text := strings.TrimSpace(alternative.Transcript)
+		if text == "" {
+			if response.IsFinal {
+				timeline.trim(response.Start + response.Duration)
+			}
+			continue
+		}
+
+		utt := audio.Utterance{
+			ID:         uuid.New(),
+			TSStart:    timeline.at(response.Start),
+			TSEnd:      timeline.at(response.Start + response.Duration),
+			Text:       text,
+			Source:     "deepgram",
+			Confidence: alternative.Confidence,
+			Interim:    !response.IsFinal,
+			Words:      toWords(alternative.Words, timeline.at),
+		}
+		if response.IsFinal {
+			timeline.trim(response.Start + response.Duration)
+		}
+
+		select {
+		case s.results <- utt:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// streamTimeline maps positions in the audio sent on one connection back to
+// capture time. Only speech is streamed, so every pause starts a new anchor.
+type streamTimeline struct {
+	sent    int       // Samples sent so far
+	next    time.Time // Expected timestamp of the next contiguous frame
+	anchors []streamAnchor
+	mutex   sync.Mutex
+}
+
+type streamAnchor struct {
+	offset int // Sample offset in the stream
+	at     time.Time
+}
+
+func (t *streamTimeline) add(frame streamFrame) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	drift := frame.timestamp.Sub(t.next)
+	if drift < 0 {
+		drift = -drift
+	}
+	if len(t.anchors) == 0 || drift > samplesToDuration(audio.FrameSize)/2 {
+		t.anchors = append(t.anchors, streamAnchor{offset: t.sent, at: frame.timestamp})
+	}
+
+	t.sent += len(frame.pcm)
+	t.next = frame.timestamp.Add(samplesToDuration(len(frame.pcm)))
+}
+
+// at returns the capture time of a position in seconds from the start of
+// the connection.
+func (t *streamTimeline) at(seconds float64) time.Time {
+	offset := int(seconds * streamSampleRate)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.anchors) == 0 {
+		return time.Now()
+	}
+
+	anchor := t.anchors[0]
+	for _, a := range t.anchors[1:] {
+		if a.offset > offset {
+			break
+		}
+		anchor = a
+	}
+	return anchor.at.Add(samplesToDuration(offset - anchor.offset))
+}
+
+// trim drops the anchors before a position in seconds once results up to
+// it are final; later results never start before it.
+func (t *streamTimeline) trim(seconds float64) {
+	offset := int(seconds * streamSampleRate)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Keep the anchor covering offset
+	keep := 0
+	for keep+1 < len(t.anchors) && t.anchors[keep+1].offset <= offset {
+		keep++
+	}
+	if keep > 0 {
+		t.anchors = append(t.anchors[:0], t.anchors[keep:]...)
+	}
+}
+
+func samplesToDuration(samples int) time.Duration {
+	return time.Duration(samples) * time.Second / streamSampleRate
+}
@@ -0,0 +1,338 @@
+package deepgram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// fakeServer is a local stand-in for Deepgram's live endpoint. Each
+// connection is handed to handle, which reads audio and replies.
+type fakeServer struct {
+	t       *testing.T
+	handle  func(conn *fakeConn)
+	server  *httptest.Server
+	conns   int
+	connMux sync.Mutex
+}
+
+// fakeConn is one connection to the fake server.
+type fakeConn struct {
+	*websocket.Conn
+	index int // Connections accepted before this one
+}
+
+func newFakeServer(t *testing.T, handle func(conn *fakeConn)) *fakeServer {
+	f := &fakeServer{t: t, handle: handle}
+	upgrader := websocket.Upgrader{}
+
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Token test-key" {
+			t.Errorf("Authorization = %q, want the API key", got)
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		f.connMux.Lock()
+		index := f.conns
+		f.conns++
+		f.connMux.Unlock()
+
+		f.handle(&fakeConn{Conn: conn, index: index})
+	}))
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakeServer) url() string {
+	return "ws" + strings.TrimPrefix(f.server.URL, "http")
+}
+
+// readAudio reads messages until samples of audio have arrived or a text
+// message other than KeepAlive is received, which it returns.
+func (c *fakeConn) readAudio(samples int) (int, string) {
+	received := 0
+	for received < samples {
+		kind, data, err := c.ReadMessage()
+		if err != nil {
+			return received, ""
+		}
+		if kind == websocket.BinaryMessage {
+			received += len(data) / 2
+			continue
+		}
+		if !strings.Contains(string(data), "KeepAlive") {
+			return received, string(data)
+		}
+	}
+	return received, ""
+}
+
+// sendResult sends a final result for start..start+duration seconds of the
+// connection's audio.
+func (c *fakeConn) sendResult(text string, start, duration float64, final bool) error {
+	response := map[string]any{
+		"type":     "Results",
+		"start":    start,
+		"duration": duration,
+		"is_final": final,
+		"channel": map[string]any{
+			"alternatives": []map[string]any{{
+				"transcript": text,
+				"confidence": 0.9,
+				"words": []map[string]any{
+					{"word": text, "start": start, "end": start + duration, "confidence": 0.9},
+				},
+			}},
+		},
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(websocket.TextMessage, data)
+}
+
+func newTestStreamer(url string) *DeepgramStreamer {
+	return NewDeepgramStreamer(Options{APIKey: "test-key"}, url)
+}
+
+// writeSpeech writes frames of speech captured from at onwards.
+func writeSpeech(t *testing.T, stream interface {
+	Write([]int16, time.Time) error
+}, at time.Time, frames int) {
+	t.Helper()
+	pcm := make([]int16, audio.FrameSize)
+	for i := 0; i < frames; i++ {
+		if err := stream.Write(pcm, at.Add(time.Duration(i)*20*time.Millisecond)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+}
+
+func collect(t *testing.T, results <-chan audio.Utterance) []audio.Utterance {
+	t.Helper()
+	var utterances []audio.Utterance
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case utt, ok := <-results:
+			if !ok {
+				return utterances
+			}
+			utterances = append(utterances, utt)
+		case <-timeout:
+			t.Fatal("timed out waiting for the results channel to close")
+		}
+	}
+}
+
+func TestStreamMapsResultsAcrossPauses(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	server := newFakeServer(t, func(conn *fakeConn) {
+		// One second of speech, a pause, then another second
+		if n, _ := conn.readAudio(2 * audio.SampleRate); n < 2*audio.SampleRate {
+			t.Errorf("received %d samples, want %d", n, 2*audio.SampleRate)
+			return
+		}
+		conn.sendResult("first", 0.2, 0.5, true)
+		conn.sendResult("second", 1.25, 0.5, true)
+
+		if _, msg := conn.readAudio(1); !strings.Contains(msg, "CloseStream") {
+			t.Errorf("got %q, want CloseStream", msg)
+		}
+	})
+
+	streamer := newTestStreamer(server.url())
+	defer streamer.Close()
+
+	stream, err := streamer.NewStream()
+	if err != nil {
+		t.Fatalf("NewStream failed: %v", err)
+	}
+	writeSpeech(t, stream, start, 50)
+	writeSpeech(t, stream, start.Add(5*time.Second), 50)
+	stream.Close()
+
+	utterances := collect(t, stream.Results())
+	if len(utterances) != 2 {
+		t.Fatalf("got %d utterances, want 2", len(utterances))
+	}
+
+	tests := []struct {
+		text  string
+		start time.Time
+	}{
+		{"first", start.Add(200 * time.Millisecond)},
+		// 0.25s into the audio sent after the pause
+		{"second", start.Add(5*time.Second + 250*time.Millisecond)},
+	}
+	for i, tt := range tests {
+		utt := utterances[i]
+		if utt.Text != tt.text || !utt.TSStart.Equal(tt.start) {
+			t.Errorf("utterance %d = %q at %s, want %q at %s", i, utt.Text, utt.TSStart, tt.text, tt.start)
+		}
+		if len(utt.Words) != 1 || !utt.Words[0].Start.Equal(tt.start) {
+			t.Errorf("utterance %d words = %+v, want one starting at %s", i, utt.Words, tt.start)
+		}
+	}
+}
+
+func TestStreamCloseFlushesAudioAndWaitsForFinalResults(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	const frames = 100
+
+	server := newFakeServer(t, func(conn *fakeConn) {
+		received, msg := conn.readAudio(frames * audio.FrameSize)
+		if msg == "" {
+			_, msg = conn.readAudio(1)
+		}
+		if received != frames*audio.FrameSize {
+			t.Errorf("received %d samples before CloseStream, want %d", received, frames*audio.FrameSize)
+		}
+		if !strings.Contains(msg, "CloseStream") {
+			t.Errorf("got %q, want CloseStream", msg)
+		}
+
+		// Final results only arrive after CloseStream
+		conn.sendResult("interim", 0, 1, false)
+		conn.sendResult("final words", 0, 2, true)
+	})
+
+	streamer := newTestStreamer(server.url())
+	defer streamer.Close()
+
+	stream, err := streamer.NewStream()
+	if err != nil {
+		t.Fatalf("NewStream failed: %v", err)
+	}
+
+	// Written before the connection is up, so all of it is buffered
+	writeSpeech(t, stream, start, frames)
+	stream.Close()
+
+	utterances := collect(t, stream.Results())
+	if len(utterances) != 2 {
+		t.Fatalf("got %d utterances, want 2", len(utterances))
+	}
+	if !utterances[0].Interim || utterances[1].Interim || utterances[1].Text != "final words" {
+		t.Errorf("got %+v, want an interim then the final result", utterances)
+	}
+}
+
+func TestStreamReconnectsWithItsOwnTimeline(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	dropped := make(chan struct{})
+
+	server := newFakeServer(t, func(conn *fakeConn) {
+		if conn.index == 0 {
+			// Drop the first connection after some audio
+			conn.readAudio(audio.FrameSize)
+			close(dropped)
+			return
+		}
+
+		// Offsets on the new connection count from its first sample
+		conn.readAudio(audio.FrameSize)
+		conn.sendResult("after reconnect", 0.5, 0.5, true)
+		conn.readAudio(1 << 30)
+	})
+
+	streamer := newTestStreamer(server.url())
+	defer streamer.Close()
+
+	stream, err := streamer.NewStream()
+	if err != nil {
+		t.Fatalf("NewStream failed: %v", err)
+	}
+	writeSpeech(t, stream, start, 1)
+
+	select {
+	case <-dropped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first connection never received audio")
+	}
+
+	// Give the stream time to notice the drop before sending more audio
+	time.Sleep(100 * time.Millisecond)
+	resumed := start.Add(30 * time.Second)
+	writeSpeech(t, stream, resumed, 50)
+
+	select {
+	case utt := <-stream.Results():
+		if want := resumed.Add(500 * time.Millisecond); !utt.TSStart.Equal(want) {
+			t.Errorf("TSStart = %s, want %s", utt.TSStart, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no result after reconnecting")
+	}
+
+	stream.Close()
+	collect(t, stream.Results())
+}
+
+func TestStreamTimeline(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	frame := func(at time.Time) streamFrame {
+		return streamFrame{pcm: make([]int16, audio.FrameSize), timestamp: at}
+	}
+
+	timeline := &streamTimeline{}
+	for i := 0; i < 50; i++ {
+		timeline.add(frame(start.Add(time.Duration(i) * 20 * time.Millisecond)))
+	}
+	// Small jitter stays on the same anchor; a pause starts a new one
+	timeline.add(frame(start.Add(1*time.Second + 3*time.Millisecond)))
+	for i := 0; i < 50; i++ {
+		timeline.add(frame(start.Add(10*time.Second + time.Duration(i)*20*time.Millisecond)))
+	}
+
+	if len(timeline.anchors) != 2 {
+		t.Fatalf("got %d anchors, want 2", len(timeline.anchors))
+	}
+
+	tests := []struct {
+		seconds float64
+		want    time.Time
+	}{
+		{0, start},
+		{0.5, start.Add(500 * time.Millisecond)},
+		{1.02, start.Add(10 * time.Second)},
+		{1.52, start.Add(10*time.Second + 500*time.Millisecond)},
+	}
+	for _, tt := range tests {
+		if got := timeline.at(tt.seconds); !got.Equal(tt.want) {
+			t.Errorf("at(%v) = %s, want %s", tt.seconds, got, tt.want)
+		}
+	}
+
+	// Results before the second run are final, so its anchor is all that's
+	// needed from now on
+	timeline.trim(1.1)
+	if len(timeline.anchors) != 1 {
+		t.Fatalf("got %d anchors after trim, want 1", len(timeline.anchors))
+	}
+	if got, want := timeline.at(1.52), start.Add(10*time.Second+500*time.Millisecond); !got.Equal(want) {
+		t.Errorf("at(1.52) after trim = %s, want %s", got, want)
+	}
+
+	// Trimming inside the only anchor keeps it
+	timeline.trim(5)
+	if len(timeline.anchors) != 1 {
+		t.Errorf("got %d anchors, want the covering anchor kept", len(timeline.anchors))
+	}
+}
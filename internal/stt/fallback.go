@@ -0,0 +1,155 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// fallbackCooldown is how long a backend that failed transiently is skipped,
+// so every chunk doesn't wait out the same timeout before falling back.
+const fallbackCooldown = 30 * time.Second
+
+// FallbackBackend is one backend in a fallback chain.
+type FallbackBackend struct {
+	Name        string
+	Transcriber Transcriber
+}
+
+// FallbackTranscriber tries backends in order until one transcribes the
+// chunk. Each backend sets Utterance.Source, so the transcript shows which
+// one produced the text.
+type FallbackTranscriber struct {
+	backends []FallbackBackend
+	breakers []*circuitBreaker
+	rate     int // Common preferred rate, or 0 if the backends differ
+
+	// Resamplers to each backend's preferred rate, keyed by from and to rate
+	resamplers   map[[2]int]*audio.Resampler
+	resamplerMux sync.Mutex
+}
+
+// NewFallbackTranscriber creates a chain from backends in priority order.
+func NewFallbackTranscriber(backends []FallbackBackend) (*FallbackTranscriber, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("fallback chain needs at least one backend")
+	}
+
+	f := &FallbackTranscriber{
+		backends:   backends,
+		rate:       backends[0].Transcriber.SampleRate(),
+		resamplers: make(map[[2]int]*audio.Resampler),
+	}
+	for _, backend := range backends {
+		f.breakers = append(f.breakers, newCircuitBreaker(backend.Name, 1, fallbackCooldown))
+		if backend.Transcriber.SampleRate() != f.rate {
+			f.rate = 0
+		}
+	}
+
+	return f, nil
+}
+
+func (f *FallbackTranscriber) Transcribe(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+	var errs []error
+
+	// Backends cooling down after a failure are tried last rather than
+	// skipped, in case everything else is down too
+	order := make([]int, 0, len(f.backends))
+	var cooling []int
+	for i := range f.backends {
+		if f.breakers[i].isOpen() {
+			cooling = append(cooling, i)
+		} else {
+			order = append(order, i)
+		}
+	}
+	order = append(order, cooling...)
+
+	for _, i := range order {
+		backend := f.backends[i]
+
+		backendChunk, err := f.resample(chunk, backend.Transcriber.SampleRate())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", backend.Name, err))
+			continue
+		}
+
+		utterances, err := backend.Transcriber.Transcribe(ctx, backendChunk)
+		if err == nil {
+			f.breakers[i].success()
+			if i > 0 {
+				log.Info().
+					Str("chunk_id", chunk.ID.String()).
+					Str("backend", backend.Name).
+					Msg("Chunk transcribed by fallback backend")
+			}
+			return utterances, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		// Quota and auth failures won't clear up within a meeting either,
+		// so any failure sends chunks to the next backend for a while
+		f.breakers[i].failure()
+		errs = append(errs, fmt.Errorf("%s: %w", backend.Name, err))
+
+		log.Warn().
+			Err(err).
+			Str("chunk_id", chunk.ID.String()).
+			Str("backend", backend.Name).
+			Msg("Transcription backend failed, trying next")
+	}
+
+	return nil, fmt.Errorf("all transcription backends failed: %w", errors.Join(errs...))
+}
+
+// resample converts a chunk to a backend's preferred rate when the chain's
+// backends disagree, so the pool can't do it for them.
+func (f *FallbackTranscriber) resample(chunk *audio.Chunk, target int) (*audio.Chunk, error) {
+	from := chunk.SampleRate
+	if from == 0 {
+		from = audio.SampleRate
+	}
+	if target == 0 || target == from {
+		return chunk, nil
+	}
+
+	key := [2]int{from, target}
+	f.resamplerMux.Lock()
+	resampler, ok := f.resamplers[key]
+	if !ok {
+		var err error
+		resampler, err = audio.NewResampler(from, target)
+		if err != nil {
+			f.resamplerMux.Unlock()
+			return nil, err
+		}
+		f.resamplers[key] = resampler
+	}
+	f.resamplerMux.Unlock()
+
+	return audio.ResampleChunk(chunk, resampler), nil
+}
+
+// SampleRate is the backends' common preferred rate, or 0 if they differ
+// and chunks are resampled per backend.
+func (f *FallbackTranscriber) SampleRate() int {
+	return f.rate
+}
+
+func (f *FallbackTranscriber) Close() error {
+	var errs []error
+	for _, backend := range f.backends {
+		if err := backend.Transcriber.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", backend.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
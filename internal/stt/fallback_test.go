@@ -0,0 +1,128 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// scriptedBackend fails with err while it is set and counts its calls.
+type scriptedBackend struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (s *scriptedBackend) backend() FallbackBackend {
+	return FallbackBackend{Name: s.name, Transcriber: funcTranscriber(func(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+		s.calls++
+		if s.err != nil {
+			return nil, s.err
+		}
+		return []audio.Utterance{{Text: "hello", Source: s.name}}, nil
+	})}
+}
+
+// sources lists which backend produced each utterance.
+func sources(utterances []audio.Utterance) string {
+	names := make([]string, len(utterances))
+	for i, utt := range utterances {
+		names[i] = utt.Source
+	}
+	return strings.Join(names, ",")
+}
+
+func TestFallbackTranscriber(t *testing.T) {
+	chunk := &audio.Chunk{PCM: make([]int16, audio.FrameSize)}
+	primary := &scriptedBackend{name: "deepgram", err: errUnavailable}
+	secondary := &scriptedBackend{name: "whisper"}
+
+	f, err := NewFallbackTranscriber([]FallbackBackend{primary.backend(), secondary.backend()})
+	if err != nil {
+		t.Fatalf("NewFallbackTranscriber failed: %v", err)
+	}
+
+	utterances, err := f.Transcribe(context.Background(), chunk)
+	if err != nil || sources(utterances) != "whisper" {
+		t.Fatalf("Transcribe() = %q, %v, want whisper's transcript", sources(utterances), err)
+	}
+
+	// The primary is cooling down, so the next chunk skips it
+	if utterances, err := f.Transcribe(context.Background(), chunk); err != nil || sources(utterances) != "whisper" {
+		t.Fatalf("second Transcribe() = %q, %v, want whisper's transcript", sources(utterances), err)
+	}
+	if primary.calls != 1 || secondary.calls != 2 {
+		t.Errorf("backends called %d and %d times, want 1 and 2", primary.calls, secondary.calls)
+	}
+
+	// Once the secondary fails too, the cooling primary is still tried last
+	secondary.err = errors.New("model not loaded")
+	primary.err = nil
+	if utterances, err := f.Transcribe(context.Background(), chunk); err != nil || sources(utterances) != "deepgram" {
+		t.Fatalf("third Transcribe() = %q, %v, want deepgram's transcript", sources(utterances), err)
+	}
+	if primary.calls != 2 || secondary.calls != 3 {
+		t.Errorf("backends called %d and %d times, want 2 and 3", primary.calls, secondary.calls)
+	}
+}
+
+func TestFallbackTranscriberAllFail(t *testing.T) {
+	rejected := &APIError{Backend: "deepgram", StatusCode: http.StatusUnauthorized}
+	broken := errors.New("model not loaded")
+	primary := &scriptedBackend{name: "deepgram", err: rejected}
+	secondary := &scriptedBackend{name: "whisper", err: broken}
+
+	f, err := NewFallbackTranscriber([]FallbackBackend{primary.backend(), secondary.backend()})
+	if err != nil {
+		t.Fatalf("NewFallbackTranscriber failed: %v", err)
+	}
+
+	_, err = f.Transcribe(context.Background(), &audio.Chunk{PCM: make([]int16, audio.FrameSize)})
+	if err == nil {
+		t.Fatal("Transcribe() succeeded with every backend failing")
+	}
+
+	// Each backend's error is kept, so the pool can still classify them
+	if !errors.Is(err, rejected) || !errors.Is(err, broken) {
+		t.Errorf("error %q doesn't wrap both backends' errors", err)
+	}
+	if IsRetryable(err) {
+		t.Errorf("IsRetryable(%q) = true, want false with no transient failure", err)
+	}
+	for _, name := range []string{"deepgram", "whisper"} {
+		if !strings.Contains(err.Error(), name+":") {
+			t.Errorf("error %q doesn't name %s", err, name)
+		}
+	}
+}
+
+func TestFallbackTranscriberStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	secondary := &scriptedBackend{name: "whisper"}
+	primary := FallbackBackend{Name: "deepgram", Transcriber: funcTranscriber(func(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+		cancel()
+		return nil, ctx.Err()
+	})}
+
+	f, err := NewFallbackTranscriber([]FallbackBackend{primary, secondary.backend()})
+	if err != nil {
+		t.Fatalf("NewFallbackTranscriber failed: %v", err)
+	}
+
+	if _, err := f.Transcribe(ctx, &audio.Chunk{PCM: make([]int16, audio.FrameSize)}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Transcribe() error = %v, want context.Canceled", err)
+	}
+	if secondary.calls != 0 {
+		t.Errorf("secondary called %d times after shutdown, want 0", secondary.calls)
+	}
+}
+
+func TestNewFallbackTranscriberNeedsABackend(t *testing.T) {
+	if _, err := NewFallbackTranscriber(nil); err == nil {
+		t.Error("NewFallbackTranscriber(nil) succeeded, want an error")
+	}
+}
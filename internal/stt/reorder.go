@@ -0,0 +1,145 @@
+package stt
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// DefaultReorderWindow is how long results are held back waiting for an
+// earlier chunk; long enough to cover a few retries.
+const DefaultReorderWindow = 10 * time.Second
+
+// reorderer puts utterances from concurrent workers back into start time
+// order. Each result is held until every chunk still being transcribed
+// starts after it, so nothing earlier can turn up, but never for longer
+// than the window: a chunk stuck in retries doesn't stall the others, its
+// results just arrive late.
+type reorderer struct {
+	window   time.Duration
+	inflight map[uuid.UUID]time.Time // Chunk ID -> start, for chunks queued or being transcribed
+	held     []heldUtterance         // Ordered by start time
+	signal   chan struct{}           // Wakes the releaser when results arrive
+	mutex    sync.Mutex
+}
+
+type heldUtterance struct {
+	utterance audio.Utterance
+	arrived   time.Time
+}
+
+func newReorderer(window time.Duration) *reorderer {
+	return &reorderer{
+		window:   window,
+		inflight: make(map[uuid.UUID]time.Time),
+		signal:   make(chan struct{}, 1),
+	}
+}
+
+// track registers a chunk before it is queued, so results that start after
+// it are held back until it is done.
+func (r *reorderer) track(chunk *audio.Chunk) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.inflight[chunk.ID] = chunk.Start
+}
+
+// forget drops a chunk that won't produce results, such as one that failed.
+func (r *reorderer) forget(chunk *audio.Chunk) {
+	r.mutex.Lock()
+	delete(r.inflight, chunk.ID)
+	r.mutex.Unlock()
+
+	r.wake()
+}
+
+// done records a chunk's results and stops waiting on it.
+func (r *reorderer) done(chunk *audio.Chunk, utterances []audio.Utterance) {
+	r.mutex.Lock()
+	delete(r.inflight, chunk.ID)
+
+	now := time.Now()
+	for _, utt := range utterances {
+		// After any held utterance that starts at the same time, so ties keep
+		// their arrival order
+		i := sort.Search(len(r.held), func(i int) bool {
+			return r.held[i].utterance.TSStart.After(utt.TSStart)
+		})
+		r.held = append(r.held, heldUtterance{})
+		copy(r.held[i+1:], r.held[i:])
+		r.held[i] = heldUtterance{utterance: utt, arrived: now}
+	}
+	r.mutex.Unlock()
+
+	r.wake()
+}
+
+func (r *reorderer) wake() {
+	select {
+	case r.signal <- struct{}{}:
+	default:
+	}
+}
+
+// ready returns the utterances that can be released now, in order, and how
+// long until the oldest remaining one is due anyway (zero if none are held).
+func (r *reorderer) ready(now time.Time) ([]audio.Utterance, time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Everything up to the last utterance held for the whole window goes
+	release := 0
+	for i, held := range r.held {
+		if now.Sub(held.arrived) >= r.window {
+			release = i + 1
+		}
+	}
+
+	// Then anything that starts before every chunk still in flight
+	var watermark time.Time
+	for _, start := range r.inflight {
+		if watermark.IsZero() || start.Before(watermark) {
+			watermark = start
+		}
+	}
+	for release < len(r.held) && (len(r.inflight) == 0 || r.held[release].utterance.TSStart.Before(watermark)) {
+		release++
+	}
+
+	var batch []audio.Utterance
+	if release > 0 {
+		batch = make([]audio.Utterance, release)
+		for i := range batch {
+			batch[i] = r.held[i].utterance
+		}
+		r.held = r.held[release:]
+	}
+
+	var next time.Duration
+	for i, held := range r.held {
+		if due := held.arrived.Add(r.window).Sub(now); i == 0 || due < next {
+			next = due
+		}
+	}
+	if len(r.held) > 0 && next < time.Millisecond {
+		next = time.Millisecond
+	}
+
+	return batch, next
+}
+
+// flush returns every held utterance in order.
+func (r *reorderer) flush() []audio.Utterance {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	batch := make([]audio.Utterance, len(r.held))
+	for i, held := range r.held {
+		batch[i] = held.utterance
+	}
+	r.held = nil
+	return batch
+}
@@ -0,0 +1,121 @@
+package stt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+func texts(utterances []audio.Utterance) string {
+	parts := make([]string, len(utterances))
+	for i, utt := range utterances {
+		parts[i] = utt.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestReorderer(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	chunks := make([]*audio.Chunk, 3)
+	for i := range chunks {
+		chunks[i] = &audio.Chunk{ID: uuid.New(), Start: start.Add(time.Duration(i) * 10 * time.Second)}
+	}
+
+	done := func(chunk int, text string) func(r *reorderer) {
+		return func(r *reorderer) {
+			r.done(chunks[chunk], []audio.Utterance{{Text: text, TSStart: chunks[chunk].Start.Add(time.Second)}})
+		}
+	}
+	forget := func(chunk int) func(r *reorderer) {
+		return func(r *reorderer) { r.forget(chunks[chunk]) }
+	}
+	wait := func(r *reorderer) {}
+
+	type step struct {
+		action  func(r *reorderer)
+		after   time.Duration // When ready is called
+		release string        // Utterances released by the step
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "in order results pass straight through",
+			steps: []step{
+				{done(0, "a"), 0, "a"},
+				{done(1, "b"), 0, "b"},
+				{done(2, "c"), 0, "c"},
+			},
+		},
+		{
+			name: "later results wait for an earlier chunk",
+			steps: []step{
+				{done(2, "c"), 0, ""},
+				{done(1, "b"), 0, ""},
+				{done(0, "a"), 0, "a b c"},
+			},
+		},
+		{
+			name: "a failed chunk stops holding others back",
+			steps: []step{
+				{done(1, "b"), 0, ""},
+				{done(2, "c"), 0, ""},
+				{forget(0), 0, "b c"},
+			},
+		},
+		{
+			name: "results held for the whole window are released anyway",
+			steps: []step{
+				{done(2, "c"), 0, ""},
+				{done(1, "b"), 0, ""},
+				{wait, 5 * time.Second, ""},
+				{wait, 11 * time.Second, "b c"},
+				// The stuck chunk's results arrive late
+				{done(0, "a"), 11 * time.Second, "a"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReorderer(10 * time.Second)
+			for _, chunk := range chunks {
+				r.track(chunk)
+			}
+
+			now := time.Now()
+			for i, s := range tt.steps {
+				s.action(r)
+				batch, _ := r.ready(now.Add(s.after))
+				if got := texts(batch); got != s.release {
+					t.Errorf("step %d released %q, want %q", i, got, s.release)
+				}
+			}
+		})
+	}
+}
+
+func TestReordererNextDue(t *testing.T) {
+	r := newReorderer(10 * time.Second)
+	chunk := &audio.Chunk{ID: uuid.New(), Start: time.Now()}
+	later := &audio.Chunk{ID: uuid.New(), Start: chunk.Start.Add(time.Minute)}
+	r.track(chunk)
+	r.track(later)
+
+	if _, next := r.ready(time.Now()); next != 0 {
+		t.Errorf("next = %s with nothing held, want 0", next)
+	}
+
+	r.done(later, []audio.Utterance{{Text: "held", TSStart: later.Start}})
+	_, next := r.ready(time.Now())
+	if next <= 9*time.Second || next > 10*time.Second {
+		t.Errorf("next = %s, want just under the window", next)
+	}
+
+	if got := texts(r.flush()); got != "held" {
+		t.Errorf("flush() = %q, want the held utterance", got)
+	}
+}
@@ -0,0 +1,125 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is an unsuccessful HTTP response from an STT backend.
+type APIError struct {
+	Backend    string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // From the Retry-After header, zero if absent
+}
+
+// NewAPIError describes a failed response whose body has been read.
+func NewAPIError(backend string, resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		Backend:    backend,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error %d: %s", e.Backend, e.StatusCode, e.Body)
+}
+
+// parseRetryAfter reads a Retry-After header in either delay-seconds or
+// HTTP-date form.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// IsRetryable reports whether a transcription error is likely transient:
+// rate limiting, server errors, timeouts and dropped connections. Anything
+// else, such as a rejected API key or an unparseable response, would fail
+// the same way again.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusRequestTimeout ||
+			apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= 500
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// Timeouts, refused and reset connections, DNS failures
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfter returns the delay the backend asked for, if any.
+func retryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// RetryPolicy controls how failed chunks are retried and when the circuit
+// breaker stops dispatching to a failing backend.
+type RetryPolicy struct {
+	MaxRetries       int           // Retries after the first attempt
+	BaseDelay        time.Duration // Backoff before the first retry
+	MaxDelay         time.Duration // Backoff ceiling
+	BreakerThreshold int           // Consecutive transient failures that open the breaker
+	BreakerCooldown  time.Duration // How long the breaker stays open before a trial request
+}
+
+// DefaultRetryPolicy rides out a backend outage of about half a minute.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:       3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         30 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// backoff returns the delay before retry number attempt (from zero):
+// exponential with jitter so workers don't retry in lockstep, and never
+// shorter than what the backend asked for.
+func (p RetryPolicy) backoff(attempt int, requested time.Duration) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+
+	// Anywhere between half and the full delay
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if requested > delay {
+		return requested
+	}
+	return delay
+}
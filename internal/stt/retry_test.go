@@ -0,0 +1,148 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt   int
+		requested time.Duration
+		min, max  time.Duration
+	}{
+		{0, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 0, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 0, 400 * time.Millisecond, 800 * time.Millisecond},
+		// Capped at MaxDelay, including once the shift overflows
+		{4, 0, 500 * time.Millisecond, time.Second},
+		{70, 0, 500 * time.Millisecond, time.Second},
+		// Retry-After wins when it is longer, even past MaxDelay
+		{0, 5 * time.Second, 5 * time.Second, 5 * time.Second},
+		{3, 10 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d requested %s", tt.attempt, tt.requested), func(t *testing.T) {
+			seen := make(map[time.Duration]bool)
+			for i := 0; i < 200; i++ {
+				delay := policy.backoff(tt.attempt, tt.requested)
+				if delay < tt.min || delay > tt.max {
+					t.Fatalf("backoff = %s, want between %s and %s", delay, tt.min, tt.max)
+				}
+				seen[delay] = true
+			}
+			if tt.min != tt.max && len(seen) < 2 {
+				t.Errorf("backoff always %v, want jitter", seen)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limited", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", fmt.Errorf("failed to transcribe: %w", errUnavailable), true},
+		{"request timeout", &APIError{StatusCode: http.StatusRequestTimeout}, true},
+		{"bad key", &APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"bad request", &APIError{StatusCode: http.StatusBadRequest}, false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"cut off", io.ErrUnexpectedEOF, true},
+		{"unparseable response", errors.New("failed to parse response"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("3"); got != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %s, want 3s", got)
+	}
+	if got := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); got < 58*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(date a minute away) = %s, want about a minute", got)
+	}
+	for _, value := range []string{"", "0", "-1", "soon", "Mon, 01 Jan 2001 00:00:00 GMT"} {
+		if got := parseRetryAfter(value); got != 0 {
+			t.Errorf("parseRetryAfter(%q) = %s, want 0", value, got)
+		}
+	}
+}
+
+func TestTranscribeRetries(t *testing.T) {
+	fast := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	rejected := &APIError{Backend: "test", StatusCode: http.StatusUnauthorized}
+
+	tests := []struct {
+		name      string
+		errs      []error // Returned by successive calls, then success
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", nil, 1, nil},
+		{"transient failures are retried", []error{errUnavailable, errUnavailable}, 3, nil},
+		{"gives up after MaxRetries", []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable, errUnavailable}, 4, errUnavailable},
+		{"non-retryable errors stop at once", []error{rejected, errUnavailable}, 1, rejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			pool := NewTranscriberPool(funcTranscriber(func(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return nil, tt.errs[calls-1]
+				}
+				return []audio.Utterance{{Text: "hello"}}, nil
+			}), 1, fast)
+
+			_, err := pool.transcribe(context.Background(), &audio.Chunk{PCM: make([]int16, audio.FrameSize)}, nil)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("transcribe() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("backend called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTranscribeCancelledDuringBackoff(t *testing.T) {
+	calls := 0
+	pool := NewTranscriberPool(funcTranscriber(func(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+		calls++
+		return nil, &APIError{Backend: "test", StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}
+	}), 1, RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	began := time.Now()
+	_, err := pool.transcribe(ctx, &audio.Chunk{PCM: make([]int16, audio.FrameSize)}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("transcribe() error = %v, want the context's", err)
+	}
+	if waited := time.Since(began); waited > time.Second {
+		t.Errorf("transcribe() returned after %s, want it to stop waiting when cancelled", waited)
+	}
+	if calls != 1 {
+		t.Errorf("backend called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,495 @@
+package stt
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+const (
+	// minRequestCost is the least a request counts for in fair queuing, so
+	// empty or tiny chunks still take their turn.
+	minRequestCost = 0.1
+
+	// streamWaitFrames bounds the audio a stream buffers while it waits for
+	// a slot: 10 seconds of 20ms frames
+	streamWaitFrames = 500
+
+	// streamCloseWait is how long a stream closed before it got a slot
+	// keeps waiting for one to transcribe what it buffered
+	streamCloseWait = 10 * time.Second
+)
+
+// Scheduler shares one backend between every session in the bot. It caps
+// how many requests are in flight at once, and when sessions are waiting
+// for a slot it hands them out by start-time fair queuing: each flow (one
+// per guild) gets a share of the backend in proportion to its weight,
+// measured in seconds of audio, so a busy meeting can't starve a quiet one.
+// Streams are shared the same way but from a budget of their own, since
+// each holds its slot for as long as its speaker is in the meeting.
+type Scheduler struct {
+	transcriber Transcriber
+	chunks      fairQueue // Chunk requests
+	streams     fairQueue // Open streams, charged by the audio they send
+}
+
+// fairQueue hands out a fixed number of slots by start-time fair queuing.
+type fairQueue struct {
+	limit   int
+	active  int              // Slots held
+	virtual float64          // Start tag of the latest request granted a slot
+	flows   map[string]*flow // Keyed by guild
+	waiting requestQueue     // Ordered by start tag
+	seq     uint64           // Requests queued so far
+	mutex   sync.Mutex
+}
+
+// flow is one guild's share of the scheduler.
+type flow struct {
+	finish float64 // Finish tag of the flow's latest request
+}
+
+type request struct {
+	flow    *flow
+	start   float64
+	finish  float64
+	seq     uint64 // Arrival order, breaks ties between equal start tags
+	granted bool
+	ready   chan struct{}
+	index   int // Position in the heap, -1 once removed
+}
+
+// NewScheduler shares transcriber between sessions, with at most limit
+// requests and streamLimit streams in flight.
+func NewScheduler(transcriber Transcriber, limit, streamLimit int) *Scheduler {
+	return &Scheduler{
+		transcriber: transcriber,
+		chunks:      newFairQueue(limit),
+		streams:     newFairQueue(streamLimit),
+	}
+}
+
+func newFairQueue(limit int) fairQueue {
+	if limit <= 0 {
+		limit = 1
+	}
+	return fairQueue{limit: limit, flows: make(map[string]*flow)}
+}
+
+// Session returns a Transcriber that sends a session's chunks through the
+// scheduler as part of key's flow. A weight of 2 gets twice the share of a
+// weight of 1 when sessions compete; zero or less counts as 1.
+func (s *Scheduler) Session(key string, weight float64) *ScheduledTranscriber {
+	if weight <= 0 {
+		weight = 1
+	}
+	return &ScheduledTranscriber{scheduler: s, key: key, weight: weight}
+}
+
+// acquire waits for a slot for a request costing cost seconds of audio.
+func (q *fairQueue) acquire(ctx context.Context, key string, weight, cost float64) error {
+	q.mutex.Lock()
+
+	f := q.flowLocked(key)
+
+	// A flow that was idle starts from the current virtual time rather
+	// than spending credit saved up while it had nothing to send
+	start := max(q.virtual, f.finish)
+	f.finish = start + max(cost, minRequestCost)/weight
+
+	if q.active < q.limit && q.waiting.Len() == 0 {
+		q.active++
+		q.virtual = max(q.virtual, start)
+		q.mutex.Unlock()
+		return nil
+	}
+
+	q.seq++
+	req := &request{flow: f, start: start, finish: f.finish, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiting, req)
+	q.mutex.Unlock()
+
+	select {
+	case <-req.ready:
+		return nil
+	case <-ctx.Done():
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		if req.granted {
+			// Granted just as the context ended; pass the slot on
+			q.active--
+			q.grantLocked()
+		} else {
+			heap.Remove(&q.waiting, req.index)
+			q.refundLocked(req)
+		}
+		return ctx.Err()
+	}
+}
+
+func (q *fairQueue) flowLocked(key string) *flow {
+	f, ok := q.flows[key]
+	if !ok {
+		f = &flow{}
+		q.flows[key] = f
+	}
+	return f
+}
+
+// charge adds audio sent while holding a slot to key's flow, for slots
+// whose cost isn't known when they are granted.
+func (q *fairQueue) charge(key string, weight, cost float64) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.flowLocked(key).finish += cost / weight
+}
+
+// refundLocked takes a cancelled request's share back off its flow, so the
+// flow isn't pushed back for audio it never sent. The flow's later requests
+// move forward into the time the cancelled one would have used.
+func (q *fairQueue) refundLocked(req *request) {
+	share := req.finish - req.start
+	req.flow.finish -= share
+	for _, other := range q.waiting {
+		if other.flow == req.flow && other.start > req.start {
+			other.start -= share
+			other.finish -= share
+		}
+	}
+	heap.Init(&q.waiting)
+}
+
+// release frees a slot for the next waiting request.
+func (q *fairQueue) release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.active--
+	q.grantLocked()
+}
+
+// grantLocked hands free slots to the waiting requests with the lowest
+// start tags. Virtual time never moves back, even when a refund has moved
+// a waiting request's tag behind it.
+func (q *fairQueue) grantLocked() {
+	for q.active < q.limit && q.waiting.Len() > 0 {
+		req := heap.Pop(&q.waiting).(*request)
+		req.granted = true
+		q.active++
+		q.virtual = max(q.virtual, req.start)
+		close(req.ready)
+	}
+}
+
+// Waiting returns how many chunk requests are queued for a slot.
+func (s *Scheduler) Waiting() int {
+	return s.chunks.queued()
+}
+
+// queued returns how many requests are waiting for a slot.
+func (q *fairQueue) queued() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.waiting.Len()
+}
+
+// Close closes the shared backend.
+func (s *Scheduler) Close() error {
+	return s.transcriber.Close()
+}
+
+// ScheduledTranscriber is one session's view of a Scheduler.
+type ScheduledTranscriber struct {
+	scheduler *Scheduler
+	key       string
+	weight    float64
+}
+
+func (t *ScheduledTranscriber) Transcribe(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+	cost := chunkDuration(chunk).Seconds()
+
+	queued := time.Now()
+	if err := t.scheduler.chunks.acquire(ctx, t.key, t.weight, cost); err != nil {
+		return nil, err
+	}
+	defer t.scheduler.chunks.release()
+
+	if wait := time.Since(queued); wait > time.Second {
+		log.Debug().
+			Str("flow", t.key).
+			Str("chunk_id", chunk.ID.String()).
+			Dur("wait", wait).
+			Msg("Chunk waited for a shared STT slot")
+	}
+
+	return t.scheduler.transcriber.Transcribe(ctx, chunk)
+}
+
+func (t *ScheduledTranscriber) SampleRate() int {
+	return t.scheduler.transcriber.SampleRate()
+}
+
+// Close does nothing; the scheduler owns the backend.
+func (t *ScheduledTranscriber) Close() error {
+	return nil
+}
+
+// Streams returns a StreamingTranscriber whose streams each hold one of
+// the scheduler's stream slots for as long as they are open, as part of
+// key's flow. Chunk requests have slots of their own, so streams never hold
+// them up. Each stream's flow is charged for the audio it sends, so when
+// guilds wait for stream slots the one that has streamed least goes first.
+// A stream that finds every slot taken buffers its audio until one comes
+// free.
+func (s *Scheduler) Streams(streamer StreamingTranscriber, key string, weight float64) *ScheduledStreamer {
+	if weight <= 0 {
+		weight = 1
+	}
+	return &ScheduledStreamer{scheduler: s, streamer: streamer, key: key, weight: weight}
+}
+
+// ScheduledStreamer is one session's view of a shared StreamingTranscriber.
+type ScheduledStreamer struct {
+	scheduler *Scheduler
+	streamer  StreamingTranscriber
+	key       string
+	weight    float64
+}
+
+// NewStream returns at once; the stream connects once it gets a slot.
+func (t *ScheduledStreamer) NewStream() (Stream, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &scheduledStream{
+		streamer: t,
+		frames:   make(chan scheduledFrame, streamWaitFrames),
+		results:  make(chan audio.Utterance, 16),
+		closing:  make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	go stream.run()
+
+	return stream, nil
+}
+
+// Close does nothing; the bot owns the shared streamer.
+func (t *ScheduledStreamer) Close() error {
+	return nil
+}
+
+type scheduledFrame struct {
+	pcm       []int16
+	timestamp time.Time
+}
+
+func (f scheduledFrame) duration() time.Duration {
+	return time.Duration(len(f.pcm)) * time.Second / audio.SampleRate
+}
+
+// scheduledStream waits for a slot, then passes its audio on to a stream
+// of the shared streamer and hands back that stream's results.
+type scheduledStream struct {
+	streamer *ScheduledStreamer
+
+	frames    chan scheduledFrame
+	results   chan audio.Utterance
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	ctx    context.Context // Cancelled to give up waiting for a slot
+	cancel context.CancelFunc
+
+	ended bool              // Set once run stops taking frames
+	lost  []audio.LostChunk // Frames accepted but never passed on
+	mutex sync.Mutex
+}
+
+func (s *scheduledStream) run() {
+	defer close(s.results)
+	defer s.cancel()
+
+	slots := &s.streamer.scheduler.streams
+	queued := time.Now()
+	if err := slots.acquire(s.ctx, s.streamer.key, s.streamer.weight, 0); err != nil {
+		log.Warn().
+			Str("flow", s.streamer.key).
+			Int("frames", len(s.frames)).
+			Msg("Stream closed before a shared STT slot came free, dropping its audio")
+		s.end("no transcription slot came free")
+		return
+	}
+	defer slots.release()
+
+	if wait := time.Since(queued); wait > time.Second {
+		log.Info().
+			Str("flow", s.streamer.key).
+			Dur("wait", wait).
+			Msg("Stream waited for a shared STT slot")
+	}
+
+	stream, err := s.streamer.streamer.NewStream()
+	if err != nil {
+		log.Error().Err(err).Str("flow", s.streamer.key).Msg("Failed to open transcription stream")
+		s.end("transcription stream could not be opened")
+		return
+	}
+
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for utterance := range stream.Results() {
+			s.results <- utterance
+		}
+	}()
+
+	for {
+		select {
+		case frame := <-s.frames:
+			s.pass(stream, frame)
+		case <-s.closing:
+			// Pass on what was written before Close
+			for len(s.frames) > 0 {
+				s.pass(stream, <-s.frames)
+			}
+			stream.Close()
+			<-forwarded
+
+			s.mutex.Lock()
+			for _, lost := range stream.Lost() {
+				s.lost = audio.AppendLost(s.lost, lost)
+			}
+			s.mutex.Unlock()
+			s.end("transcription stream ended before the audio was sent")
+			return
+		}
+	}
+}
+
+// pass writes a buffered frame to the backend's stream and charges the
+// flow for it, or records it as lost if the stream refuses it.
+func (s *scheduledStream) pass(stream Stream, frame scheduledFrame) {
+	if err := stream.Write(frame.pcm, frame.timestamp); err != nil {
+		log.Debug().Err(err).Str("flow", s.streamer.key).Msg("Transcription stream refused audio")
+		s.addLost(frame, "transcription stream fell behind")
+		return
+	}
+	s.streamer.scheduler.streams.charge(s.streamer.key, s.streamer.weight, frame.duration().Seconds())
+}
+
+// end stops Write accepting frames, recording any still buffered as lost
+// for reason.
+func (s *scheduledStream) end(reason string) {
+	s.mutex.Lock()
+	s.ended = true
+	s.mutex.Unlock()
+
+	for {
+		select {
+		case frame := <-s.frames:
+			s.addLost(frame, reason)
+		default:
+			return
+		}
+	}
+}
+
+func (s *scheduledStream) addLost(frame scheduledFrame, reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lost = audio.AppendLost(s.lost, audio.LostChunk{
+		Start:  frame.timestamp,
+		End:    frame.timestamp.Add(frame.duration()),
+		Reason: reason,
+	})
+}
+
+func (s *scheduledStream) Write(pcm []int16, timestamp time.Time) error {
+	select {
+	case <-s.closing:
+		return fmt.Errorf("stream closed")
+	default:
+	}
+
+	// The caller reuses its buffers
+	frame := scheduledFrame{
+		pcm:       append([]int16(nil), pcm...),
+		timestamp: timestamp,
+	}
+
+	// Held while queuing, so end can't miss a frame
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ended {
+		return fmt.Errorf("stream ended")
+	}
+
+	select {
+	case s.frames <- frame:
+		return nil
+	default:
+		return fmt.Errorf("stream buffer full, dropping audio")
+	}
+}
+
+func (s *scheduledStream) Results() <-chan audio.Utterance {
+	return s.results
+}
+
+func (s *scheduledStream) Lost() []audio.LostChunk {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lost := make([]audio.LostChunk, len(s.lost))
+	copy(lost, s.lost)
+	return lost
+}
+
+// Close flushes the stream. One still waiting for a slot gives up after
+// streamCloseWait.
+func (s *scheduledStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+		time.AfterFunc(streamCloseWait, s.cancel)
+	})
+	return nil
+}
+
+// requestQueue is a min-heap of waiting requests by start tag.
+type requestQueue []*request
+
+func (q requestQueue) Len() int { return len(q) }
+
+func (q requestQueue) Less(i, j int) bool {
+	if q[i].start != q[j].start {
+		return q[i].start < q[j].start
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q requestQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *requestQueue) Push(x any) {
+	req := x.(*request)
+	req.index = len(*q)
+	*q = append(*q, req)
+}
+
+func (q *requestQueue) Pop() any {
+	old := *q
+	req := old[len(old)-1]
+	old[len(old)-1] = nil
+	req.index = -1
+	*q = old[:len(old)-1]
+	return req
+}
@@ -0,0 +1,316 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// waitFor polls until cond holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerSharesSlotsByWeight(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]float64
+		queued  map[string]int // One-second requests per flow
+		want    []string       // Flows in the order they get a slot
+	}{
+		{
+			name:    "equal weights alternate",
+			weights: map[string]float64{"busy": 1, "quiet": 1},
+			queued:  map[string]int{"busy": 3, "quiet": 2},
+			want:    []string{"busy", "quiet", "busy", "quiet", "busy"},
+		},
+		{
+			// Ties go to the request queued first
+			name:    "double weight gets two turns for one",
+			weights: map[string]float64{"heavy": 2, "light": 1},
+			queued:  map[string]int{"heavy": 4, "light": 2},
+			want:    []string{"heavy", "light", "heavy", "light", "heavy", "heavy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScheduler(nil, 1, 1)
+			ctx := context.Background()
+
+			// Hold the only slot while the flows queue up
+			if err := s.chunks.acquire(ctx, "holder", 1, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			var order []string
+			var orderMux sync.Mutex
+			var wg sync.WaitGroup
+			queued := 0
+			for round := 0; ; round++ {
+				added := false
+				for _, key := range []string{"busy", "quiet", "heavy", "light"} {
+					if round >= tt.queued[key] {
+						continue
+					}
+					added = true
+					queued++
+					wg.Add(1)
+					go func(key string) {
+						defer wg.Done()
+						if err := s.chunks.acquire(ctx, key, tt.weights[key], 1); err != nil {
+							t.Error(err)
+							return
+						}
+						orderMux.Lock()
+						order = append(order, key)
+						orderMux.Unlock()
+						s.chunks.release()
+					}(key)
+					// Queue in a known order
+					n := queued
+					waitFor(t, "request to queue", func() bool { return s.Waiting() == n })
+				}
+				if !added {
+					break
+				}
+			}
+
+			s.chunks.release()
+			wg.Wait()
+
+			if len(order) != len(tt.want) {
+				t.Fatalf("got order %v, want %v", order, tt.want)
+			}
+			for i := range tt.want {
+				if order[i] != tt.want[i] {
+					t.Fatalf("got order %v, want %v", order, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerRefundsCancelledRequests(t *testing.T) {
+	s := NewScheduler(nil, 1, 1)
+	if err := s.chunks.acquire(context.Background(), "holder", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// A long request that is cancelled while waiting, then a short one
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() { cancelled <- s.chunks.acquire(ctx, "guild", 1, 30) }()
+	waitFor(t, "long request to queue", func() bool { return s.Waiting() == 1 })
+
+	granted := make(chan error)
+	go func() { granted <- s.chunks.acquire(context.Background(), "guild", 1, 1) }()
+	waitFor(t, "short request to queue", func() bool { return s.Waiting() == 2 })
+
+	cancel()
+	if err := <-cancelled; err == nil {
+		t.Fatal("cancelled request was granted")
+	}
+
+	s.chunks.mutex.Lock()
+	finish := s.chunks.flows["guild"].finish
+	start := s.chunks.waiting[0].start
+	s.chunks.mutex.Unlock()
+	// The short request moves up to where the long one would have started
+	if finish != 1 || start != 0 {
+		t.Errorf("after cancelling, flow finish = %v and queued start = %v, want 1 and 0", finish, start)
+	}
+
+	s.chunks.release()
+	if err := <-granted; err != nil {
+		t.Fatal(err)
+	}
+	s.chunks.release()
+}
+
+// fakeStreamer records the streams opened through it.
+type fakeStreamer struct {
+	opened chan *fakeStream
+}
+
+func (f *fakeStreamer) NewStream() (Stream, error) {
+	stream := &fakeStream{results: make(chan audio.Utterance, 1)}
+	f.opened <- stream
+	return stream, nil
+}
+
+func (f *fakeStreamer) Close() error { return nil }
+
+type fakeStream struct {
+	writes  int
+	refuse  bool // Refuse every write, as a stream that has fallen behind
+	results chan audio.Utterance
+	mutex   sync.Mutex
+}
+
+func (f *fakeStream) Write(pcm []int16, timestamp time.Time) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.refuse {
+		return errors.New("stream buffer full, dropping audio")
+	}
+	f.writes++
+	return nil
+}
+
+func (f *fakeStream) written() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.writes
+}
+
+func (f *fakeStream) Results() <-chan audio.Utterance { return f.results }
+
+func (f *fakeStream) Lost() []audio.LostChunk { return nil }
+
+func (f *fakeStream) Close() error {
+	f.results <- audio.Utterance{Text: "final"}
+	close(f.results)
+	return nil
+}
+
+func TestScheduledStreamsHoldASlotWhileOpen(t *testing.T) {
+	s := NewScheduler(nil, 1, 1)
+	backend := &fakeStreamer{opened: make(chan *fakeStream, 2)}
+	streamer := s.Streams(backend, "guild", 1)
+
+	first, _ := streamer.NewStream()
+	firstBackend := <-backend.opened
+
+	// The second stream has to wait, buffering its audio meanwhile
+	second, _ := streamer.NewStream()
+	pcm := make([]int16, audio.FrameSize)
+	for i := 0; i < 5; i++ {
+		if err := second.Write(pcm, time.Now()); err != nil {
+			t.Fatalf("Write while waiting failed: %v", err)
+		}
+	}
+	waitFor(t, "second stream to queue", func() bool { return s.streams.queued() == 1 })
+	select {
+	case <-backend.opened:
+		t.Fatal("second stream opened while the first held the only slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first.Close()
+	for range first.Results() {
+	}
+	if firstBackend.written() != 0 {
+		t.Errorf("first stream wrote %d frames, want 0", firstBackend.written())
+	}
+
+	secondBackend := <-backend.opened
+	waitFor(t, "buffered audio to reach the backend", func() bool { return secondBackend.written() == 5 })
+
+	second.Close()
+	var results []audio.Utterance
+	for utterance := range second.Results() {
+		results = append(results, utterance)
+	}
+	if len(results) != 1 || results[0].Text != "final" {
+		t.Errorf("got results %+v, want the backend's final result", results)
+	}
+
+	s.streams.mutex.Lock()
+	active := s.streams.active
+	streamed := s.streams.flows["guild"].finish
+	s.streams.mutex.Unlock()
+	if active != 0 {
+		t.Errorf("%d slots still held after both streams ended", active)
+	}
+
+	// Charged the least a stream counts for, twice, and the 100ms sent
+	if want := 2*minRequestCost + 0.1; math.Abs(streamed-want) > 1e-9 {
+		t.Errorf("flow charged %.3fs, want %.3fs", streamed, want)
+	}
+}
+
+func TestScheduledStreamsLeaveChunkSlotsFree(t *testing.T) {
+	s := NewScheduler(nil, 1, 1)
+	backend := &fakeStreamer{opened: make(chan *fakeStream, 1)}
+	stream, _ := s.Streams(backend, "guild", 1).NewStream()
+	<-backend.opened
+
+	// The open stream holds the only stream slot, but chunks still get theirs
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.chunks.acquire(ctx, "other", 1, 1); err != nil {
+		t.Fatalf("chunk request waited behind an open stream: %v", err)
+	}
+	s.chunks.release()
+
+	stream.Close()
+	for range stream.Results() {
+	}
+}
+
+func TestSchedulerRefundedFlowCompetesWithNewFlow(t *testing.T) {
+	s := NewScheduler(nil, 1, 1)
+	ctx := context.Background()
+	if err := s.chunks.acquire(ctx, "holder", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Grants in order, with the virtual time each was made at
+	var order []string
+	var virtual []float64
+	var wg sync.WaitGroup
+	queue := func(ctx context.Context, key string, cost float64, n int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.chunks.acquire(ctx, key, 1, cost); err != nil {
+				return
+			}
+			s.chunks.mutex.Lock()
+			order = append(order, key)
+			virtual = append(virtual, s.chunks.virtual)
+			s.chunks.mutex.Unlock()
+			s.chunks.release()
+		}()
+		waitFor(t, key+" request to queue", func() bool { return s.Waiting() == n })
+	}
+
+	// A long request is refunded, moving the flow's next ones back into
+	// the time it would have used
+	cancelCtx, cancel := context.WithCancel(ctx)
+	queue(cancelCtx, "refunded", 30, 1)
+	queue(ctx, "refunded", 1, 2)
+	queue(ctx, "refunded", 1, 3)
+	cancel()
+	waitFor(t, "long request to be refunded", func() bool { return s.Waiting() == 2 })
+
+	// A flow that was idle until now starts level with the refunded one
+	queue(ctx, "new", 1, 3)
+	queue(ctx, "new", 1, 4)
+
+	s.chunks.release()
+	wg.Wait()
+
+	want := []string{"refunded", "new", "refunded", "new"}
+	if strings.Join(order, " ") != strings.Join(want, " ") {
+		t.Errorf("got order %v, want %v", order, want)
+	}
+	for i := 1; i < len(virtual); i++ {
+		if virtual[i] < virtual[i-1] {
+			t.Errorf("virtual time went back from %v to %v", virtual[i-1], virtual[i])
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package stt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// spillQueue holds chunks that arrived while the transcriber queue was full.
+// Only the PCM goes to disk; the rest of each chunk stays in memory so a
+// file that can't be read back is still accounted for.
+type spillQueue struct {
+	dir     string
+	entries []spillEntry // Oldest first
+	seq     int
+	mutex   sync.Mutex
+}
+
+type spillEntry struct {
+	path    string
+	chunk   audio.Chunk // Without PCM
+	samples int         // Written to the file, to catch a truncated one
+}
+
+func newSpillQueue(dir string) (*spillQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spill directory: %w", err)
+	}
+	return &spillQueue{dir: dir}, nil
+}
+
+// push writes a chunk's audio to disk and queues it behind earlier chunks.
+func (q *spillQueue) push(chunk *audio.Chunk) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.seq++
+	path := filepath.Join(q.dir, fmt.Sprintf("chunk_%06d.pcm", q.seq))
+
+	data := make([]byte, len(chunk.PCM)*2)
+	for i, sample := range chunk.PCM {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(sample))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+
+	header := *chunk
+	header.PCM = nil
+	q.entries = append(q.entries, spillEntry{path: path, chunk: header, samples: len(chunk.PCM)})
+	return nil
+}
+
+// pop reads back the oldest chunk and deletes its file. ok is false when
+// the queue is empty. On error the chunk is still removed from the queue
+// and returned without audio, so the caller can record it as lost.
+func (q *spillQueue) pop() (chunk *audio.Chunk, ok bool, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.entries) == 0 {
+		return nil, false, nil
+	}
+
+	entry := q.entries[0]
+	q.entries = q.entries[1:]
+	defer os.Remove(entry.path)
+
+	chunk = &entry.chunk
+	data, err := os.ReadFile(entry.path)
+	if err != nil {
+		return chunk, true, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	if len(data) != entry.samples*2 {
+		return chunk, true, fmt.Errorf("spill file %s has %d bytes, want %d", entry.path, len(data), entry.samples*2)
+	}
+
+	chunk.PCM = make([]int16, len(data)/2)
+	for i := range chunk.PCM {
+		chunk.PCM[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return chunk, true, nil
+}
+
+func (q *spillQueue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.entries)
+}
+
+// remove deletes the spill directory. The queue must be empty.
+func (q *spillQueue) remove() error {
+	return os.RemoveAll(q.dir)
+}
@@ -0,0 +1,175 @@
+package stt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// spillChunk is a short chunk whose samples, including negative ones,
+// identify it.
+func spillChunk(n int, start time.Time) *audio.Chunk {
+	pcm := make([]int16, audio.FrameSize)
+	for i := range pcm {
+		pcm[i] = int16((n*1000 + i) * (1 - 2*(i%2)))
+	}
+	return &audio.Chunk{
+		ID:         uuid.New(),
+		PCM:        pcm,
+		Start:      start.Add(time.Duration(n) * time.Second),
+		End:        start.Add(time.Duration(n)*time.Second + 20*time.Millisecond),
+		Speakers:   []string{"alice"},
+		SampleRate: audio.SampleRate,
+	}
+}
+
+func TestSpillQueueRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spill")
+	q, err := newSpillQueue(dir)
+	if err != nil {
+		t.Fatalf("newSpillQueue failed: %v", err)
+	}
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var pushed []*audio.Chunk
+	for n := 0; n < 3; n++ {
+		chunk := spillChunk(n, start)
+		if err := q.push(chunk); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+		pushed = append(pushed, chunk)
+	}
+	if q.len() != 3 {
+		t.Fatalf("len() = %d, want 3", q.len())
+	}
+
+	for _, want := range pushed {
+		got, ok, err := q.pop()
+		if !ok || err != nil {
+			t.Fatalf("pop() = %v, %v, want chunk %s", ok, err, want.ID)
+		}
+		if got.ID != want.ID || !got.Start.Equal(want.Start) || !got.End.Equal(want.End) ||
+			!slices.Equal(got.Speakers, want.Speakers) || got.SampleRate != want.SampleRate {
+			t.Errorf("pop() = %+v, want the header of %s", got, want.ID)
+		}
+		if !slices.Equal(got.PCM, want.PCM) {
+			t.Errorf("chunk %s audio changed on disk", want.ID)
+		}
+	}
+	if _, ok, _ := q.pop(); ok {
+		t.Error("pop() returned a chunk from an empty queue")
+	}
+
+	// Files are removed as they are read back
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d spill files left behind", len(files))
+	}
+	if err := q.remove(); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("spill directory still there after remove: %v", err)
+	}
+}
+
+func TestSpillQueueCorruptFiles(t *testing.T) {
+	dir := t.TempDir()
+	q, err := newSpillQueue(dir)
+	if err != nil {
+		t.Fatalf("newSpillQueue failed: %v", err)
+	}
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var pushed []*audio.Chunk
+	for n := 0; n < 3; n++ {
+		chunk := spillChunk(n, start)
+		if err := q.push(chunk); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+		pushed = append(pushed, chunk)
+	}
+
+	// The first file is cut short and the second disappears
+	if err := os.Truncate(q.entries[0].path, 101); err != nil {
+		t.Fatalf("failed to truncate spill file: %v", err)
+	}
+	if err := os.Remove(q.entries[1].path); err != nil {
+		t.Fatalf("failed to remove spill file: %v", err)
+	}
+
+	// Unreadable chunks still come back, without audio, so they can be
+	// recorded as lost, and don't hold up the ones behind them
+	for _, want := range pushed[:2] {
+		got, ok, err := q.pop()
+		if !ok || err == nil {
+			t.Fatalf("pop() = %v, %v, want an error for chunk %s", ok, err, want.ID)
+		}
+		if got.ID != want.ID || !got.Start.Equal(want.Start) || got.PCM != nil {
+			t.Errorf("pop() = %s with %d samples, want %s without audio", got.ID, len(got.PCM), want.ID)
+		}
+	}
+
+	got, ok, err := q.pop()
+	if !ok || err != nil || !slices.Equal(got.PCM, pushed[2].PCM) {
+		t.Fatalf("pop() = %v, %v, want the intact chunk", ok, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d spill files left behind", len(files))
+	}
+}
+
+func TestSpillQueueRestart(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// A queue abandoned with chunks still on disk, as after a crash
+	abandoned, err := newSpillQueue(dir)
+	if err != nil {
+		t.Fatalf("newSpillQueue failed: %v", err)
+	}
+	for n := 0; n < 2; n++ {
+		if err := abandoned.push(spillChunk(n, start)); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+
+	// Only the chunk headers in memory say what a file holds, so a new
+	// queue starts empty and its files replace the stale ones
+	q, err := newSpillQueue(dir)
+	if err != nil {
+		t.Fatalf("newSpillQueue over a used directory failed: %v", err)
+	}
+	if _, ok, _ := q.pop(); ok {
+		t.Fatal("pop() returned a stale chunk")
+	}
+
+	chunk := spillChunk(5, start)
+	if err := q.push(chunk); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	got, ok, err := q.pop()
+	if !ok || err != nil || got.ID != chunk.ID || !slices.Equal(got.PCM, chunk.PCM) {
+		t.Fatalf("pop() = %v, %v, want the new chunk's audio", ok, err)
+	}
+}
+
+func TestSpillChunkWithoutSpill(t *testing.T) {
+	pool := NewTranscriberPool(funcTranscriber(nil), 1, RetryPolicy{})
+	chunk := spillChunk(0, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+
+	if err := pool.SpillChunk(chunk); !errors.Is(err, errQueueFull) {
+		t.Fatalf("SpillChunk() error = %v, want errQueueFull", err)
+	}
+
+	// Kept for a retry rather than dropped
+	lost := pool.Lost()
+	if len(lost) != 1 || !lost[0].Start.Equal(chunk.Start) {
+		t.Errorf("Lost() = %+v, want the chunk", lost)
+	}
+}
@@ -0,0 +1,35 @@
+package stt
+
+import (
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// StreamingTranscriber transcribes speech as it is spoken rather than in
+// chunks. Each speaker gets their own stream so backends can keep context
+// across pauses.
+type StreamingTranscriber interface {
+	// NewStream opens a stream for one speaker. It returns without waiting
+	// for the backend, so audio written early is buffered until connected.
+	NewStream() (Stream, error)
+	// Close ends every open stream.
+	Close() error
+}
+
+// Stream is a single speaker's live transcription.
+type Stream interface {
+	// Write queues 48kHz mono PCM captured at timestamp. It never blocks;
+	// audio is dropped if the stream has fallen too far behind.
+	Write(pcm []int16, timestamp time.Time) error
+	// Results delivers interim and final utterances. It is closed once the
+	// stream has been closed and its final results have arrived.
+	Results() <-chan audio.Utterance
+	// Close stops accepting audio and flushes what is buffered. It does not
+	// wait; read Results until it is closed to collect the final results.
+	Close() error
+	// Lost returns audio that Write accepted but the backend never got.
+	// Audio Write refused is the caller's to record. Complete once Results
+	// is closed.
+	Lost() []audio.LostChunk
+}
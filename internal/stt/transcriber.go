@@ -2,38 +2,97 @@ package stt
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/user/discord-notetaker/internal/audio"
 	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/audio"
 )
 
 // Transcriber interface for STT backends
 type Transcriber interface {
 	Transcribe(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error)
+	// SampleRate is the input rate the backend prefers; chunks are resampled
+	// to it before Transcribe is called. Zero accepts chunks at any rate.
+	SampleRate() int
 	Close() error
 }
 
 // TranscriberPool manages a pool of STT workers
 type TranscriberPool struct {
-	transcriber    Transcriber
-	workers        int
-	chunkChan      chan *audio.Chunk
-	utteranceChan  chan []audio.Utterance
-	stopChan       chan struct{}
-	wg             sync.WaitGroup
-	started        bool
-	mutex          sync.Mutex
+	transcriber   Transcriber
+	workers       int
+	chunkChan     chan *audio.Chunk
+	utteranceChan chan []audio.Utterance
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	started       bool
+	mutex         sync.Mutex
+
+	// Resamplers to the transcriber's preferred rate, keyed by input rate
+	resamplers   map[int]*audio.Resampler
+	resamplerMux sync.Mutex
+
+	// Failure handling
+	policy      RetryPolicy
+	breaker     *circuitBreaker
+	deadLetters []DeadLetter      // Chunks that failed every attempt
+	lost        []audio.LostChunk // Chunks given up on entirely
+	deadMux     sync.Mutex        // Protects deadLetters and lost
+
+	// Overflow handling
+	spill       *spillQueue   // Nil unless EnableSpill was called
+	spillSignal chan struct{} // Wakes the feeder after a chunk is spilled
+	drainChan   chan struct{} // Closed by Drain; the feeder empties the spill and exits
+	feederDone  chan struct{} // Closed when the feeder exits, nil without a spill
+	closed      bool          // Stop or Drain has begun
+	closeMux    sync.RWMutex  // Held for reading while chunks are queued
+
+	// Results are released in start time order
+	reorder     *reorderer
+	releaseStop chan struct{} // Closed once workers exit; held results are flushed
+	releaseDone chan struct{} // Closed when the releaser exits
+
+	transcribed atomic.Int64 // Nanoseconds of audio transcribed, for usage accounting
 }
 
-func NewTranscriberPool(transcriber Transcriber, workers int) *TranscriberPool {
+// DeadLetter is a chunk that could not be transcribed.
+type DeadLetter struct {
+	Chunk    *audio.Chunk
+	Err      error
+	FailedAt time.Time
+}
+
+// errQueueFull is kept with chunks that found no room in the queue.
+var errQueueFull = errors.New("transcriber queue full")
+
+// enqueueTimeout is how long ProcessChunk waits for room in the queue when
+// chunks can't be spilled to disk.
+const enqueueTimeout = 2 * time.Second
+
+// maxDeadLetters bounds the failed chunks kept for a later retry; at 16kHz
+// a 5 second chunk is 160KB, so this is about 40MB.
+const maxDeadLetters = 256
+
+func NewTranscriberPool(transcriber Transcriber, workers int, policy RetryPolicy) *TranscriberPool {
 	return &TranscriberPool{
 		transcriber:   transcriber,
 		workers:       workers,
 		chunkChan:     make(chan *audio.Chunk, workers*2),
 		utteranceChan: make(chan []audio.Utterance, workers*2),
 		stopChan:      make(chan struct{}),
+		spillSignal:   make(chan struct{}, 1),
+		drainChan:     make(chan struct{}),
+		reorder:       newReorderer(DefaultReorderWindow),
+		releaseStop:   make(chan struct{}),
+		releaseDone:   make(chan struct{}),
+		resamplers:    make(map[int]*audio.Resampler),
+		policy:        policy,
+		breaker:       newCircuitBreaker("pool", policy.BreakerThreshold, policy.BreakerCooldown),
 	}
 }
 
@@ -47,6 +106,12 @@ func (p *TranscriberPool) Start(ctx context.Context) error {
 
 	p.started = true
 
+	if p.spill != nil {
+		p.feederDone = make(chan struct{})
+		go p.feed(ctx)
+	}
+	go p.release()
+
 	// Start worker goroutines
 	for i := 0; i < p.workers; i++ {
 		p.wg.Add(1)
@@ -70,48 +135,556 @@ func (p *TranscriberPool) worker(ctx context.Context, workerID int) {
 				return
 			}
 
-			utterances, err := p.transcriber.Transcribe(ctx, chunk)
+			resampled, err := p.resample(chunk)
+			if err != nil {
+				log.Error().
+					Err(err).
+					Int("worker_id", workerID).
+					Msg("Failed to resample chunk")
+				p.addDeadLetter(chunk, err)
+				continue
+			}
+			chunk = resampled
+
+			utterances, err := p.transcribe(ctx, chunk, p.stopChan)
 			if err != nil {
 				log.Error().
 					Err(err).
 					Str("chunk_id", chunk.ID.String()).
 					Int("worker_id", workerID).
 					Msg("Failed to transcribe chunk")
+				p.addDeadLetter(chunk, err)
 				continue
 			}
 
-			if len(utterances) > 0 {
-				select {
-				case p.utteranceChan <- utterances:
-					log.Debug().
-						Int("utterances", len(utterances)).
-						Str("chunk_id", chunk.ID.String()).
-						Int("worker_id", workerID).
-						Msg("Transcribed chunk")
-				case <-ctx.Done():
-					return
-				case <-p.stopChan:
+			p.reorder.done(chunk, utterances)
+			log.Debug().
+				Int("utterances", len(utterances)).
+				Str("chunk_id", chunk.ID.String()).
+				Int("worker_id", workerID).
+				Msg("Transcribed chunk")
+
+		case <-ctx.Done():
+			return
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+// transcribe sends a chunk to the backend, retrying transient failures
+// with backoff and waiting while the circuit breaker is open.
+func (p *TranscriberPool) transcribe(ctx context.Context, chunk *audio.Chunk, stop <-chan struct{}) ([]audio.Utterance, error) {
+	for attempt := 0; ; attempt++ {
+		trial, err := p.breaker.wait(ctx, stop)
+		if err != nil {
+			return nil, err
+		}
+
+		utterances, err := p.transcriber.Transcribe(ctx, chunk)
+		if err == nil {
+			p.breaker.success()
+			p.transcribed.Add(int64(chunkDuration(chunk)))
+			return utterances, nil
+		}
+		if ctx.Err() != nil {
+			if trial {
+				p.breaker.abort()
+			}
+			return nil, err
+		}
+
+		if !IsRetryable(err) {
+			p.breaker.success()
+			return nil, err
+		}
+		p.breaker.failure()
+
+		if attempt >= p.policy.MaxRetries {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		delay := p.policy.backoff(attempt, retryAfter(err))
+		log.Warn().
+			Err(err).
+			Str("chunk_id", chunk.ID.String()).
+			Int("attempt", attempt+1).
+			Dur("retry_in", delay).
+			Msg("Transient transcription failure, retrying")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-stop:
+			return nil, errPoolStopped
+		}
+	}
+}
+
+// addDeadLetter keeps a failed chunk for RetryDeadLetters, dropping the
+// oldest when the list is full.
+func (p *TranscriberPool) addDeadLetter(chunk *audio.Chunk, err error) {
+	p.reorder.forget(chunk)
+
+	p.deadMux.Lock()
+	defer p.deadMux.Unlock()
+
+	if len(p.deadLetters) >= maxDeadLetters {
+		oldest := p.deadLetters[0]
+		log.Warn().
+			Str("chunk_id", oldest.Chunk.ID.String()).
+			Msg("Dead letter list full, discarding oldest failed chunk")
+		p.lost = append(p.lost, lostChunk(oldest.Chunk, "too many failed chunks to retry"))
+		p.deadLetters = p.deadLetters[1:]
+	}
+
+	p.deadLetters = append(p.deadLetters, DeadLetter{
+		Chunk:    chunk,
+		Err:      err,
+		FailedAt: time.Now(),
+	})
+}
+
+// DeadLetters returns the chunks that could not be transcribed.
+func (p *TranscriberPool) DeadLetters() []DeadLetter {
+	p.deadMux.Lock()
+	defer p.deadMux.Unlock()
+
+	letters := make([]DeadLetter, len(p.deadLetters))
+	copy(letters, p.deadLetters)
+	return letters
+}
+
+// RetryDeadLetters transcribes the failed chunks again, as many at a time
+// as the pool has workers, and returns the recovered utterances. It works
+// after Stop or Drain, so sessions can make a last attempt while
+// finalizing; chunks still queued when the pool stopped are retried here
+// too. An open circuit breaker is waited out; chunks that fail again stay in
+// the list, and once a failure reopens the breaker the remaining chunks
+// aren't attempted.
+func (p *TranscriberPool) RetryDeadLetters(ctx context.Context) []audio.Utterance {
+	p.deadMux.Lock()
+	letters := p.deadLetters
+	p.deadLetters = nil
+	p.deadMux.Unlock()
+
+	if len(letters) == 0 {
+		return nil
+	}
+
+	log.Info().Int("chunks", len(letters)).Msg("Retrying failed chunks")
+
+	var (
+		recovered []audio.Utterance
+		failed    []DeadLetter
+		resultMux sync.Mutex
+		wg        sync.WaitGroup
+		giveUp    atomic.Bool
+	)
+	slots := make(chan struct{}, max(p.workers, 1))
+
+	for _, letter := range letters {
+		slots <- struct{}{}
+		if giveUp.Load() || ctx.Err() != nil {
+			<-slots
+			resultMux.Lock()
+			failed = append(failed, letter)
+			resultMux.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(letter DeadLetter) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			chunk, err := p.resample(letter.Chunk)
+			var utterances []audio.Utterance
+			if err == nil {
+				utterances, err = p.transcribe(ctx, chunk, nil)
+			}
+
+			resultMux.Lock()
+			defer resultMux.Unlock()
+			if err == nil {
+				recovered = append(recovered, utterances...)
+				return
+			}
+
+			failed = append(failed, DeadLetter{Chunk: letter.Chunk, Err: err, FailedAt: time.Now()})
+			if ctx.Err() != nil || p.breaker.isOpen() {
+				giveUp.Store(true)
+			}
+		}(letter)
+	}
+	wg.Wait()
+
+	// Keep the list in timeline order for later retries and reports
+	sort.SliceStable(failed, func(i, j int) bool {
+		return failed[i].Chunk.Start.Before(failed[j].Chunk.Start)
+	})
+
+	p.deadMux.Lock()
+	p.deadLetters = append(failed, p.deadLetters...)
+	p.deadMux.Unlock()
+
+	log.Info().
+		Int("recovered_chunks", len(letters)-len(failed)).
+		Int("failed_chunks", len(failed)).
+		Int("utterances", len(recovered)).
+		Msg("Retried failed chunks")
+
+	return recovered
+}
+
+// Transcribed returns how much audio the pool has transcribed, counting
+// each chunk once however many attempts it took.
+func (p *TranscriberPool) Transcribed() time.Duration {
+	return time.Duration(p.transcribed.Load())
+}
+
+// chunkDuration returns the length of a chunk's audio.
+func chunkDuration(chunk *audio.Chunk) time.Duration {
+	rate := chunk.SampleRate
+	if rate == 0 {
+		rate = audio.SampleRate
+	}
+	return time.Duration(len(chunk.PCM)) * time.Second / time.Duration(rate)
+}
+
+// resample converts a chunk to the transcriber's preferred sample rate.
+func (p *TranscriberPool) resample(chunk *audio.Chunk) (*audio.Chunk, error) {
+	target := p.transcriber.SampleRate()
+	from := chunk.SampleRate
+	if from == 0 {
+		from = audio.SampleRate
+	}
+	if target == 0 || target == from {
+		return chunk, nil
+	}
+
+	p.resamplerMux.Lock()
+	resampler, ok := p.resamplers[from]
+	if !ok {
+		var err error
+		resampler, err = audio.NewResampler(from, target)
+		if err != nil {
+			p.resamplerMux.Unlock()
+			return nil, err
+		}
+		p.resamplers[from] = resampler
+	}
+	p.resamplerMux.Unlock()
+
+	return audio.ResampleChunk(chunk, resampler), nil
+}
+
+// EnableSpill lets ProcessChunk write chunks to dir while the queue is
+// full, instead of holding up the caller. Spilled chunks are fed back in
+// order as workers free up. Call it before Start.
+func (p *TranscriberPool) EnableSpill(dir string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.started {
+		return fmt.Errorf("pool already started")
+	}
+
+	spill, err := newSpillQueue(dir)
+	if err != nil {
+		return err
+	}
+
+	p.spill = spill
+	return nil
+}
+
+// feed moves spilled chunks back into the queue as workers take chunks off
+// it. It exits on Stop, or on Drain once the spill is empty.
+func (p *TranscriberPool) feed(ctx context.Context) {
+	defer close(p.feederDone)
+
+	for {
+		chunk, ok, err := p.spill.pop()
+		if !ok {
+			select {
+			case <-p.spillSignal:
+			case <-p.drainChan:
+				if p.spill.len() == 0 {
 					return
 				}
+			case <-p.stopChan:
+				return
+			case <-ctx.Done():
+				return
 			}
+			continue
+		}
 
-		case <-ctx.Done():
-			return
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("chunk_id", chunk.ID.String()).
+				Msg("Failed to read back spilled chunk")
+			p.addLost(chunk, "spilled audio could not be read back")
+			continue
+		}
+
+		select {
+		case p.chunkChan <- chunk:
 		case <-p.stopChan:
+			p.addDeadLetter(chunk, errPoolStopped)
+			return
+		case <-ctx.Done():
+			p.addDeadLetter(chunk, ctx.Err())
 			return
 		}
 	}
 }
 
+// ProcessChunk queues a chunk from a live session without holding up the
+// caller for long. While the queue is full the chunk is spilled to disk if
+// EnableSpill was called, or else waited on for up to enqueueTimeout. A
+// chunk that still finds no room, or arrives after Stop, is kept as a dead
+// letter for RetryDeadLetters rather than dropped.
 func (p *TranscriberPool) ProcessChunk(chunk *audio.Chunk) error {
+	p.closeMux.RLock()
+	defer p.closeMux.RUnlock()
+
+	if p.closed {
+		p.addDeadLetter(chunk, errPoolStopped)
+		return errPoolStopped
+	}
+	p.reorder.track(chunk)
+
+	// Once chunks are spilling, newer ones queue up behind them
+	if p.spill == nil || p.spill.len() == 0 {
+		select {
+		case p.chunkChan <- chunk:
+			return nil
+		default:
+		}
+	}
+
+	if p.spill != nil {
+		err := p.pushSpill(chunk)
+		if err == nil {
+			log.Debug().
+				Str("chunk_id", chunk.ID.String()).
+				Int("spilled", p.spill.len()).
+				Msg("Transcriber queue full, spilled chunk to disk")
+			return nil
+		}
+		log.Error().
+			Err(err).
+			Str("chunk_id", chunk.ID.String()).
+			Msg("Failed to spill chunk, waiting for room")
+	}
+
+	select {
+	case p.chunkChan <- chunk:
+		return nil
+	case <-time.After(enqueueTimeout):
+		p.addDeadLetter(chunk, errQueueFull)
+		return errQueueFull
+	}
+}
+
+// SpillChunk writes a chunk straight to the spill, behind any already
+// there, for a caller whose own queue in front of the pool is full. Without
+// a spill, or if writing it fails, the chunk is kept as a dead letter.
+func (p *TranscriberPool) SpillChunk(chunk *audio.Chunk) error {
+	p.closeMux.RLock()
+	defer p.closeMux.RUnlock()
+
+	if p.closed {
+		p.addDeadLetter(chunk, errPoolStopped)
+		return errPoolStopped
+	}
+	if p.spill == nil {
+		p.addDeadLetter(chunk, errQueueFull)
+		return errQueueFull
+	}
+
+	p.reorder.track(chunk)
+	if err := p.pushSpill(chunk); err != nil {
+		p.addDeadLetter(chunk, errQueueFull)
+		return fmt.Errorf("failed to spill chunk: %w", err)
+	}
+	return nil
+}
+
+// pushSpill writes a chunk to the spill and wakes the feeder.
+func (p *TranscriberPool) pushSpill(chunk *audio.Chunk) error {
+	if err := p.spill.push(chunk); err != nil {
+		return err
+	}
+	select {
+	case p.spillSignal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SubmitChunk queues a chunk, waiting for room instead of dropping it. Used
+// for offline replay, where audio arrives faster than real time.
+func (p *TranscriberPool) SubmitChunk(ctx context.Context, chunk *audio.Chunk) error {
+	p.closeMux.RLock()
+	defer p.closeMux.RUnlock()
+
+	if p.closed {
+		return errPoolStopped
+	}
+	p.reorder.track(chunk)
+
 	select {
 	case p.chunkChan <- chunk:
 		return nil
+	case <-ctx.Done():
+		p.reorder.forget(chunk)
+		return ctx.Err()
+	}
+}
+
+// addLost records a chunk that will never be transcribed.
+func (p *TranscriberPool) addLost(chunk *audio.Chunk, reason string) {
+	p.reorder.forget(chunk)
+
+	p.deadMux.Lock()
+	defer p.deadMux.Unlock()
+	p.lost = append(p.lost, lostChunk(chunk, reason))
+}
+
+// Lost returns the chunks that were never transcribed: dead letters still
+// waiting for a retry, and chunks that had to be given up on.
+func (p *TranscriberPool) Lost() []audio.LostChunk {
+	p.deadMux.Lock()
+	defer p.deadMux.Unlock()
+
+	lost := make([]audio.LostChunk, 0, len(p.lost)+len(p.deadLetters))
+	lost = append(lost, p.lost...)
+	for _, letter := range p.deadLetters {
+		lost = append(lost, lostChunk(letter.Chunk, lostReason(letter.Err)))
+	}
+	return lost
+}
+
+func lostChunk(chunk *audio.Chunk, reason string) audio.LostChunk {
+	return audio.LostChunk{
+		Start:    chunk.Start,
+		End:      chunk.End,
+		Speakers: chunk.Speakers,
+		Reason:   reason,
+	}
+}
+
+// lostReason describes why a dead letter was never transcribed, for the
+// notes rather than the logs.
+func lostReason(err error) string {
+	switch {
+	case errors.Is(err, errPoolStopped), errors.Is(err, context.Canceled):
+		return "not transcribed before the session ended"
+	case errors.Is(err, errQueueFull):
+		return "transcriber queue full"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "transcription timed out"
 	default:
-		return fmt.Errorf("chunk queue full, dropping chunk")
+		return "transcription failed"
 	}
 }
 
+// close stops ProcessChunk and SubmitChunk from queueing more chunks, so
+// the queue can be closed.
+func (p *TranscriberPool) close() {
+	p.closeMux.Lock()
+	defer p.closeMux.Unlock()
+	p.closed = true
+}
+
+// abandonQueued keeps every chunk the workers didn't get to as a dead
+// letter, and removes the spill directory. Workers must have exited.
+func (p *TranscriberPool) abandonQueued() {
+	abandoned := 0
+	for chunk := range p.chunkChan {
+		p.addDeadLetter(chunk, errPoolStopped)
+		abandoned++
+	}
+
+	if p.spill != nil {
+		for {
+			chunk, ok, err := p.spill.pop()
+			if !ok {
+				break
+			}
+			if err != nil {
+				log.Error().Err(err).Str("chunk_id", chunk.ID.String()).Msg("Failed to read back spilled chunk")
+				p.addLost(chunk, "spilled audio could not be read back")
+				continue
+			}
+			p.addDeadLetter(chunk, errPoolStopped)
+			abandoned++
+		}
+
+		if err := p.spill.remove(); err != nil {
+			log.Warn().Err(err).Msg("Failed to remove spill directory")
+		}
+	}
+
+	if abandoned > 0 {
+		log.Info().Int("chunks", abandoned).Msg("Kept untranscribed chunks for a retry")
+	}
+}
+
+// SetReorderWindow sets how long results may be held back waiting for an
+// earlier chunk to finish; zero releases them as soon as they arrive. Call
+// it before Start.
+func (p *TranscriberPool) SetReorderWindow(window time.Duration) {
+	p.reorder.window = window
+}
+
+// release sends results on in start time order as the reorderer lets them
+// go, then everything still held once the workers have exited.
+func (p *TranscriberPool) release() {
+	defer close(p.releaseDone)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		batch, next := p.reorder.ready(time.Now())
+		if len(batch) > 0 {
+			p.utteranceChan <- batch
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		var due <-chan time.Time
+		if next > 0 {
+			timer.Reset(next)
+			due = timer.C
+		}
+
+		select {
+		case <-p.reorder.signal:
+		case <-due:
+		case <-p.releaseStop:
+			if batch := p.reorder.flush(); len(batch) > 0 {
+				p.utteranceChan <- batch
+			}
+			return
+		}
+	}
+}
+
+// GetUtterances returns transcribed utterances in start time order, apart
+// from results that arrive after the reorder window. The channel must be
+// read until it closes, after Stop or Drain.
 func (p *TranscriberPool) GetUtterances() <-chan []audio.Utterance {
 	return p.utteranceChan
 }
@@ -124,13 +697,50 @@ func (p *TranscriberPool) Stop() {
 		return
 	}
 
+	p.close()
 	close(p.stopChan)
+	if p.feederDone != nil {
+		<-p.feederDone
+	}
 	close(p.chunkChan)
 
 	// Wait for all workers to finish
 	p.wg.Wait()
+	p.abandonQueued()
+	close(p.releaseStop)
+	<-p.releaseDone
 	close(p.utteranceChan)
 
 	p.started = false
 	log.Info().Msg("Stopped STT worker pool")
-}
\ No newline at end of file
+}
+
+// Drain stops accepting chunks and waits until every queued chunk has been
+// transcribed, then closes the utterance channel. Unlike Stop, queued work
+// is not abandoned; utterances must keep being read until the channel closes.
+func (p *TranscriberPool) Drain() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.started {
+		return
+	}
+
+	// Spilled chunks are fed in before the queue is closed
+	p.close()
+	close(p.drainChan)
+	if p.feederDone != nil {
+		<-p.feederDone
+	}
+	close(p.chunkChan)
+
+	p.wg.Wait()
+	close(p.stopChan)
+	p.abandonQueued()
+	close(p.releaseStop)
+	<-p.releaseDone
+	close(p.utteranceChan)
+
+	p.started = false
+	log.Info().Msg("Drained STT worker pool")
+}
@@ -0,0 +1,94 @@
+package vosk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// preferredSampleRate is the rate Vosk models are trained on. Chunks are
+// resampled to it before recognition.
+const preferredSampleRate = 16000
+
+// VoskResult is the JSON a recognizer returns with word output enabled.
+type VoskResult struct {
+	Text   string     `json:"text"`
+	Result []VoskWord `json:"result"`
+}
+
+// VoskWord is a recognized word with its timing in seconds from the start
+// of the audio fed to the recognizer.
+type VoskWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Conf  float64 `json:"conf"`
+}
+
+// parseResult converts a recognizer result into an utterance placed on the
+// chunk's timeline. Returns nil for results without any speech.
+func parseResult(data string, chunk *audio.Chunk) (*audio.Utterance, error) {
+	var result VoskResult
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode vosk result: %w", err)
+	}
+
+	text := strings.TrimSpace(result.Text)
+	if text == "" {
+		return nil, nil
+	}
+
+	utt := &audio.Utterance{
+		ID:      uuid.New(),
+		TSStart: chunk.Start,
+		TSEnd:   chunk.End,
+		Text:    text,
+		Source:  "vosk",
+	}
+
+	// Word timings narrow the utterance to when it was actually spoken
+	if len(result.Result) > 0 {
+		first := result.Result[0]
+		last := result.Result[len(result.Result)-1]
+		utt.TSStart = chunk.TimeAt(secondsToDuration(first.Start))
+		utt.TSEnd = chunk.TimeAt(secondsToDuration(last.End))
+
+		var total float64
+		utt.Words = make([]audio.Word, 0, len(result.Result))
+		for _, word := range result.Result {
+			total += word.Conf
+			utt.Words = append(utt.Words, audio.Word{
+				Text:       word.Word,
+				Start:      chunk.TimeAt(secondsToDuration(word.Start)),
+				End:        chunk.TimeAt(secondsToDuration(word.End)),
+				Confidence: word.Conf,
+			})
+		}
+		utt.Confidence = total / float64(len(result.Result))
+	}
+
+	if len(chunk.Speakers) > 0 {
+		utt.UserID = chunk.Speakers[0]
+		utt.UserTag = chunk.Speakers[0]
+	}
+
+	return utt, nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// pcmBytes converts samples to the little-endian 16-bit buffer Vosk reads.
+func pcmBytes(pcm []int16) []byte {
+	buf := make([]byte, len(pcm)*2)
+	for i, sample := range pcm {
+		buf[i*2] = byte(sample)
+		buf[i*2+1] = byte(sample >> 8)
+	}
+	return buf
+}
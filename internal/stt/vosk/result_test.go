@@ -0,0 +1,91 @@
+package vosk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+func TestParseResult(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	resumed := start.Add(30 * time.Second)
+	chunk := &audio.Chunk{
+		Start:    start,
+		End:      resumed.Add(time.Second),
+		Speakers: []string{"alice"},
+		Anchors: []audio.TimeAnchor{
+			{Offset: 0, At: start},
+			{Offset: time.Second, At: resumed},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		data      string
+		wantText  string // Empty when no utterance is expected
+		wantStart time.Time
+		wantEnd   time.Time
+		wantWords int
+		wantConf  float64
+	}{
+		{
+			name:      "words place the utterance",
+			data:      `{"text": "before after", "result": [{"word": "before", "start": 0.5, "end": 0.75, "conf": 1}, {"word": "after", "start": 1.25, "end": 1.5, "conf": 0.5}]}`,
+			wantText:  "before after",
+			wantStart: start.Add(500 * time.Millisecond),
+			wantEnd:   resumed.Add(500 * time.Millisecond),
+			wantWords: 2,
+			wantConf:  0.75,
+		},
+		{
+			name:      "no words spans the chunk",
+			data:      `{"text": " hello "}`,
+			wantText:  "hello",
+			wantStart: start,
+			wantEnd:   resumed.Add(time.Second),
+		},
+		{
+			name: "no speech",
+			data: `{"text": ""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utt, err := parseResult(tt.data, chunk)
+			if err != nil {
+				t.Fatalf("parseResult failed: %v", err)
+			}
+
+			if tt.wantText == "" {
+				if utt != nil {
+					t.Errorf("got %+v, want no utterance", utt)
+				}
+				return
+			}
+			if utt == nil {
+				t.Fatal("got no utterance")
+			}
+
+			if utt.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", utt.Text, tt.wantText)
+			}
+			if !utt.TSStart.Equal(tt.wantStart) || !utt.TSEnd.Equal(tt.wantEnd) {
+				t.Errorf("span = %s-%s, want %s-%s", utt.TSStart, utt.TSEnd, tt.wantStart, tt.wantEnd)
+			}
+			if len(utt.Words) != tt.wantWords || utt.Confidence != tt.wantConf {
+				t.Errorf("got %d words at confidence %v, want %d at %v", len(utt.Words), utt.Confidence, tt.wantWords, tt.wantConf)
+			}
+			if utt.UserID != "alice" || utt.Source != "vosk" {
+				t.Errorf("utterance from %q via %q, want alice via vosk", utt.UserID, utt.Source)
+			}
+		})
+	}
+}
+
+func TestParseResultRejectsInvalidJSON(t *testing.T) {
+	if _, err := parseResult("{", &audio.Chunk{}); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,151 @@
+//go:build vosk
+
+package vosk
+
+import (
+	"context"
+	"fmt"
+
+	vosk "github.com/alphacep/vosk-api/go"
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// feedSamples is how much audio is given to the recognizer at a time;
+// cancellation is checked between blocks.
+const feedSamples = preferredSampleRate / 4
+
+// Available reports whether this build includes the Vosk backend.
+const Available = true
+
+// VoskTranscriber recognizes speech locally with a Vosk model. The model is
+// loaded once and shared; recognizers are stateful, so each concurrent
+// transcription uses its own, recycled between chunks.
+type VoskTranscriber struct {
+	model       *vosk.VoskModel
+	recognizers chan *vosk.VoskRecognizer
+}
+
+// NewVoskTranscriber loads the model at modelPath. workers bounds how many
+// idle recognizers are kept for reuse.
+func NewVoskTranscriber(modelPath string, workers int) (*VoskTranscriber, error) {
+	vosk.SetLogLevel(-1)
+
+	model, err := vosk.NewModel(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vosk model from %s: %w", modelPath, err)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	log.Info().
+		Str("model_path", modelPath).
+		Msg("Loaded Vosk model")
+
+	return &VoskTranscriber{
+		model:       model,
+		recognizers: make(chan *vosk.VoskRecognizer, workers),
+	}, nil
+}
+
+func (v *VoskTranscriber) Transcribe(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+	if len(chunk.PCM) == 0 {
+		return nil, nil
+	}
+
+	sampleRate := chunk.SampleRate
+	if sampleRate == 0 {
+		sampleRate = audio.SampleRate
+	}
+	if sampleRate != preferredSampleRate {
+		return nil, fmt.Errorf("vosk expects %dHz audio, got %dHz", preferredSampleRate, sampleRate)
+	}
+
+	recognizer, err := v.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer v.release(recognizer)
+
+	var utterances []audio.Utterance
+	collect := func(result string) error {
+		utt, err := parseResult(result, chunk)
+		if err != nil {
+			return err
+		}
+		if utt != nil {
+			utterances = append(utterances, *utt)
+		}
+		return nil
+	}
+
+	// Vosk endpoints on pauses itself, so a chunk may yield several results
+	for offset := 0; offset < len(chunk.PCM); offset += feedSamples {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		end := min(offset+feedSamples, len(chunk.PCM))
+		if recognizer.AcceptWaveform(pcmBytes(chunk.PCM[offset:end])) > 0 {
+			if err := collect(recognizer.Result()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := collect(recognizer.FinalResult()); err != nil {
+		return nil, err
+	}
+
+	log.Debug().
+		Str("chunk_id", chunk.ID.String()).
+		Int("utterances", len(utterances)).
+		Msg("Vosk transcription completed")
+
+	return utterances, nil
+}
+
+// acquire returns an idle recognizer, creating one if none is free.
+func (v *VoskTranscriber) acquire() (*vosk.VoskRecognizer, error) {
+	select {
+	case recognizer := <-v.recognizers:
+		return recognizer, nil
+	default:
+	}
+
+	recognizer, err := vosk.NewRecognizer(v.model, preferredSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vosk recognizer: %w", err)
+	}
+	recognizer.SetWords(1)
+	return recognizer, nil
+}
+
+// release resets a recognizer for the next chunk and keeps it if there is
+// room, freeing it otherwise.
+func (v *VoskTranscriber) release(recognizer *vosk.VoskRecognizer) {
+	recognizer.Reset()
+	select {
+	case v.recognizers <- recognizer:
+	default:
+		recognizer.Free()
+	}
+}
+
+func (v *VoskTranscriber) SampleRate() int {
+	return preferredSampleRate
+}
+
+func (v *VoskTranscriber) Close() error {
+	for {
+		select {
+		case recognizer := <-v.recognizers:
+			recognizer.Free()
+		default:
+			v.model.Free()
+			return nil
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !vosk
+
+package vosk
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+// Available reports whether this build includes the Vosk backend. It needs
+// libvosk, so it is only compiled in with the "vosk" build tag.
+const Available = false
+
+// VoskTranscriber is unavailable in builds without the "vosk" tag.
+type VoskTranscriber struct{}
+
+// NewVoskTranscriber always fails; rebuild with -tags vosk to use Vosk.
+func NewVoskTranscriber(modelPath string, workers int) (*VoskTranscriber, error) {
+	return nil, fmt.Errorf("vosk support is not compiled in; rebuild with -tags vosk and libvosk installed")
+}
+
+func (v *VoskTranscriber) Transcribe(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+	return nil, fmt.Errorf("vosk support is not compiled in")
+}
+
+func (v *VoskTranscriber) SampleRate() int {
+	return preferredSampleRate
+}
+
+func (v *VoskTranscriber) Close() error {
+	return nil
+}
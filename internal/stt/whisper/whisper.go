@@ -0,0 +1,269 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/user/discord-notetaker/internal/audio"
+	"github.com/user/discord-notetaker/internal/stt"
+)
+
+// Server APIs
+const (
+	APIOpenAI     = "openai"     // POST /v1/audio/transcriptions, e.g. faster-whisper-server
+	APIWhisperCpp = "whispercpp" // POST /inference on the whisper.cpp server
+)
+
+const (
+	// preferredSampleRate is what Whisper models consume; servers would
+	// resample anything else themselves
+	preferredSampleRate = 16000
+
+	// requestTimeout bounds a single transcription. Generous because CPU-only
+	// servers can take several times real time.
+	requestTimeout = 2 * time.Minute
+
+	// Segments Whisper itself considers probably silent and that it decoded
+	// with low confidence are hallucinations such as "Thanks for watching"
+	noSpeechThreshold = 0.6
+	logProbThreshold  = -1.0
+)
+
+// Options configures a WhisperTranscriber.
+type Options struct {
+	BaseURL  string // e.g. http://localhost:8000
+	API      string // APIOpenAI or APIWhisperCpp
+	Model    string // Sent as "model"; ignored by whisper.cpp, which serves one model
+	Language string // ISO-639-1 code; empty lets the server detect it
+	Prompt   string // Vocabulary and style hint, e.g. names and jargon
+	APIKey   string // Optional bearer token
+}
+
+// WhisperTranscriber transcribes chunks with a locally run Whisper server.
+type WhisperTranscriber struct {
+	opts     Options
+	endpoint string
+	client   *http.Client
+}
+
+// WhisperResponse is the verbose_json transcription format shared by
+// OpenAI-compatible servers and whisper.cpp.
+type WhisperResponse struct {
+	Text     string           `json:"text"`
+	Language string           `json:"language"`
+	Segments []WhisperSegment `json:"segments"`
+}
+
+// WhisperSegment is a span of the transcription with its timing in seconds
+// from the start of the uploaded audio.
+type WhisperSegment struct {
+	Start        float64 `json:"start"`
+	End          float64 `json:"end"`
+	Text         string  `json:"text"`
+	AvgLogProb   float64 `json:"avg_logprob"`
+	NoSpeechProb float64 `json:"no_speech_prob"`
+}
+
+// NewWhisperTranscriber validates opts and returns a transcriber.
+func NewWhisperTranscriber(opts Options) (*WhisperTranscriber, error) {
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("whisper server URL is required")
+	}
+
+	var endpoint string
+	switch opts.API {
+	case APIOpenAI:
+		endpoint = baseURL + "/v1/audio/transcriptions"
+	case APIWhisperCpp:
+		endpoint = baseURL + "/inference"
+	default:
+		return nil, fmt.Errorf("unknown whisper API %q, expected %q or %q", opts.API, APIOpenAI, APIWhisperCpp)
+	}
+
+	return &WhisperTranscriber{
+		opts:     opts,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+func (w *WhisperTranscriber) Transcribe(ctx context.Context, chunk *audio.Chunk) ([]audio.Utterance, error) {
+	if len(chunk.PCM) == 0 {
+		return nil, nil
+	}
+
+	sampleRate := chunk.SampleRate
+	if sampleRate == 0 {
+		sampleRate = audio.SampleRate
+	}
+
+	body, contentType, err := w.buildForm(audio.EncodeWAV(chunk.PCM, sampleRate))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug().
+		Str("url", w.endpoint).
+		Str("model", w.opts.Model).
+		Str("language", w.opts.Language).
+		Int("audio_size_bytes", body.Len()).
+		Msg("Making Whisper API request")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.opts.APIKey)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Whisper API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Warn().
+			Int("status_code", resp.StatusCode).
+			Str("response_body", string(data)).
+			Str("url", w.endpoint).
+			Msg("Whisper API error response")
+		return nil, stt.NewAPIError("Whisper", resp, data)
+	}
+
+	var result WhisperResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Warn().
+			Str("response_body", string(data)).
+			Msg("Failed to parse Whisper response")
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	utterances := w.toUtterances(&result, chunk)
+
+	log.Debug().
+		Str("chunk_id", chunk.ID.String()).
+		Str("language", result.Language).
+		Int("segments", len(result.Segments)).
+		Int("utterances", len(utterances)).
+		Msg("Whisper transcription completed")
+
+	return utterances, nil
+}
+
+// buildForm encodes the multipart upload. Both APIs take the audio as
+// "file" and accept the same optional fields.
+func (w *WhisperTranscriber) buildForm(wav []byte) (*bytes.Buffer, string, error) {
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+
+	part, err := form.CreateFormFile("file", "chunk.wav")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(wav); err != nil {
+		return nil, "", fmt.Errorf("failed to write audio: %w", err)
+	}
+
+	fields := map[string]string{
+		"response_format": "verbose_json",
+		"temperature":     "0",
+		"model":           w.opts.Model,
+		"language":        w.opts.Language,
+		"prompt":          w.opts.Prompt,
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return nil, "", fmt.Errorf("failed to write form field %s: %w", name, err)
+		}
+	}
+
+	if err := form.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to finish form: %w", err)
+	}
+	return body, form.FormDataContentType(), nil
+}
+
+// toUtterances turns each segment into an utterance on the chunk's
+// timeline, dropping likely hallucinations. Servers that return no segments
+// yield a single utterance spanning the chunk.
+func (w *WhisperTranscriber) toUtterances(result *WhisperResponse, chunk *audio.Chunk) []audio.Utterance {
+	var speaker string
+	if len(chunk.Speakers) > 0 {
+		speaker = chunk.Speakers[0]
+	}
+
+	newUtterance := func(text string, start, end time.Time, confidence float64) audio.Utterance {
+		return audio.Utterance{
+			ID:         uuid.New(),
+			TSStart:    start,
+			TSEnd:      end,
+			UserID:     speaker,
+			UserTag:    speaker,
+			Text:       text,
+			Source:     "whisper",
+			Confidence: confidence,
+		}
+	}
+
+	if len(result.Segments) == 0 {
+		text := strings.TrimSpace(result.Text)
+		if text == "" {
+			return nil
+		}
+		return []audio.Utterance{newUtterance(text, chunk.Start, chunk.End, 0)}
+	}
+
+	var utterances []audio.Utterance
+	for _, segment := range result.Segments {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+
+		if segment.NoSpeechProb > noSpeechThreshold && segment.AvgLogProb < logProbThreshold {
+			log.Debug().
+				Str("chunk_id", chunk.ID.String()).
+				Str("text", text).
+				Float64("no_speech_prob", segment.NoSpeechProb).
+				Msg("Dropping likely hallucinated Whisper segment")
+			continue
+		}
+
+		start := chunk.TimeAt(time.Duration(segment.Start * float64(time.Second)))
+		end := chunk.TimeAt(time.Duration(segment.End * float64(time.Second)))
+		utterances = append(utterances, newUtterance(text, start, end, math.Exp(segment.AvgLogProb)))
+	}
+
+	return utterances
+}
+
+func (w *WhisperTranscriber) SampleRate() int {
+	return preferredSampleRate
+}
+
+func (w *WhisperTranscriber) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,76 @@
+package whisper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/discord-notetaker/internal/audio"
+)
+
+func TestToUtterances(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	chunk := &audio.Chunk{
+		Start:    start,
+		End:      start.Add(5 * time.Second),
+		Speakers: []string{"alice"},
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string // "text@start-end" in milliseconds from the chunk start
+	}{
+		{
+			name: "segments",
+			body: `{"text": "one two", "segments": [
+				{"start": 0.5, "end": 1.5, "text": " one ", "avg_logprob": -0.2, "no_speech_prob": 0.1},
+				{"start": 2, "end": 3, "text": "two", "avg_logprob": -0.3, "no_speech_prob": 0.1}
+			]}`,
+			want: "one@500-1500 | two@2000-3000",
+		},
+		{
+			name: "likely hallucinations are dropped",
+			body: `{"segments": [
+				{"start": 0, "end": 1, "text": "Thanks for watching!", "avg_logprob": -1.5, "no_speech_prob": 0.9},
+				{"start": 1, "end": 2, "text": "quiet but real", "avg_logprob": -0.5, "no_speech_prob": 0.9},
+				{"start": 2, "end": 3, "text": " ", "avg_logprob": -0.1, "no_speech_prob": 0.1}
+			]}`,
+			want: "quiet but real@1000-2000",
+		},
+		{
+			name: "text without segments spans the chunk",
+			body: `{"text": " hello "}`,
+			want: "hello@0-5000",
+		},
+		{
+			name: "nothing said",
+			body: `{"text": ""}`,
+			want: "",
+		},
+	}
+
+	w := &WhisperTranscriber{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result WhisperResponse
+			if err := json.Unmarshal([]byte(tt.body), &result); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			utterances := w.toUtterances(&result, chunk)
+			parts := make([]string, len(utterances))
+			for i, utt := range utterances {
+				parts[i] = fmt.Sprintf("%s@%d-%d", utt.Text, utt.TSStart.Sub(start).Milliseconds(), utt.TSEnd.Sub(start).Milliseconds())
+				if utt.UserID != "alice" || utt.Source != "whisper" {
+					t.Errorf("utterance %q from %q via %q, want alice via whisper", utt.Text, utt.UserID, utt.Source)
+				}
+			}
+			if got := strings.Join(parts, " | "); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -29,9 +29,15 @@ func NewGeminiSummariser(apiKey, model string) (*GeminiSummariser, error) {
 	}, nil
 }
 
-func (g *GeminiSummariser) Summarise(ctx context.Context, utterances []audio.Utterance) (string, error) {
+// Usage is the tokens a summary was billed for.
+type Usage struct {
+	InputTokens  int64
+	OutputTokens int64
+}
+
+func (g *GeminiSummariser) Summarise(ctx context.Context, utterances []audio.Utterance) (string, Usage, error) {
 	if len(utterances) == 0 {
-		return "# Meeting Notes\n\nNo transcript available.", nil
+		return "# Meeting Notes\n\nNo transcript available.", Usage{}, nil
 	}
 
 	// Convert utterances to transcript text
@@ -43,11 +49,12 @@ func (g *GeminiSummariser) Summarise(ctx context.Context, utterances []audio.Utt
 	genModel := g.client.GenerativeModel(g.model)
 	resp, err := genModel.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
-		return "", fmt.Errorf("failed to generate summary: %w", err)
+		return "", Usage{}, fmt.Errorf("failed to generate summary: %w", err)
 	}
 
 	if len(resp.Candidates) == 0 {
-		return "", fmt.Errorf("no summary generated")
+		// The prompt is billed even when nothing comes back
+		return "", Usage{InputTokens: g.countTokens(ctx, genModel, prompt)}, fmt.Errorf("no summary generated")
 	}
 
 	var summary strings.Builder
@@ -57,14 +64,39 @@ func (g *GeminiSummariser) Summarise(ctx context.Context, utterances []audio.Utt
 		}
 	}
 
+	usage := Usage{
+		InputTokens:  g.countTokens(ctx, genModel, prompt),
+		OutputTokens: int64(resp.Candidates[0].TokenCount),
+	}
+	if usage.OutputTokens == 0 {
+		usage.OutputTokens = g.countTokens(ctx, genModel, summary.String())
+	}
+
 	log.Info().
 		Int("utterances", len(utterances)).
 		Int("summary_length", summary.Len()).
+		Int64("input_tokens", usage.InputTokens).
+		Int64("output_tokens", usage.OutputTokens).
 		Msg("Generated meeting summary")
 
-	return summary.String(), nil
+	return summary.String(), usage, nil
+}
+
+// countTokens returns how many tokens the model splits text into. This
+// client's responses carry no usage metadata, so billed tokens are counted
+// separately; a failed count is logged and counts as zero rather than
+// losing the notes.
+func (g *GeminiSummariser) countTokens(ctx context.Context, model *genai.GenerativeModel, text string) int64 {
+	resp, err := model.CountTokens(ctx, genai.Text(text))
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to count summariser tokens")
+		return 0
+	}
+	return int64(resp.TotalTokens)
 }
 
+// buildTranscript formats one line per utterance; sessions pass speaker
+// turns from the assembler, so each line is a whole turn.
 func (g *GeminiSummariser) buildTranscript(utterances []audio.Utterance) string {
 	var transcript strings.Builder
 